@@ -0,0 +1,114 @@
+package gossr
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// App 是一个独立的 SSR 应用：自有数据路由（Mux 与 Engine）、渲染器、index.html 与静态资源路由，
+// 同一进程内可按路径前缀（WithBasePath）或 host 挂载多个 App。
+// 包级的 DataMux、HandleData、SsrEngine 与 NewHandler/Ssr 等价于一个默认应用。
+//
+//	admin, err := gossr.New(adminBuild, gossr.WithBasePath("/admin"))
+//	admin.HandleData("GET /users/{id}", loadUser)
+//	mux.Handle("/admin/", admin)
+//	mux.Handle("/", site)
+type App struct {
+	// Mux 为该应用的 net/http 数据路由，页面渲染与 /_ssr/data 请求优先在这里匹配。
+	Mux *http.ServeMux
+	// Engine 为该应用的 gin 数据路由，Mux 未匹配的请求交给它处理，handler 可使用 WrapSSR。
+	Engine *gin.Engine
+
+	build   FrontendBuild
+	options Options
+	server  *Server
+
+	// routesMu 保护 routes：经 HandleData 注册的路由模式，供 Prerender 枚举。
+	routesMu sync.Mutex
+	routes   []string
+}
+
+// New 创建独立的 SSR 应用，构建产物无法加载时返回错误。数据路由在 New 之后注册即可，
+// 请求时才会匹配。
+func New(build FrontendBuild, opts ...Option) (*App, error) {
+	app := &App{
+		Mux:    http.NewServeMux(),
+		Engine: newDataEngine(),
+		build:  build,
+	}
+	app.options = newOptions(opts...)
+	app.options.app = app
+
+	server, err := newServer(build, dataFetcher(app.options), newDataHandler(app.options), app.options)
+	if err != nil {
+		return nil, err
+	}
+	app.server = server
+	return app, nil
+}
+
+// ServeHTTP 实现 http.Handler。
+func (a *App) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	a.server.ServeHTTP(w, r)
+}
+
+// Server 返回应用的 Server，用于 SwapBuild 等运行时操作。
+func (a *App) Server() *Server {
+	return a.server
+}
+
+// Close 停止应用的后台任务，见 Server.Close。
+func (a *App) Close() error {
+	return a.server.Close()
+}
+
+// HandleData 在应用的 Mux 上注册数据路由，写法与包级 HandleData 相同。
+func (a *App) HandleData(pattern string, h DataHandler) {
+	a.Mux.Handle(pattern, WrapData(h))
+
+	a.routesMu.Lock()
+	a.routes = append(a.routes, pattern)
+	a.routesMu.Unlock()
+}
+
+// Resolve 在应用的数据路由上获取 SSR 数据，与包级 Resolve 相同。
+func (a *App) Resolve(ctx context.Context, rawPath, rawQuery string) (SSRPayload, int, error) {
+	return resolvePath(contextWithOptions(ctx, a.options), a, rawPath, rawQuery)
+}
+
+// Prerender 使用 New 时传入的构建与配置预渲染应用的数据路由，见包级 Prerender。
+func (a *App) Prerender(ctx context.Context, popts PrerenderOptions) (PrerenderResult, error) {
+	return prerender(ctx, a.build, popts, a.options)
+}
+
+// dataMux 返回应用的 ServeMux 数据路由，nil 表示默认应用（包级 DataMux）。
+func (a *App) dataMux() *http.ServeMux {
+	if a == nil {
+		return DataMux
+	}
+	return a.Mux
+}
+
+// dataEngine 返回应用的 gin 数据路由，nil 表示默认应用（包级 SsrEngine）。
+func (a *App) dataEngine() *gin.Engine {
+	if a == nil {
+		return SsrEngine
+	}
+	return a.Engine
+}
+
+// dataRoutePatterns 返回经 HandleData 注册的路由模式副本。
+func (a *App) dataRoutePatterns() []string {
+	if a == nil {
+		dataRoutesMu.Lock()
+		defer dataRoutesMu.Unlock()
+		return slices.Clone(dataRoutes)
+	}
+	a.routesMu.Lock()
+	defer a.routesMu.Unlock()
+	return slices.Clone(a.routes)
+}
@@ -0,0 +1,137 @@
+package gossr
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func testAppScript(name string) string {
+	return `globalThis.ssrRender = function(url) {
+		if (url === "/private") return { html: "", redirect: "/login" }
+		return "<p>` + name + `:" + url + ":" + (__SSR_DATA__.message || "none") + "</p>"
+	}`
+}
+
+func TestAppsAreIsolatedAndMountUnderPrefix(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	withTestDataMux(t, func(*http.ServeMux) {
+		HandleData("GET /users/{id}", func(*http.Request) (SSRPayload, error) {
+			return mapPayload{"message": "default"}, nil
+		})
+	})
+
+	admin, err := New(testBuild(testAppScript("admin"), nil), WithBasePath("/admin/"))
+	if err != nil {
+		t.Fatalf("new admin app: %v", err)
+	}
+	admin.HandleData("GET /users/{id}", func(r *http.Request) (SSRPayload, error) {
+		return mapPayload{"message": "admin user " + r.PathValue("id")}, nil
+	})
+	admin.Engine.GET("/stats", WrapSSR(func(*gin.Context) (SSRPayload, error) {
+		return mapPayload{"message": "stats"}, nil
+	}))
+
+	site, err := New(testBuild(testAppScript("site"), nil))
+	if err != nil {
+		t.Fatalf("new site app: %v", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/admin/", admin)
+	mux.Handle("/", site)
+
+	for target, want := range map[string]string{
+		"/admin/users/7": "<p>admin:/users/7:admin user 7</p>",
+		"/admin/stats":   "<p>admin:/stats:stats</p>",
+		"/admin/":        "<p>admin:/:none</p>",
+		"/users/7":       "<p>site:/users/7:none</p>",
+	} {
+		if body := performRequest(mux, http.MethodGet, target, nil).Body.String(); !strings.Contains(body, want) {
+			t.Fatalf("%s: expected %q, got %q", target, want, body)
+		}
+	}
+
+	if w := performRequest(mux, http.MethodGet, "/admin/assets/app.js", nil); w.Code != http.StatusOK || w.Body.String() != "console.log('ok')" {
+		t.Fatalf("expected admin assets under prefix, got %d %q", w.Code, w.Body.String())
+	}
+	if w := performRequest(mux, http.MethodGet, "/admin/private", nil); w.Code != http.StatusFound || w.Header().Get("Location") != "/admin/login" {
+		t.Fatalf("expected redirect to stay under prefix, got %d %q", w.Code, w.Header().Get("Location"))
+	}
+	w := performRequest(mux, http.MethodGet, "/admin/_ssr/data/users/3", func(req *http.Request) {
+		req.Header.Set("Referer", "http://example.com/admin/")
+	})
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"message":"admin user 3"`) {
+		t.Fatalf("expected admin data route, got %d %q", w.Code, w.Body.String())
+	}
+	if w := performRequest(admin, http.MethodGet, "/administrator", nil); w.Code != http.StatusNotFound {
+		t.Fatalf("expected path outside the prefix to be rejected, got %d", w.Code)
+	}
+
+	payload, status, err := admin.Resolve(context.Background(), "/users/9", "")
+	if err != nil || status != http.StatusOK || payload.AsMap()["message"] != "admin user 9" {
+		t.Fatalf("unexpected admin resolve: %v %d %v", payload, status, err)
+	}
+	if payload, _, _ := Resolve(context.Background(), "/users/9", ""); payload.AsMap()["message"] != "default" {
+		t.Fatalf("expected package-level Resolve to keep using the default app, got %v", payload)
+	}
+}
+
+func TestMountAppsByHost(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	admin, err := New(testBuild(testAppScript("admin"), nil))
+	if err != nil {
+		t.Fatalf("new admin app: %v", err)
+	}
+	site, err := New(testBuild(testAppScript("site"), nil))
+	if err != nil {
+		t.Fatalf("new site app: %v", err)
+	}
+
+	router := gin.New()
+	router.GET("/api/ping", func(c *gin.Context) { c.String(http.StatusOK, "pong") })
+	MountApps(router, map[string]*App{"admin.example.com/": admin, "/": site})
+
+	if body := performRequest(router, http.MethodGet, "/", func(req *http.Request) {
+		req.Host = "admin.example.com"
+	}).Body.String(); !strings.Contains(body, "<p>admin:/:none</p>") {
+		t.Fatalf("expected admin app for its host, got %q", body)
+	}
+	if w := performRequest(router, http.MethodGet, "/", nil); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "<p>site:/:none</p>") {
+		t.Fatalf("expected site app for other hosts, got %d %q", w.Code, w.Body.String())
+	}
+	if body := performRequest(router, http.MethodGet, "/api/ping", nil).Body.String(); body != "pong" {
+		t.Fatalf("expected gin routes to take precedence, got %q", body)
+	}
+}
+
+func TestAppPrerenderUsesOwnRoutes(t *testing.T) {
+	withTestDataMux(t, func(*http.ServeMux) {
+		HandleData("GET /default-only", func(*http.Request) (SSRPayload, error) {
+			return mapPayload{"message": "default"}, nil
+		})
+	})
+
+	app, err := New(testBuild(testAppScript("docs"), nil))
+	if err != nil {
+		t.Fatalf("new app: %v", err)
+	}
+	app.HandleData("GET /guide", func(*http.Request) (SSRPayload, error) {
+		return mapPayload{"message": "guide"}, nil
+	})
+
+	out := t.TempDir()
+	result, err := app.Prerender(context.Background(), PrerenderOptions{OutDir: out})
+	if err != nil || len(result.Written) != 1 || result.Written[0] != "/guide" {
+		t.Fatalf("expected only the app's routes to be prerendered, got %v %v", result, err)
+	}
+	if data, err := os.ReadFile(filepath.Join(out, "guide", "index.html")); err != nil || !strings.Contains(string(data), "<p>docs:/guide:guide</p>") {
+		t.Fatalf("unexpected prerendered page %q %v", data, err)
+	}
+}
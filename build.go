@@ -0,0 +1,127 @@
+package gossr
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/daodao97/gossr/renderer"
+)
+
+// pageBuild 是一份构建产物对应的渲染状态（index.html、渲染器与资源清单），热重载时整体替换。
+type pageBuild struct {
+	// version 为 index.html 与 server.js 内容的摘要，用于区分构建（如版本 ETag）。
+	version   string
+	indexHTML string
+	ssr       renderer.Renderer
+	manifest  *assetManifest
+	hints     *assetHints
+	// islands 为 index.html 中的命名出口 <!--ssr:name-->，与主渲染并行渲染。
+	islands []islandOutlet
+	// streamUnsupported 在脚本未提供 ssrRenderStream 时置位，后续请求直接走缓冲渲染。
+	streamUnsupported atomic.Bool
+
+	// mu 的读锁由渲染中的请求持有，retire 获取写锁以等待它们结束后再关闭引擎池。
+	mu      sync.RWMutex
+	retired bool
+	// unregisterPool 注销 registerPool 登记的引擎池指标，retire 时调用。
+	unregisterPool func()
+}
+
+// loadPageBuild 读取 index.html 与 server.js 并创建渲染器；脚本无法编译时返回错误而非 panic。
+func loadPageBuild(frontendBuild FrontendBuild, options Options) (*pageBuild, error) {
+	indexBytes, err := readFSFile(frontendBuild.FrontendDist, "index.html")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read index.html: %w", err)
+	}
+	scriptName := frontendBuild.scriptName()
+	serverEntry, err := readFSFile(frontendBuild.ServerDist, scriptName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", scriptName, err)
+	}
+
+	ssr, err := newRendererChecked(string(serverEntry), scriptName, options)
+	if err != nil {
+		return nil, err
+	}
+
+	indexHTML := string(indexBytes)
+	b := newPageBuild(indexHTML, ssr, loadAssetManifest(frontendBuild.FrontendDist), options)
+	sum := sha256.Sum256([]byte(indexHTML + "\x00" + string(serverEntry)))
+	b.version = hex.EncodeToString(sum[:8])
+	return b, nil
+}
+
+// newPageBuild 创建构建并从 index.html 解析资源提示与 island 出口，文件构建与 DevSSR 共用；manifest 可为 nil。
+func newPageBuild(indexHTML string, ssr renderer.Renderer, manifest *assetManifest, options Options) *pageBuild {
+	return &pageBuild{
+		indexHTML: indexHTML,
+		ssr:       ssr,
+		manifest:  manifest,
+		hints:     newAssetHints(options, indexHTML, manifest),
+		islands:   parseIslandOutlets(indexHTML),
+	}
+}
+
+// newRendererChecked 包装 newRenderer，把引擎编译脚本时的 panic 转为错误。
+func newRendererChecked(script, scriptName string, options Options) (ssr renderer.Renderer, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			ssr, err = nil, fmt.Errorf("failed to load %s: %v", scriptName, r)
+		}
+	}()
+	return newRenderer(script, scriptName, options), nil
+}
+
+// acquireBuild 返回当前构建并登记一次渲染，调用方结束后必须调用 release，且不可嵌套调用。
+func (h *pageHandler) acquireBuild() *pageBuild {
+	for {
+		b := h.build.Load()
+		b.mu.RLock()
+		if !b.retired {
+			return b
+		}
+		// 读取指针与加锁之间构建已被替换，改用新的构建。
+		b.mu.RUnlock()
+	}
+}
+
+func (b *pageBuild) release() {
+	b.mu.RUnlock()
+}
+
+// swapBuild 原子替换当前构建，并在后台等待旧构建上的渲染结束后关闭其引擎池。
+func (h *pageHandler) swapBuild(next *pageBuild) {
+	h.registerPool(next)
+	old := h.build.Swap(next)
+	if h.cache != nil {
+		h.cache.flush()
+	}
+	if old != nil {
+		go old.retire()
+	}
+}
+
+// retire 等待进行中的渲染结束后关闭渲染器。
+func (b *pageBuild) retire() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.retired = true
+	if closer, ok := b.ssr.(renderer.Closer); ok {
+		closer.Close()
+	}
+	if b.unregisterPool != nil {
+		b.unregisterPool()
+	}
+}
+
+// registerPool 向 Metrics 登记构建的引擎池，多个 App 及 SwapBuild 前后的池分别上报，构建退役时注销。
+func (h *pageHandler) registerPool(b *pageBuild) {
+	provider, ok := b.ssr.(renderer.PoolStatsProvider)
+	if !ok || h.options.Metrics == nil {
+		return
+	}
+	b.unregisterPool = h.options.Metrics.RegisterPool(h.engine, provider.PoolStats)
+}
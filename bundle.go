@@ -0,0 +1,342 @@
+package gossr
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/daodao97/gossr/renderer"
+)
+
+// maxArchiveBytes 限制构建包解压后的总大小，防止异常压缩包占满内存。
+const maxArchiveBytes = 512 << 20
+
+// Layout 描述构建产物根目录下 client/server 子目录与 SSR 脚本的位置。
+// 零值对应 vite build 的 dist/ 目录本身：client/、server/ 与 server/server.js。
+type Layout struct {
+	// ClientDir 为含 index.html 与 assets/ 的目录，默认 client。
+	ClientDir string
+	// ServerDir 为含 SSR 脚本的目录，默认 server。
+	ServerDir string
+	// ScriptName 为 ServerDir 下的 SSR 脚本文件名，默认 server.js。
+	ScriptName string
+}
+
+func (l Layout) withDefaults() Layout {
+	if l.ClientDir == "" {
+		l.ClientDir = "client"
+	}
+	if l.ServerDir == "" {
+		l.ServerDir = "server"
+	}
+	if l.ScriptName == "" {
+		l.ScriptName = renderer.DefaultSSRScriptName
+	}
+	return l
+}
+
+// LoadFS 按 layout 从任意 fs.FS 中取出构建产物，并检查 index.html 与 SSR 脚本存在。
+func LoadFS(fsys fs.FS, layout Layout) (FrontendBuild, error) {
+	layout = layout.withDefaults()
+
+	client, err := fs.Sub(fsys, path.Clean(layout.ClientDir))
+	if err != nil {
+		return FrontendBuild{}, fmt.Errorf("client dir %q: %w", layout.ClientDir, err)
+	}
+	server, err := fs.Sub(fsys, path.Clean(layout.ServerDir))
+	if err != nil {
+		return FrontendBuild{}, fmt.Errorf("server dir %q: %w", layout.ServerDir, err)
+	}
+
+	if _, err := fs.Stat(client, "index.html"); err != nil {
+		return FrontendBuild{}, fmt.Errorf("build is missing %s/index.html: %w", layout.ClientDir, err)
+	}
+	if _, err := fs.Stat(server, layout.ScriptName); err != nil {
+		return FrontendBuild{}, fmt.Errorf("build is missing %s/%s: %w", layout.ServerDir, layout.ScriptName, err)
+	}
+
+	return FrontendBuild{
+		FrontendDist: client,
+		ServerDist:   server,
+		ScriptName:   layout.ScriptName,
+	}, nil
+}
+
+// LoadDir 按 layout 读取磁盘目录 dir 中的构建产物，文件在请求时从磁盘读取。
+func LoadDir(dir string, layout Layout) (FrontendBuild, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return FrontendBuild{}, err
+	}
+	if !info.IsDir() {
+		return FrontendBuild{}, fmt.Errorf("%s is not a directory", dir)
+	}
+	return LoadFS(os.DirFS(dir), layout)
+}
+
+// LoadArchive 把 .tar.gz/.tgz 或 .zip 构建包整体读入内存后按 layout 取出构建产物，
+// 便于前端产物独立于 Go 二进制发布。
+func LoadArchive(file string, layout Layout) (FrontendBuild, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return FrontendBuild{}, err
+	}
+
+	var fsys memFS
+	switch name := strings.ToLower(file); {
+	case strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		fsys, err = readTarGz(data)
+	case strings.HasSuffix(name, ".zip"):
+		fsys, err = readZip(data)
+	default:
+		return FrontendBuild{}, fmt.Errorf("unsupported build archive %s (want .tar.gz, .tgz or .zip)", file)
+	}
+	if err != nil {
+		return FrontendBuild{}, fmt.Errorf("read build archive %s: %w", file, err)
+	}
+	return LoadFS(fsys, layout)
+}
+
+func readTarGz(data []byte) (memFS, error) {
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+
+	fsys := memFS{}
+	var total int64
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return fsys, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch header.Typeflag {
+		case tar.TypeReg:
+		case tar.TypeDir:
+			if err := fsys.addDir(header.Name, header.ModTime); err != nil {
+				return nil, err
+			}
+			continue
+		default:
+			// 链接等特殊文件不参与构建产物。
+			continue
+		}
+
+		content, err := readArchiveEntry(tr, &total)
+		if err != nil {
+			return nil, err
+		}
+		if err := fsys.addFile(header.Name, content, header.ModTime); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func readZip(data []byte) (memFS, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+
+	fsys := memFS{}
+	var total int64
+	for _, file := range zr.File {
+		if file.FileInfo().IsDir() {
+			if err := fsys.addDir(file.Name, file.Modified); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if !file.Mode().IsRegular() {
+			continue
+		}
+
+		rc, err := file.Open()
+		if err != nil {
+			return nil, err
+		}
+		content, err := readArchiveEntry(rc, &total)
+		_ = rc.Close()
+		if err != nil {
+			return nil, err
+		}
+		if err := fsys.addFile(file.Name, content, file.Modified); err != nil {
+			return nil, err
+		}
+	}
+	return fsys, nil
+}
+
+// readArchiveEntry 读取单个文件内容，total 累计已解压字节数，超过 maxArchiveBytes 时报错。
+func readArchiveEntry(r io.Reader, total *int64) ([]byte, error) {
+	remaining := maxArchiveBytes - *total
+	content, err := io.ReadAll(io.LimitReader(r, remaining+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(content)) > remaining {
+		return nil, fmt.Errorf("archive exceeds %d bytes", maxArchiveBytes)
+	}
+	*total += int64(len(content))
+	return content, nil
+}
+
+// memFS 是构建包解压后的只读内存文件系统，key 为规范化后的路径（根目录为 "."）。
+// 文件内容以 bytes.Reader 提供，支持 http.FS 所需的 Seek。
+type memFS map[string]*memEntry
+
+type memEntry struct {
+	name     string
+	data     []byte
+	modTime  time.Time
+	dir      bool
+	children map[string]*memEntry
+}
+
+// archivePath 规范化压缩包内的路径，拒绝绝对路径与 ".." 逃逸。
+func archivePath(name string) (string, error) {
+	cleaned := path.Clean(strings.TrimPrefix(strings.ReplaceAll(name, "\\", "/"), "./"))
+	if cleaned == "" || cleaned == "." {
+		return ".", nil
+	}
+	if !fs.ValidPath(cleaned) {
+		return "", fmt.Errorf("invalid path %q in archive", name)
+	}
+	return cleaned, nil
+}
+
+func (m memFS) addDir(name string, modTime time.Time) error {
+	p, err := archivePath(name)
+	if err != nil {
+		return err
+	}
+	m.mkdirAll(p, modTime)
+	return nil
+}
+
+func (m memFS) addFile(name string, data []byte, modTime time.Time) error {
+	p, err := archivePath(name)
+	if err != nil {
+		return err
+	}
+	if p == "." {
+		return fmt.Errorf("invalid path %q in archive", name)
+	}
+	if existing, ok := m[p]; ok && existing.dir {
+		return fmt.Errorf("%q is both a file and a directory in archive", name)
+	}
+
+	parent := m.mkdirAll(path.Dir(p), modTime)
+	entry := &memEntry{name: path.Base(p), data: data, modTime: modTime}
+	parent.children[entry.name] = entry
+	m[p] = entry
+	return nil
+}
+
+func (m memFS) mkdirAll(p string, modTime time.Time) *memEntry {
+	if entry, ok := m[p]; ok && entry.dir {
+		return entry
+	}
+
+	entry := &memEntry{name: path.Base(p), modTime: modTime, dir: true, children: map[string]*memEntry{}}
+	if p != "." {
+		parent := m.mkdirAll(path.Dir(p), modTime)
+		parent.children[entry.name] = entry
+	}
+	m[p] = entry
+	return entry
+}
+
+func (m memFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	entry, ok := m[name]
+	if !ok {
+		if name != "." {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+		}
+		entry = &memEntry{name: ".", dir: true}
+	}
+	if entry.dir {
+		return &memDir{entry: entry}, nil
+	}
+	return &memFile{entry: entry, Reader: bytes.NewReader(entry.data)}, nil
+}
+
+func (e *memEntry) Name() string       { return e.name }
+func (e *memEntry) Size() int64        { return int64(len(e.data)) }
+func (e *memEntry) ModTime() time.Time { return e.modTime }
+func (e *memEntry) IsDir() bool        { return e.dir }
+func (e *memEntry) Sys() any           { return nil }
+
+func (e *memEntry) Mode() fs.FileMode {
+	if e.dir {
+		return fs.ModeDir | 0o555
+	}
+	return 0o444
+}
+
+type memFile struct {
+	entry *memEntry
+	*bytes.Reader
+}
+
+func (f *memFile) Stat() (fs.FileInfo, error) { return f.entry, nil }
+func (f *memFile) Close() error               { return nil }
+
+type memDir struct {
+	entry   *memEntry
+	entries []fs.DirEntry
+	offset  int
+}
+
+func (d *memDir) Stat() (fs.FileInfo, error) { return d.entry, nil }
+func (d *memDir) Close() error               { return nil }
+
+func (d *memDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.entry.name, Err: errors.New("is a directory")}
+}
+
+func (d *memDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	if d.entries == nil {
+		names := make([]string, 0, len(d.entry.children))
+		for name := range d.entry.children {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		d.entries = make([]fs.DirEntry, 0, len(names))
+		for _, name := range names {
+			d.entries = append(d.entries, fs.FileInfoToDirEntry(d.entry.children[name]))
+		}
+	}
+
+	remaining := d.entries[d.offset:]
+	if n <= 0 {
+		d.offset = len(d.entries)
+		return remaining, nil
+	}
+	if len(remaining) == 0 {
+		return nil, io.EOF
+	}
+	if n > len(remaining) {
+		n = len(remaining)
+	}
+	d.offset += n
+	return remaining[:n], nil
+}
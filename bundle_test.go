@@ -0,0 +1,166 @@
+package gossr
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+var testBundleFiles = map[string]string{
+	"web/client/index.html":    testIndexHTML,
+	"web/client/assets/app.js": "console.log('ok')",
+	"web/client/favicon.ico":   "ico",
+	"web/server/entry.js":      `globalThis.ssrRender = function(url) { return "<p>bundle " + url + "</p>" }`,
+}
+
+var testBundleLayout = Layout{ClientDir: "web/client", ServerDir: "web/server", ScriptName: "entry.js"}
+
+func writeTarGz(t *testing.T, file string, files map[string]string) {
+	t.Helper()
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, content := range files {
+		header := &tar.Header{Name: name, Mode: 0o644, Size: int64(len(content)), ModTime: time.Now(), Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatalf("tar header: %v", err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatalf("tar write: %v", err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("tar close: %v", err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatalf("gzip close: %v", err)
+	}
+	if err := os.WriteFile(file, buf.Bytes(), 0o644); err != nil {
+		t.Fatalf("write archive: %v", err)
+	}
+}
+
+func writeZip(t *testing.T, file string, files map[string]string) {
+	t.Helper()
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatalf("zip create: %v", err)
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			t.Fatalf("zip write: %v", err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("zip close: %v", err)
+	}
+	if err := os.WriteFile(file, buf.Bytes(), 0o644); err != nil {
+		t.Fatalf("write archive: %v", err)
+	}
+}
+
+func TestMountBuildFromArchives(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	dir := t.TempDir()
+	writeTarGz(t, filepath.Join(dir, "web.tar.gz"), testBundleFiles)
+	writeZip(t, filepath.Join(dir, "web.zip"), testBundleFiles)
+
+	for _, name := range []string{"web.tar.gz", "web.zip"} {
+		build, err := LoadArchive(filepath.Join(dir, name), testBundleLayout)
+		if err != nil {
+			t.Fatalf("%s: load archive: %v", name, err)
+		}
+		if build.ScriptName != "entry.js" {
+			t.Fatalf("%s: expected script name from layout, got %q", name, build.ScriptName)
+		}
+
+		router := gin.New()
+		if _, err := MountBuild(router, build); err != nil {
+			t.Fatalf("%s: mount: %v", name, err)
+		}
+		if body := performRequest(router, http.MethodGet, "/hello", nil).Body.String(); !strings.Contains(body, "<p>bundle /hello</p>") {
+			t.Fatalf("%s: expected page rendered from the archive, got %q", name, body)
+		}
+		if w := performRequest(router, http.MethodGet, "/assets/app.js", nil); w.Code != http.StatusOK || w.Body.String() != "console.log('ok')" {
+			t.Fatalf("%s: expected asset from the archive, got %d %q", name, w.Code, w.Body.String())
+		}
+		if w := performRequest(router, http.MethodGet, "/favicon.ico", nil); w.Code != http.StatusOK || w.Body.String() != "ico" {
+			t.Fatalf("%s: expected root file from the archive, got %d %q", name, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestLoadArchiveRejectsInvalidArchives(t *testing.T) {
+	dir := t.TempDir()
+
+	escape := filepath.Join(dir, "escape.tar.gz")
+	writeTarGz(t, escape, map[string]string{"../evil.js": "x"})
+	if _, err := LoadArchive(escape, Layout{}); err == nil || !strings.Contains(err.Error(), "invalid path") {
+		t.Fatalf("expected path escape to be rejected, got %v", err)
+	}
+
+	missing := filepath.Join(dir, "missing.zip")
+	writeZip(t, missing, map[string]string{"client/index.html": testIndexHTML})
+	if _, err := LoadArchive(missing, Layout{}); err == nil || !strings.Contains(err.Error(), "server/server.js") {
+		t.Fatalf("expected missing script error, got %v", err)
+	}
+
+	if _, err := LoadArchive(filepath.Join(dir, "web.rar"), Layout{}); err == nil {
+		t.Fatal("expected unsupported archive error")
+	}
+}
+
+func TestLoadDir(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range testBundleFiles {
+		writeBuildFile(t, dir, name, content)
+	}
+
+	build, err := LoadDir(dir, testBundleLayout)
+	if err != nil {
+		t.Fatalf("load dir: %v", err)
+	}
+	if data, err := fs.ReadFile(build.ServerDist, build.ScriptName); err != nil || !strings.Contains(string(data), "ssrRender") {
+		t.Fatalf("expected script from directory, got %q %v", data, err)
+	}
+
+	if _, err := LoadDir(filepath.Join(dir, "web", "server", "entry.js"), Layout{}); err == nil {
+		t.Fatal("expected error for non-directory")
+	}
+}
+
+func TestSsrAcceptsAnyFS(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	err := Ssr(router, fstest.MapFS{
+		"dist/client/index.html": {Data: []byte(testIndexHTML)},
+		"dist/server/server.js":  {Data: []byte(`globalThis.ssrRender = function() { return "<p>mapfs</p>" }`)},
+	})
+	if err != nil {
+		t.Fatalf("ssr: %v", err)
+	}
+	if body := performRequest(router, http.MethodGet, "/", nil).Body.String(); !strings.Contains(body, "<p>mapfs</p>") {
+		t.Fatalf("expected page rendered from fs.FS, got %q", body)
+	}
+
+	if err := Ssr(gin.New(), fstest.MapFS{}); err == nil {
+		t.Fatal("expected missing build to be reported as an error")
+	}
+}
@@ -0,0 +1,343 @@
+package gossr
+
+import (
+	"container/list"
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	cacheStatusHeader      = "X-SSR-Cache"
+	cacheStateHit          = "HIT"
+	cacheStateStale        = "STALE"
+	cacheStateMiss         = "MISS"
+	cacheStateBypass       = "BYPASS"
+	defaultCacheMaxEntries = 1024
+)
+
+// CachedPage 是页面缓存中的一条记录，字段均可序列化，便于外部存储实现。
+type CachedPage struct {
+	Status int
+	Header http.Header
+	Body   []byte
+	// Encoded 为启用 WithCompression 时预先压缩的 Body，key 为 Content-Encoding（br/zstd/gzip）。
+	Encoded map[string][]byte
+	// ETag 为基于 payload 版本字段的 ETag（见 HTTPCacheOptions.VersionField），为空时按 Body 计算。
+	ETag string
+	// FreshUntil 之前直接命中；StaleUntil 之前返回旧内容并在后台重新渲染。
+	FreshUntil time.Time
+	StaleUntil time.Time
+}
+
+// RenderCache 页面缓存存储接口，默认实现为内存 LRU，可替换为 Redis 等外部存储。
+type RenderCache interface {
+	Get(ctx context.Context, key string) (*CachedPage, bool)
+	// Set 写入记录，ttl 为记录整体保留时长（含 stale-while-revalidate 窗口）。
+	Set(ctx context.Context, key string, page *CachedPage, ttl time.Duration)
+	Delete(ctx context.Context, key string)
+}
+
+// CacheRule 描述某类页面的缓存时长。
+type CacheRule struct {
+	// TTL 为 0 表示不缓存。
+	TTL time.Duration
+	// StaleWhileRevalidate 过期后仍可返回旧内容的窗口，期间后台重新渲染。
+	StaleWhileRevalidate time.Duration
+}
+
+// CacheKeyFunc 在默认 key（origin + locale + path + 排序后的 query）基础上计算缓存 key，
+// 可追加 cookie/header 等变量；返回空字符串表示跳过缓存。
+type CacheKeyFunc func(r *http.Request, base string) string
+
+// CacheOptions 页面缓存配置。
+type CacheOptions struct {
+	// Store 缓存存储，nil 时使用容量为 MaxEntries（默认 1024）的内存 LRU。
+	Store      RenderCache
+	MaxEntries int
+	// Default 未命中 Routes 时使用的规则，TTL 为 0 表示只缓存 Routes 中声明的页面。
+	Default CacheRule
+	// Routes 按 SsrEngine 同款路由模式（如 /hi/:name、/docs/*path）配置规则。
+	Routes map[string]CacheRule
+	Key    CacheKeyFunc
+	// IncludeSessions 为 true 时携带有效 session 的请求也走缓存（默认跳过）。
+	IncludeSessions bool
+}
+
+// WithRenderCache 启用页面 HTML 缓存。
+func WithRenderCache(cache CacheOptions) Option {
+	return func(opts *Options) {
+		opts.Cache = &cache
+	}
+}
+
+// VaryByCookies 返回把指定 cookie 值追加到缓存 key 的 CacheKeyFunc。
+func VaryByCookies(names ...string) CacheKeyFunc {
+	return func(r *http.Request, base string) string {
+		key := base
+		for _, name := range names {
+			value := ""
+			if cookie, err := r.Cookie(name); err == nil {
+				value = cookie.Value
+			}
+			key += "|c:" + name + "=" + value
+		}
+		return key
+	}
+}
+
+// VaryByHeaders 返回把指定请求头追加到缓存 key 的 CacheKeyFunc。
+func VaryByHeaders(names ...string) CacheKeyFunc {
+	return func(r *http.Request, base string) string {
+		key := base
+		for _, name := range names {
+			key += "|h:" + http.CanonicalHeaderKey(name) + "=" + r.Header.Get(name)
+		}
+		return key
+	}
+}
+
+// pageCache 在 renderWithTimeout 前提供整页缓存，并发未命中通过 singleflight 合并。
+type pageCache struct {
+	store          RenderCache
+	opts           CacheOptions
+	routes         routeTable[CacheRule]
+	trustForwarded bool
+	compressor     *htmlCompressor
+	flight         singleflight.Group
+	// generation 在热重载替换构建时递增并拼入 key，使旧构建渲染的页面不再命中。
+	generation atomic.Uint64
+}
+
+func newPageCache(options Options, compressor *htmlCompressor) *pageCache {
+	routeRules := routeCacheRules(options.Routes)
+	if options.Cache == nil && len(routeRules) == 0 {
+		return nil
+	}
+
+	var cacheOpts CacheOptions
+	if options.Cache != nil {
+		cacheOpts = *options.Cache
+	}
+	store := cacheOpts.Store
+	if store == nil {
+		store = NewMemoryCache(cacheOpts.MaxEntries)
+	}
+
+	// Route(..., CachePolicy(...)) 声明的规则优先于 CacheOptions.Routes。
+	rules := make(map[string]CacheRule, len(cacheOpts.Routes)+len(routeRules))
+	for pattern, rule := range cacheOpts.Routes {
+		rules[pattern] = rule
+	}
+	for pattern, rule := range routeRules {
+		rules[pattern] = rule
+	}
+
+	return &pageCache{
+		store:          store,
+		opts:           cacheOpts,
+		routes:         newRouteTable(rules),
+		trustForwarded: options.TrustForwardedHeaders,
+		compressor:     compressor,
+	}
+}
+
+// serve 返回页面响应及缓存状态（HIT/STALE/MISS/BYPASS）。
+func (pc *pageCache) serve(req *http.Request, render func(*http.Request) pageResponse) (pageResponse, string) {
+	rule, key, ok := pc.lookup(req)
+	if !ok {
+		return render(req), cacheStateBypass
+	}
+
+	now := time.Now()
+	if page, found := pc.store.Get(req.Context(), key); found && page != nil {
+		if now.Before(page.FreshUntil) {
+			return page.response(), cacheStateHit
+		}
+		if now.Before(page.StaleUntil) {
+			pc.revalidate(req, key, rule, render)
+			return page.response(), cacheStateStale
+		}
+	}
+
+	// 渲染与请求解耦，避免发起者断开后影响合并等待的其他请求。
+	detached := req.Clone(context.WithoutCancel(req.Context()))
+	v, _, _ := pc.flight.Do(key, func() (any, error) {
+		return pc.fill(detached, key, rule, render), nil
+	})
+	return v.(pageResponse), cacheStateMiss
+}
+
+// accepts 判断请求是否会进入页面缓存（命中规则且未被跳过）。
+func (pc *pageCache) accepts(req *http.Request) bool {
+	_, _, ok := pc.lookup(req)
+	return ok
+}
+
+func (pc *pageCache) lookup(req *http.Request) (CacheRule, string, bool) {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return CacheRule{}, "", false
+	}
+	if !pc.opts.IncludeSessions && sessionStateFromRequest(req) != nil {
+		return CacheRule{}, "", false
+	}
+
+	rule, ok := pc.routes.lookup(req.URL.Path)
+	if !ok {
+		rule = pc.opts.Default
+	}
+	if rule.TTL <= 0 {
+		return CacheRule{}, "", false
+	}
+
+	key := pc.baseKey(req)
+	if pc.opts.Key != nil {
+		key = pc.opts.Key(req, key)
+	}
+	if key == "" {
+		return CacheRule{}, "", false
+	}
+	if gen := pc.generation.Load(); gen > 0 {
+		key = "g" + strconv.FormatUint(gen, 10) + "|" + key
+	}
+
+	return rule, key, true
+}
+
+// flush 使已缓存的页面全部失效，旧记录由存储按 TTL/LRU 自然淘汰。
+func (pc *pageCache) flush() {
+	pc.generation.Add(1)
+}
+
+func (pc *pageCache) baseKey(req *http.Request) string {
+	return requestOrigin(req, pc.trustForwarded) + "|" + localeFromPath(req.URL.Path) + "|" + req.URL.Path + "?" + req.URL.Query().Encode()
+}
+
+func (pc *pageCache) fill(req *http.Request, key string, rule CacheRule, render func(*http.Request) pageResponse) pageResponse {
+	resp := render(req)
+	if !resp.cacheable {
+		return resp
+	}
+
+	now := time.Now()
+	page := &CachedPage{
+		Status:     resp.status,
+		Header:     resp.header.Clone(),
+		Body:       []byte(resp.body),
+		ETag:       resp.etag,
+		FreshUntil: now.Add(rule.TTL),
+		StaleUntil: now.Add(rule.TTL + rule.StaleWhileRevalidate),
+	}
+	page.Encoded = pc.compressor.encodeAll(page.Body)
+	pc.store.Set(req.Context(), key, page, rule.TTL+rule.StaleWhileRevalidate)
+	resp.encoded = page.Encoded
+	return resp
+}
+
+func (pc *pageCache) revalidate(req *http.Request, key string, rule CacheRule, render func(*http.Request) pageResponse) {
+	detached := req.Clone(context.WithoutCancel(req.Context()))
+	go func() {
+		_, _, _ = pc.flight.Do(key, func() (any, error) {
+			return pc.fill(detached, key, rule, render), nil
+		})
+	}()
+}
+
+func (p *CachedPage) response() pageResponse {
+	return pageResponse{
+		status:    p.Status,
+		header:    p.Header,
+		body:      string(p.Body),
+		cacheable: true,
+		encoded:   p.Encoded,
+		etag:      p.ETag,
+	}
+}
+
+// memoryCache 是 RenderCache 的内存 LRU 实现。
+type memoryCache struct {
+	mu         sync.Mutex
+	maxEntries int
+	ll         *list.List
+	items      map[string]*list.Element
+}
+
+type memoryCacheEntry struct {
+	key       string
+	page      *CachedPage
+	expiresAt time.Time
+}
+
+// NewMemoryCache 创建内存 LRU 页面缓存，maxEntries <= 0 时使用 1024。
+func NewMemoryCache(maxEntries int) RenderCache {
+	if maxEntries <= 0 {
+		maxEntries = defaultCacheMaxEntries
+	}
+
+	return &memoryCache{
+		maxEntries: maxEntries,
+		ll:         list.New(),
+		items:      make(map[string]*list.Element),
+	}
+}
+
+func (m *memoryCache) Get(_ context.Context, key string) (*CachedPage, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	elem, ok := m.items[key]
+	if !ok {
+		return nil, false
+	}
+
+	entry := elem.Value.(*memoryCacheEntry)
+	if !entry.expiresAt.IsZero() && time.Now().After(entry.expiresAt) {
+		m.removeElement(elem)
+		return nil, false
+	}
+
+	m.ll.MoveToFront(elem)
+	return entry.page, true
+}
+
+func (m *memoryCache) Set(_ context.Context, key string, page *CachedPage, ttl time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var expiresAt time.Time
+	if ttl > 0 {
+		expiresAt = time.Now().Add(ttl)
+	}
+
+	if elem, ok := m.items[key]; ok {
+		entry := elem.Value.(*memoryCacheEntry)
+		entry.page = page
+		entry.expiresAt = expiresAt
+		m.ll.MoveToFront(elem)
+		return
+	}
+
+	m.items[key] = m.ll.PushFront(&memoryCacheEntry{key: key, page: page, expiresAt: expiresAt})
+	for m.ll.Len() > m.maxEntries {
+		m.removeElement(m.ll.Back())
+	}
+}
+
+func (m *memoryCache) Delete(_ context.Context, key string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if elem, ok := m.items[key]; ok {
+		m.removeElement(elem)
+	}
+}
+
+func (m *memoryCache) removeElement(elem *list.Element) {
+	m.ll.Remove(elem)
+	delete(m.items, elem.Value.(*memoryCacheEntry).key)
+}
@@ -0,0 +1,201 @@
+package gossr
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRunBlockingRenderCache(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := testRouterWithRunBlocking(`globalThis.renders = 0
+	globalThis.ssrRender = function(url) { globalThis.renders++; return "<div>" + url + "#" + globalThis.renders + "</div>" }`,
+		WithRenderCache(CacheOptions{
+			Routes: map[string]CacheRule{"/cached/:id": {TTL: time.Minute}},
+		}))
+
+	first := performRequest(router, http.MethodGet, "/cached/1?b=2&a=1", nil)
+	second := performRequest(router, http.MethodGet, "/cached/1?a=1&b=2", nil)
+
+	if got := first.Header().Get(cacheStatusHeader); got != cacheStateMiss {
+		t.Fatalf("expected first request MISS, got %q", got)
+	}
+	if got := second.Header().Get(cacheStatusHeader); got != cacheStateHit {
+		t.Fatalf("expected second request HIT, got %q", got)
+	}
+	if first.Body.String() != second.Body.String() {
+		t.Fatalf("expected cached body to match, got %q vs %q", first.Body.String(), second.Body.String())
+	}
+	assertNoCacheHeaders(t, second.Header())
+
+	t.Run("uncached route bypasses", func(t *testing.T) {
+		w := performRequest(router, http.MethodGet, "/other", nil)
+		if got := w.Header().Get(cacheStatusHeader); got != cacheStateBypass {
+			t.Fatalf("expected BYPASS, got %q", got)
+		}
+	})
+
+	t.Run("session bypasses by default", func(t *testing.T) {
+		token := mustSessionToken(t, map[string]any{"email": "demo@example.com"})
+		w := performRequest(router, http.MethodGet, "/cached/1?a=1&b=2", func(req *http.Request) {
+			addSessionTokenCookie(req, token)
+		})
+		if got := w.Header().Get(cacheStatusHeader); got != cacheStateBypass {
+			t.Fatalf("expected BYPASS for session request, got %q", got)
+		}
+	})
+}
+
+func TestPageCacheStaleWhileRevalidate(t *testing.T) {
+	var renders atomic.Int32
+	revalidated := make(chan struct{}, 1)
+	render := func(*http.Request) pageResponse {
+		n := renders.Add(1)
+		if n > 1 {
+			defer func() { revalidated <- struct{}{} }()
+		}
+		return pageResponse{status: http.StatusOK, body: "v" + string(rune('0'+n)), cacheable: true}
+	}
+
+	pc := newPageCache(newOptions(WithRenderCache(CacheOptions{
+		Default: CacheRule{TTL: 10 * time.Millisecond, StaleWhileRevalidate: time.Minute},
+	})), nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/page", nil)
+	if resp, state := pc.serve(req, render); state != cacheStateMiss || resp.body != "v1" {
+		t.Fatalf("expected MISS v1, got %s %q", state, resp.body)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	if resp, state := pc.serve(req, render); state != cacheStateStale || resp.body != "v1" {
+		t.Fatalf("expected STALE v1, got %s %q", state, resp.body)
+	}
+
+	select {
+	case <-revalidated:
+	case <-time.After(time.Second):
+		t.Fatal("expected background revalidation")
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		resp, state := pc.serve(req, render)
+		if state == cacheStateHit && resp.body == "v2" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected revalidated HIT v2, got %s %q", state, resp.body)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestPageCacheDeduplicatesConcurrentMisses(t *testing.T) {
+	var renders atomic.Int32
+	release := make(chan struct{})
+	render := func(*http.Request) pageResponse {
+		renders.Add(1)
+		<-release
+		return pageResponse{status: http.StatusOK, body: "shared", cacheable: true}
+	}
+
+	pc := newPageCache(newOptions(WithRenderCache(CacheOptions{Default: CacheRule{TTL: time.Minute}})), nil)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, _ := pc.serve(httptest.NewRequest(http.MethodGet, "/same", nil), render)
+			if resp.body != "shared" {
+				t.Errorf("unexpected body %q", resp.body)
+			}
+		}()
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if got := renders.Load(); got != 1 {
+		t.Fatalf("expected a single render for concurrent misses, got %d", got)
+	}
+}
+
+func TestPageCacheKeyVariance(t *testing.T) {
+	pc := newPageCache(newOptions(WithRenderCache(CacheOptions{
+		Default: CacheRule{TTL: time.Minute},
+		Key:     VaryByCookies("ab"),
+	})), nil)
+
+	render := func(r *http.Request) pageResponse {
+		cookie, _ := r.Cookie("ab")
+		return pageResponse{status: http.StatusOK, body: "variant-" + cookie.Value, cacheable: true}
+	}
+
+	for _, variant := range []string{"a", "b"} {
+		req := httptest.NewRequest(http.MethodGet, "/exp", nil)
+		req.AddCookie(&http.Cookie{Name: "ab", Value: variant})
+		if resp, _ := pc.serve(req, render); resp.body != "variant-"+variant {
+			t.Fatalf("expected variant %s, got %q", variant, resp.body)
+		}
+	}
+}
+
+func TestMemoryCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	ctx := context.Background()
+	cache := NewMemoryCache(2)
+	cache.Set(ctx, "a", &CachedPage{Body: []byte("a")}, time.Minute)
+	cache.Set(ctx, "b", &CachedPage{Body: []byte("b")}, time.Minute)
+	if _, ok := cache.Get(ctx, "a"); !ok {
+		t.Fatal("expected a to be cached")
+	}
+	cache.Set(ctx, "c", &CachedPage{Body: []byte("c")}, time.Minute)
+
+	if _, ok := cache.Get(ctx, "b"); ok {
+		t.Fatal("expected least recently used entry b to be evicted")
+	}
+	if _, ok := cache.Get(ctx, "a"); !ok {
+		t.Fatal("expected a to survive eviction")
+	}
+
+	cache.Set(ctx, "expired", &CachedPage{}, time.Nanosecond)
+	time.Sleep(time.Millisecond)
+	if _, ok := cache.Get(ctx, "expired"); ok {
+		t.Fatal("expected expired entry to be dropped")
+	}
+}
+
+func TestRouteTableLookupPrefersStaticSegments(t *testing.T) {
+	table := newRouteTable(map[string]string{
+		"/hi/:name":    "param",
+		"/hi/gopher":   "static",
+		"/docs/*path":  "wildcard",
+		"/":            "root",
+		"/zh/hi/:name": "localized",
+	})
+
+	tests := map[string]string{
+		"/hi/gopher":    "static",
+		"/hi/vue":       "param",
+		"/docs/a/b":     "wildcard",
+		"/":             "root",
+		"/zh/hi/gopher": "localized",
+	}
+	for path, want := range tests {
+		if got, ok := table.lookup(path); !ok || got != want {
+			t.Fatalf("lookup(%q)=%q,%v want %q", path, got, ok, want)
+		}
+	}
+
+	if _, ok := table.lookup("/hi/a/b"); ok {
+		t.Fatal("expected no match for extra segments")
+	}
+}
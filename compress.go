@@ -0,0 +1,245 @@
+package gossr
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	encodingBrotli = "br"
+	encodingZstd   = "zstd"
+	encodingGzip   = "gzip"
+
+	defaultCompressionMinSize = 1024
+)
+
+// precompressedSuffixes 为 /assets 下预压缩文件的后缀，按优先级排列。
+var precompressedSuffixes = []struct {
+	encoding string
+	suffix   string
+}{
+	{encodingBrotli, ".br"},
+	{encodingGzip, ".gz"},
+}
+
+// CompressionOptions 渲染后 HTML 的压缩配置。
+type CompressionOptions struct {
+	// Encodings 按优先级排列的编码（br、zstd、gzip），为空时依次为 br、zstd、gzip。
+	Encodings []string
+	// MinSize 小于该字节数的 HTML 不压缩，<=0 时使用 1024。
+	MinSize int
+}
+
+// WithCompression 启用 SSR HTML 压缩；启用页面缓存时各编码的结果随缓存一起保存。
+func WithCompression(compression CompressionOptions) Option {
+	return func(opts *Options) {
+		opts.Compression = &compression
+	}
+}
+
+// htmlCompressor 按 Accept-Encoding 协商编码并压缩 HTML。
+type htmlCompressor struct {
+	encodings []string
+	minSize   int
+	zstd      *zstd.Encoder
+}
+
+func newHTMLCompressor(options Options) *htmlCompressor {
+	if options.Compression == nil {
+		return nil
+	}
+
+	hc := &htmlCompressor{minSize: options.Compression.MinSize}
+	if hc.minSize <= 0 {
+		hc.minSize = defaultCompressionMinSize
+	}
+
+	encodings := options.Compression.Encodings
+	if len(encodings) == 0 {
+		encodings = []string{encodingBrotli, encodingZstd, encodingGzip}
+	}
+	for _, enc := range encodings {
+		switch enc = strings.ToLower(strings.TrimSpace(enc)); enc {
+		case encodingBrotli, encodingZstd, encodingGzip:
+			hc.encodings = append(hc.encodings, enc)
+		default:
+			log.Printf("config: unsupported compression encoding %q ignored", enc)
+		}
+	}
+	if len(hc.encodings) == 0 {
+		return nil
+	}
+
+	if encoder, err := zstd.NewWriter(nil); err == nil {
+		hc.zstd = encoder
+	}
+	return hc
+}
+
+// eligible 判断 body 是否达到压缩阈值。
+func (hc *htmlCompressor) eligible(body string) bool {
+	return hc != nil && len(body) >= hc.minSize
+}
+
+// negotiate 按配置的优先级选出客户端接受的编码，无可用编码时返回空字符串。
+func (hc *htmlCompressor) negotiate(acceptEncoding string) string {
+	if hc == nil {
+		return ""
+	}
+	accepted := parseAcceptEncoding(acceptEncoding)
+	for _, enc := range hc.encodings {
+		if acceptsEncoding(accepted, enc) {
+			return enc
+		}
+	}
+	return ""
+}
+
+// encodeAll 以全部配置的编码压缩 body，供页面缓存保存。
+func (hc *htmlCompressor) encodeAll(body []byte) map[string][]byte {
+	if hc == nil || len(body) < hc.minSize {
+		return nil
+	}
+
+	encoded := make(map[string][]byte, len(hc.encodings))
+	for _, enc := range hc.encodings {
+		data, err := hc.encode(enc, body)
+		if err != nil {
+			log.Printf("compress html with %s failed: %v", enc, err)
+			continue
+		}
+		encoded[enc] = data
+	}
+	return encoded
+}
+
+func (hc *htmlCompressor) encode(enc string, body []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	switch enc {
+	case encodingBrotli:
+		w := brotli.NewWriterLevel(&buf, brotli.DefaultCompression)
+		if _, err := w.Write(body); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+	case encodingGzip:
+		w := gzip.NewWriter(&buf)
+		if _, err := w.Write(body); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+	case encodingZstd:
+		if hc.zstd == nil {
+			return nil, fmt.Errorf("unsupported encoding %q", enc)
+		}
+		return hc.zstd.EncodeAll(body, nil), nil
+	default:
+		return nil, fmt.Errorf("unsupported encoding %q", enc)
+	}
+	return buf.Bytes(), nil
+}
+
+// parseAcceptEncoding 解析 Accept-Encoding，返回编码到 q 值的映射。
+func parseAcceptEncoding(header string) map[string]float64 {
+	accepted := make(map[string]float64)
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(part, ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+
+		q := 1.0
+		for _, param := range strings.Split(params, ";") {
+			key, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+			if ok && strings.EqualFold(strings.TrimSpace(key), "q") {
+				if parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+		accepted[name] = q
+	}
+	return accepted
+}
+
+func acceptsEncoding(accepted map[string]float64, enc string) bool {
+	if q, ok := accepted[enc]; ok {
+		return q > 0
+	}
+	q, ok := accepted["*"]
+	return ok && q > 0
+}
+
+// precompressedAssets 在 Accept-Encoding 允许时返回 foo.js.br / foo.js.gz 等预压缩文件，
+// 未命中时交给 next 处理。
+func precompressedAssets(assets fs.FS, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := assetName(r)
+		switch path.Ext(name) {
+		case "", ".br", ".gz":
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		accepted := parseAcceptEncoding(r.Header.Get("Accept-Encoding"))
+		hasVariant := false
+		for _, candidate := range precompressedSuffixes {
+			file, err := assets.Open(name + candidate.suffix)
+			if err != nil {
+				continue
+			}
+			stat, err := file.Stat()
+			if err != nil || stat.IsDir() {
+				_ = file.Close()
+				continue
+			}
+			hasVariant = true
+			if !acceptsEncoding(accepted, candidate.encoding) {
+				_ = file.Close()
+				continue
+			}
+
+			content, ok := file.(io.ReadSeeker)
+			if !ok {
+				data, err := io.ReadAll(file)
+				if err != nil {
+					_ = file.Close()
+					continue
+				}
+				content = bytes.NewReader(data)
+			}
+
+			header := w.Header()
+			header.Set("Vary", "Accept-Encoding")
+			header.Set("Content-Encoding", candidate.encoding)
+			if ctype := mime.TypeByExtension(path.Ext(name)); ctype != "" {
+				header.Set("Content-Type", ctype)
+			}
+			http.ServeContent(w, r, name, stat.ModTime(), content)
+			_ = file.Close()
+			return
+		}
+
+		if hasVariant {
+			w.Header().Set("Vary", "Accept-Encoding")
+		}
+		next.ServeHTTP(w, r)
+	})
+}
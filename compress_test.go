@@ -0,0 +1,176 @@
+package gossr
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/andybalholm/brotli"
+	"github.com/gin-gonic/gin"
+	"github.com/klauspost/compress/zstd"
+)
+
+func decodeBody(t *testing.T, encoding string, data []byte) string {
+	t.Helper()
+
+	var r io.Reader
+	switch encoding {
+	case "br":
+		r = brotli.NewReader(bytes.NewReader(data))
+	case "gzip":
+		gz, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("gzip reader: %v", err)
+		}
+		r = gz
+	case "zstd":
+		zr, err := zstd.NewReader(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("zstd reader: %v", err)
+		}
+		defer zr.Close()
+		r = zr
+	default:
+		return string(data)
+	}
+
+	decoded, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("decode %s: %v", encoding, err)
+	}
+	return string(decoded)
+}
+
+func TestHTMLCompressorNegotiate(t *testing.T) {
+	hc := newHTMLCompressor(newOptions(WithCompression(CompressionOptions{})))
+
+	tests := map[string]string{
+		"":                           "",
+		"gzip, deflate, br, zstd":    "br",
+		"gzip;q=1.0, br;q=0":         "gzip",
+		"zstd, gzip":                 "zstd",
+		"*":                          "br",
+		"*;q=0.5, br;q=0":            "zstd",
+		"identity":                   "",
+		"GZIP; q=0.8, deflate;q=1":   "gzip",
+		"br;q=0, zstd;q=0, gzip;q=0": "",
+	}
+	for header, want := range tests {
+		if got := hc.negotiate(header); got != want {
+			t.Fatalf("negotiate(%q)=%q want %q", header, got, want)
+		}
+	}
+
+	if newHTMLCompressor(newOptions()) != nil {
+		t.Fatal("expected compression to be disabled by default")
+	}
+	gzipOnly := newHTMLCompressor(newOptions(WithCompression(CompressionOptions{Encodings: []string{"gzip", "deflate"}})))
+	if got := gzipOnly.negotiate("br, gzip"); got != "gzip" {
+		t.Fatalf("expected configured encodings to be respected, got %q", got)
+	}
+}
+
+func TestRunBlockingCompressesHTML(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	script := `globalThis.ssrRender = function(url) {
+		if (url === "/small") return "<p>small</p>"
+		return "<p>" + "x".repeat(4096) + "</p>"
+	}`
+	router := gin.New()
+	RunBlocking(router, FrontendBuild{
+		FrontendDist: testFrontendDistFS(),
+		ServerDist:   fstest.MapFS{"server.js": {Data: []byte(script)}},
+	}, nil,
+		WithCompression(CompressionOptions{MinSize: 512}),
+		WithRenderCache(CacheOptions{Routes: map[string]CacheRule{"/cached": {TTL: time.Minute}}}),
+	)
+
+	for _, target := range []string{"/page", "/cached", "/cached"} {
+		for _, enc := range []string{"br", "zstd", "gzip"} {
+			w := performRequest(router, http.MethodGet, target, func(req *http.Request) {
+				req.Header.Set("Accept-Encoding", enc)
+			})
+			if got := w.Header().Get("Content-Encoding"); got != enc {
+				t.Fatalf("%s: expected Content-Encoding %s, got %q", target, enc, got)
+			}
+			if !strings.Contains(w.Header().Get("Vary"), "Accept-Encoding") {
+				t.Fatalf("%s: expected Vary: Accept-Encoding, got %q", target, w.Header().Get("Vary"))
+			}
+			if body := decodeBody(t, enc, w.Body.Bytes()); !strings.Contains(body, strings.Repeat("x", 4096)) {
+				t.Fatalf("%s: unexpected decoded body %q", target, body)
+			}
+		}
+	}
+
+	w := performRequest(router, http.MethodGet, "/page", nil)
+	if w.Header().Get("Content-Encoding") != "" || !strings.Contains(w.Body.String(), "xxxx") {
+		t.Fatalf("expected identity response without Accept-Encoding, got %v", w.Header())
+	}
+
+	w = performRequest(router, http.MethodGet, "/small", func(req *http.Request) {
+		req.Header.Set("Accept-Encoding", "gzip")
+	})
+	if w.Header().Get("Content-Encoding") != "" || w.Header().Get("Vary") != "" {
+		t.Fatalf("expected small page to stay uncompressed, got %v", w.Header())
+	}
+}
+
+func TestRunBlockingServesPrecompressedAssets(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	dist := testFrontendDistFS()
+	dist["assets/app.js"] = &fstest.MapFile{Data: []byte("console.log('plain')")}
+	dist["assets/app.js.br"] = &fstest.MapFile{Data: []byte("brotli-bytes")}
+	dist["assets/app.js.gz"] = &fstest.MapFile{Data: []byte("gzip-bytes")}
+	dist["assets/only.css"] = &fstest.MapFile{Data: []byte("body{}")}
+
+	router := gin.New()
+	RunBlocking(router, FrontendBuild{
+		FrontendDist: dist,
+		ServerDist:   fstest.MapFS{"server.js": {Data: []byte(`globalThis.ssrRender = function() { return "" }`)}},
+	}, nil)
+
+	tests := []struct {
+		path, accept, encoding, body, vary string
+	}{
+		{"/assets/app.js", "gzip, br", "br", "brotli-bytes", "Accept-Encoding"},
+		{"/assets/app.js", "gzip", "gzip", "gzip-bytes", "Accept-Encoding"},
+		{"/assets/app.js", "br;q=0, gzip", "gzip", "gzip-bytes", "Accept-Encoding"},
+		{"/assets/app.js", "", "", "console.log('plain')", "Accept-Encoding"},
+		{"/assets/only.css", "br, gzip", "", "body{}", ""},
+		{"/assets/app.js.gz", "gzip", "", "gzip-bytes", ""},
+	}
+	for _, tt := range tests {
+		w := performRequest(router, http.MethodGet, tt.path, func(req *http.Request) {
+			if tt.accept != "" {
+				req.Header.Set("Accept-Encoding", tt.accept)
+			}
+		})
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s (%s): unexpected status %d", tt.path, tt.accept, w.Code)
+		}
+		if got := w.Header().Get("Content-Encoding"); got != tt.encoding {
+			t.Fatalf("%s (%s): Content-Encoding=%q want %q", tt.path, tt.accept, got, tt.encoding)
+		}
+		if got := w.Header().Get("Vary"); got != tt.vary {
+			t.Fatalf("%s (%s): Vary=%q want %q", tt.path, tt.accept, got, tt.vary)
+		}
+		if got := w.Body.String(); got != tt.body {
+			t.Fatalf("%s (%s): body=%q want %q", tt.path, tt.accept, got, tt.body)
+		}
+		if tt.path == "/assets/app.js" {
+			if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/javascript") {
+				t.Fatalf("expected javascript content type, got %q", ct)
+			}
+			if cc := w.Header().Get("Cache-Control"); cc != cacheImmutableAsset {
+				t.Fatalf("expected immutable cache header, got %q", cc)
+			}
+		}
+	}
+}
@@ -0,0 +1,313 @@
+package gossr
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// errNotFound 用于 handler 表示路由不匹配（如无效的 locale）
+var errNotFound = errors.New("not found")
+
+// DataHandler 是不依赖 Gin 的数据路由 handler，路由参数经 r.PathValue 读取。
+type DataHandler func(r *http.Request) (SSRPayload, error)
+
+// DataMux 为默认应用的 SSR 数据路由（标准库 ServeMux 模式，如 "GET /hi/{name}"），
+// 页面渲染与 /_ssr/data 请求优先在这里匹配，未命中时再交给 SsrEngine。独立应用使用 App.Mux，见 New。
+var DataMux = http.NewServeMux()
+
+// HandleData 在 DataMux 上注册数据路由。注意 ServeMux 中以 / 结尾的模式匹配整个子树，
+// 只匹配首页时使用 "/{$}"。
+func HandleData(pattern string, h DataHandler) {
+	DataMux.Handle(pattern, WrapData(h))
+
+	dataRoutesMu.Lock()
+	dataRoutes = append(dataRoutes, pattern)
+	dataRoutesMu.Unlock()
+}
+
+// WrapData 把 DataHandler 包装为 http.Handler：返回的 payload 以 JSON 输出，
+// 错误默认只返回通用提示，DevMode 且开启 ExposeHandlerErrors 时返回原始错误文本。
+func WrapData(h DataHandler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		payload, err := h(r)
+		if errors.Is(err, errNotFound) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("ssr handler failed path=%s err=%v", r.URL.Path, err)
+			if opts := optionsFromContext(r.Context()); opts.DevMode && opts.ExposeHandlerErrors {
+				writeJSON(w, http.StatusInternalServerError, map[string]any{"error": err.Error()})
+				return
+			}
+			writeJSON(w, http.StatusInternalServerError, map[string]any{"error": "internal server error"})
+			return
+		}
+		if payload == nil {
+			writeJSON(w, http.StatusOK, map[string]any{})
+			return
+		}
+		writeJSON(w, http.StatusOK, payload.AsMap())
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		status, data = http.StatusInternalServerError, []byte(`{"error":"internal server error"}`)
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	_, _ = w.Write(data)
+}
+
+// DataRouter 返回供客户端 fetch 调用的数据接口 Handler，请求路径即页面路径
+// （挂在前缀下时配合 http.StripPrefix），不含 /_ssr/data 的访问校验。
+func DataRouter(opts ...Option) http.Handler {
+	options := newOptions(opts...)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := contextWithOptions(r.Context(), options)
+		status, body := serveSSRData(ctx, r, r.URL.Path, r.URL.RawQuery, options)
+		if status != http.StatusOK {
+			w.Header().Set("Content-Type", "application/json")
+		} else {
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		}
+		w.WriteHeader(status)
+		_, _ = w.Write(body)
+	})
+}
+
+// newDataHandler 返回挂在 /_ssr/data 下、带访问校验的数据接口。
+func newDataHandler(options Options) http.Handler {
+	data := http.StripPrefix(DefaultSSRDataRoute, DataRouter(WithOptions(options)))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if code, ok := authorizeSSRFetch(r, options); !ok {
+			w.WriteHeader(code)
+			return
+		}
+		data.ServeHTTP(w, r)
+	})
+}
+
+// dataFetcher 返回进程内调用数据路由的 BackendDataFetcher，未匹配的路由按空 payload 渲染。
+func dataFetcher(options Options) BackendDataFetcher {
+	return func(ctx context.Context, req *http.Request) (SSRPayload, error) {
+		payload, status, err := resolveRequest(contextWithOptions(ctx, options), options.app, req)
+		if err != nil {
+			return nil, err
+		}
+
+		switch status {
+		case http.StatusOK:
+			return payload, nil
+		case http.StatusNotFound:
+			return mapPayload{}, nil
+		default:
+			return nil, fmt.Errorf("ssr fetch %s returned status %d", req.URL.Path, status)
+		}
+	}
+}
+
+// serveSSRData 执行数据路由；成功时返回补充了路由上下文的 JSON，否则原样返回 handler 输出。
+func serveSSRData(ctx context.Context, sourceReq *http.Request, requestPath, rawQuery string, options Options) (int, []byte) {
+	w, req := callDataRoutes(ctx, options.app, sourceReq, requestPath, rawQuery)
+	if w.Code != http.StatusOK {
+		return w.Code, w.Body.Bytes()
+	}
+
+	var data map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &data); err != nil {
+		return w.Code, w.Body.Bytes()
+	}
+
+	body, err := json.Marshal(enrichPayloadForSSRFetchResponse(data, req, options.TrustForwardedHeaders))
+	if err != nil {
+		return http.StatusInternalServerError, []byte(`{"error":"internal server error"}`)
+	}
+	return http.StatusOK, body
+}
+
+// Resolve 服务端内部调用，获取 SSR 数据
+func Resolve(ctx context.Context, rawPath, rawQuery string) (SSRPayload, int, error) {
+	return resolvePath(ctx, nil, rawPath, rawQuery)
+}
+
+// resolvePath 在 app 的数据路由上执行 Resolve，app 为 nil 时使用包级 DataMux 与 SsrEngine。
+func resolvePath(ctx context.Context, app *App, rawPath, rawQuery string) (SSRPayload, int, error) {
+	cleanPath := path.Clean("/" + strings.TrimPrefix(strings.TrimSpace(rawPath), "/"))
+	w, _ := callDataRoutes(ctx, app, nil, cleanPath, rawQuery)
+	data, status, err := parseSSRPayloadResponse(w)
+	if err != nil || status != http.StatusOK {
+		return nil, status, err
+	}
+
+	return mapPayload(data), status, nil
+}
+
+func resolveRequest(ctx context.Context, app *App, req *http.Request) (SSRPayload, int, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	if req == nil || req.URL == nil {
+		return nil, http.StatusInternalServerError, fmt.Errorf("nil request")
+	}
+
+	cleanPath := path.Clean("/" + strings.TrimPrefix(strings.TrimSpace(req.URL.Path), "/"))
+	w, _ := callDataRoutes(ctx, app, req, cleanPath, req.URL.RawQuery)
+	data, status, err := parseSSRPayloadResponse(w)
+	if err != nil || status != http.StatusOK {
+		return nil, status, err
+	}
+
+	return mapPayload(data), status, nil
+}
+
+// callDataRoutes 在 app 的数据路由上执行请求：先匹配 ServeMux，未命中时交给 gin 引擎。
+func callDataRoutes(ctx context.Context, app *App, sourceReq *http.Request, requestPath, rawQuery string) (*httptest.ResponseRecorder, *http.Request) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	requestPath = strings.TrimSpace(requestPath)
+	if requestPath == "" {
+		requestPath = "/"
+	}
+
+	req := httptest.NewRequest(http.MethodGet, requestPath+"?"+rawQuery, nil)
+	req = req.WithContext(ctx)
+	if sourceReq != nil {
+		req.Header = sourceReq.Header.Clone()
+		req.Host = sourceReq.Host
+		req.TLS = sourceReq.TLS
+		req.RemoteAddr = sourceReq.RemoteAddr
+	}
+	w, ok := serveDataMux(app.dataMux(), req)
+	if !ok {
+		w = httptest.NewRecorder()
+		app.dataEngine().ServeHTTP(w, req)
+	}
+
+	return w, req
+}
+
+// redirectHandlerType 是 http.RedirectHandler 的类型，ServeMux 为子树路由补斜杠时也返回该类型。
+var redirectHandlerType = reflect.TypeOf(http.RedirectHandler("/", http.StatusTemporaryRedirect))
+
+// serveDataMux 在 mux 上处理请求并记录指标，未注册匹配的路由时返回 false。
+// 是否匹配在执行前由 mux.Handler 判定：405（pattern 为空）与 ServeMux 为子树路由（如 "GET /docs/"）
+// 生成的重定向都按未匹配处理；一旦匹配，handler 的响应（包括 3xx）原样返回。
+func serveDataMux(mux *http.ServeMux, req *http.Request) (*httptest.ResponseRecorder, bool) {
+	h, pattern := mux.Handler(req)
+	if pattern == "" || isMuxSlashRedirect(h, req) {
+		return nil, false
+	}
+
+	w := httptest.NewRecorder()
+	start := time.Now()
+	func() {
+		defer func() {
+			if r := recover(); r != nil {
+				log.Printf("ssr data handler panic path=%s err=%v", req.URL.Path, r)
+				w.Code = http.StatusInternalServerError
+				w.Body.Reset()
+			}
+		}()
+		mux.ServeHTTP(w, req)
+	}()
+
+	if m := optionsFromContext(req.Context()).Metrics; m != nil {
+		m.ObserveDataFetch(pattern, w.Code, time.Since(start))
+	}
+	return w, true
+}
+
+// isMuxSlashRedirect 判断 h 是否为 ServeMux 把 req 重定向到 path+"/" 的 handler。
+// 重定向 handler 没有副作用，可以直接试跑；用户注册的 http.RedirectHandler 指向别处时仍视为匹配。
+func isMuxSlashRedirect(h http.Handler, req *http.Request) bool {
+	if reflect.TypeOf(h) != redirectHandlerType {
+		return false
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if w.Code != http.StatusTemporaryRedirect {
+		return false
+	}
+	location, err := url.Parse(w.Header().Get("Location"))
+	return err == nil && location.Path == req.URL.Path+"/"
+}
+
+func parseSSRPayloadResponse(w *httptest.ResponseRecorder) (map[string]any, int, error) {
+	if w.Code == http.StatusNotFound {
+		return nil, http.StatusNotFound, nil
+	}
+
+	if w.Code != http.StatusOK {
+		return nil, w.Code, fmt.Errorf("ssr handler returned %d", w.Code)
+	}
+
+	var data map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &data); err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	return data, http.StatusOK, nil
+}
+
+func authorizeSSRFetch(r *http.Request, options Options) (int, bool) {
+	if sharedToken := options.FetchToken; sharedToken != "" {
+		if r.Header.Get("X-SSR-Token") != sharedToken {
+			return http.StatusUnauthorized, false
+		}
+		return 0, true
+	}
+
+	if sameOriginRequest(r, options.TrustForwardedHeaders) {
+		return 0, true
+	}
+
+	if options.AllowUnsafeFetchHeader && r.Header.Get("X-SSR-Fetch") == "1" {
+		return 0, true
+	}
+
+	return http.StatusForbidden, false
+}
+
+func sameOriginRequest(r *http.Request, trustForwarded bool) bool {
+	host := primaryHost(r, trustForwarded)
+	if host == "" {
+		return false
+	}
+
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		origin = r.Header.Get("Referer")
+	}
+	if origin == "" {
+		return false
+	}
+
+	parsed, err := url.Parse(origin)
+	if err != nil || parsed.Host == "" {
+		return false
+	}
+
+	return strings.EqualFold(parsed.Host, host)
+}
+
+type mapPayload map[string]any
+
+func (m mapPayload) AsMap() map[string]any {
+	return m
+}
@@ -0,0 +1,91 @@
+package gossr
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"net/http/httputil"
+	"path"
+	"strings"
+
+	"github.com/daodao97/gossr/renderer"
+	"github.com/daodao97/gossr/renderer/engine/vitedev"
+)
+
+// WithDevSSR 在 DevMode 下改为执行 Vite 插件按源码变更重新打包的 SSR 入口：页面请求走与生产一致的
+// 取数、session 补充与 head/__SSR_DATA__ 注入流程，资源、源码模块与 HMR websocket 仍代理给 Vite。
+// Vite 侧需启用 gossr-dev 插件（见 example/web/plugins/gossr-dev.ts）。
+func WithDevSSR(enabled bool) Option {
+	return func(opts *Options) {
+		opts.DevSSR = enabled
+	}
+}
+
+// newDevSSRHandler 返回开发模式下的 SSR 页面流程，Vite 插件不可用时整页回退为代理。
+func newDevSSRHandler(proxy *httputil.ReverseProxy, fetcher BackendDataFetcher, options Options) http.Handler {
+	dev := vitedev.NewRenderer(options.DevServerURL, func(script string) (renderer.Renderer, error) {
+		return newRendererChecked(script, renderer.DefaultSSRScriptName, options)
+	})
+
+	pages := newPageHandler(options, fetcher)
+	// 开发模式下不缓存页面，避免源码修改后仍返回旧内容。
+	pages.cache = nil
+	pages.build.Store(newPageBuild("", dev, nil, options))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, DefaultSSRDataRoute) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if devProxyRequest(r) {
+			proxy.ServeHTTP(w, r)
+			return
+		}
+		fragment := strings.HasPrefix(r.URL.Path, DefaultSSRFragmentRoute+"/") && r.Method == http.MethodGet
+		if err := pages.syncDevBuild(r.Context(), dev); err != nil {
+			log.Printf("dev ssr unavailable, proxying path=%s err=%v", r.URL.Path, err)
+			if fragment {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			proxy.ServeHTTP(w, r)
+			return
+		}
+
+		if fragment {
+			pages.serveFragment(w, r)
+			return
+		}
+		pages.handle(w, r)
+	})
+}
+
+// syncDevBuild 让 dev 渲染器与 Vite 同步，index.html 变化时替换当前构建（重新解析 island 出口与资源提示）。
+// 新旧构建共用同一个 dev 渲染器，因此直接替换指针而不经 swapBuild 关闭旧构建。
+func (h *pageHandler) syncDevBuild(ctx context.Context, dev *vitedev.Renderer) error {
+	indexHTML, err := dev.Sync(ctx)
+	if err != nil {
+		return err
+	}
+	if h.build.Load().indexHTML != indexHTML {
+		h.build.Store(newPageBuild(indexHTML, dev, nil, h.options))
+	}
+	return nil
+}
+
+// devProxyRequest 判断开发模式下应直接代理给 Vite 的请求：HMR websocket、非 GET/HEAD 请求、
+// Vite 内部路径（/@vite/client、/@fs/、/@id/ 等）、node_modules 以及带扩展名的资源与源码模块。
+func devProxyRequest(req *http.Request) bool {
+	if strings.EqualFold(req.Header.Get("Upgrade"), "websocket") {
+		return true
+	}
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return true
+	}
+
+	p := req.URL.Path
+	if strings.HasPrefix(p, "/@") || strings.HasPrefix(p, "/node_modules/") || strings.HasPrefix(p, "/__") {
+		return true
+	}
+	return path.Ext(p) != ""
+}
@@ -0,0 +1,133 @@
+package gossr
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/daodao97/gossr/renderer/engine/vitedev"
+	"github.com/gin-gonic/gin"
+)
+
+func newViteStub(t *testing.T, withPlugin bool) *httptest.Server {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case withPlugin && r.URL.Path == vitedev.EntryPath:
+			w.Header().Set("ETag", `"1"`)
+			_, _ = w.Write([]byte(`globalThis.ssrRender = function(url) {
+				return { html: "<p>dev " + url + " " + __SSR_DATA__.user + "</p>", head: "<title>dev</title>" }
+			}`))
+		case withPlugin && r.URL.Path == vitedev.IndexPath:
+			w.Header().Set("ETag", `"1"`)
+			_, _ = w.Write([]byte(`<!doctype html><html><head><script type="module" src="/@vite/client"></script></head><body><!--app-html--></body></html>`))
+		default:
+			_, _ = w.Write([]byte("vite:" + r.URL.Path))
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// getDev 经真实的 HTTP server 请求，ReverseProxy 需要 ResponseRecorder 不支持的 CloseNotify。
+func getDev(t *testing.T, base, target string, setup func(*http.Request)) (int, string) {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodGet, base+target, nil)
+	if err != nil {
+		t.Fatalf("new request: %v", err)
+	}
+	if setup != nil {
+		setup(req)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("get %s: %v", target, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("read %s: %v", target, err)
+	}
+	return resp.StatusCode, string(body)
+}
+
+func TestRunBlockingDevSSR(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	vite := newViteStub(t, true)
+	router := gin.New()
+	RunBlocking(router, FrontendBuild{}, func(context.Context, *http.Request) (SSRPayload, error) {
+		return mapPayload{"user": "demo"}, nil
+	}, WithDevMode(true), WithDevServerURL(vite.URL), WithDevSSR(true))
+	srv := httptest.NewServer(router)
+	defer srv.Close()
+
+	code, body := getDev(t, srv.URL, "/hello?x=1", nil)
+	if code != http.StatusOK || !strings.Contains(body, "<p>dev /hello?x=1 demo</p>") {
+		t.Fatalf("expected page rendered through the vite entry, got %d %q", code, body)
+	}
+	if !strings.Contains(body, "<title>dev</title>") || !strings.Contains(body, "window.__SSR_DATA__") || !strings.Contains(body, "/@vite/client") {
+		t.Fatalf("expected production injection pipeline on the dev index.html, got %q", body)
+	}
+
+	for _, target := range []string{"/src/main.ts", "/@vite/client", "/node_modules/.vite/deps/vue.js"} {
+		if _, got := getDev(t, srv.URL, target, nil); got != "vite:"+target {
+			t.Fatalf("%s: expected proxy to vite, got %q", target, got)
+		}
+	}
+	_, ws := getDev(t, srv.URL, "/", func(req *http.Request) {
+		req.Header.Set("Connection", "Upgrade")
+		req.Header.Set("Upgrade", "websocket")
+	})
+	if ws != "vite:/" {
+		t.Fatalf("expected websocket upgrade to be proxied, got %q", ws)
+	}
+}
+
+func TestRunBlockingDevSSRFallsBackToProxy(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	vite := newViteStub(t, false)
+	router := gin.New()
+	RunBlocking(router, FrontendBuild{}, nil, WithDevMode(true), WithDevServerURL(vite.URL), WithDevSSR(true))
+	srv := httptest.NewServer(router)
+	defer srv.Close()
+
+	if _, got := getDev(t, srv.URL, "/hello", nil); got != "vite:/hello" {
+		t.Fatalf("expected proxy fallback without the vite plugin, got %q", got)
+	}
+}
+
+func TestRunBlockingDevSSRRendersIslands(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	vite := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"1"`)
+		switch r.URL.Path {
+		case vitedev.EntryPath:
+			_, _ = w.Write([]byte(`globalThis.ssrRender = function(url) { return "<p>dev " + url + "</p>" }
+globalThis.ssrRenderers = { header: function(url) { return "<nav>" + url + "</nav>" } }`))
+		case vitedev.IndexPath:
+			_, _ = w.Write([]byte(`<!doctype html><html><head></head><body><header><!--ssr:header--></header><!--app-html--></body></html>`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer vite.Close()
+
+	router := gin.New()
+	RunBlocking(router, FrontendBuild{}, nil, WithDevMode(true), WithDevServerURL(vite.URL), WithDevSSR(true))
+	srv := httptest.NewServer(router)
+	defer srv.Close()
+
+	code, body := getDev(t, srv.URL, "/shop", nil)
+	if code != http.StatusOK || !strings.Contains(body, "<header><nav>/shop</nav></header>") || !strings.Contains(body, "<p>dev /shop</p>") {
+		t.Fatalf("expected the island outlet rendered in dev mode, got %d %q", code, body)
+	}
+}
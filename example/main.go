@@ -1,11 +1,13 @@
 package main
 
 import (
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
+	"os"
 	"strings"
 	"time"
 
@@ -38,81 +40,87 @@ var localeMessages = mustLoadLocaleMessages()
 
 func init() {
 	registerLocalizedSSRRoute("/", homePayload)
-	registerLocalizedSSRRoute("/hi/:name", hiPayload)
+	registerLocalizedSSRRoute("/hi/{name}", hiPayload)
 	registerLocalizedSSRRoute("/seo-demo", seoDemoPayload)
 	registerLocalizedSSRRoute("/session-demo", sessionDemoPayload)
 	registerLocalizedSSRRoute("/slow-ssr", slowSSRPayload)
 	registerLocalizedSSRRoute("/slow-fetch", slowFetchPayload)
 }
 
-func homePayload(c *gin.Context) (gossr.SSRPayload, error) {
-	locale := localeFromRequestPath(c.Request.URL.Path)
+func homePayload(r *http.Request) (gossr.SSRPayload, error) {
+	locale := localeFromRequestPath(r.URL.Path)
 	message := localizedText(locale, "payload.home.message")
-	return buildPayload(c, message), nil
+	return buildPayload(r, message), nil
 }
 
-func hiPayload(c *gin.Context) (gossr.SSRPayload, error) {
-	locale := localeFromRequestPath(c.Request.URL.Path)
-	name := strings.TrimSpace(c.Param("name"))
+func hiPayload(r *http.Request) (gossr.SSRPayload, error) {
+	locale := localeFromRequestPath(r.URL.Path)
+	name := strings.TrimSpace(r.PathValue("name"))
 	if name == "" {
 		name = localizedText(locale, "payload.hi.friend")
 	}
 
-	title := strings.TrimSpace(c.Query("title"))
+	title := strings.TrimSpace(r.URL.Query().Get("title"))
 	if title != "" {
 		name = fmt.Sprintf("%s %s", title, name)
 	}
 
 	message := fmt.Sprintf(localizedText(locale, "payload.hi.template"), name)
-	return buildPayload(c, message), nil
+	return buildPayload(r, message), nil
 }
 
-func seoDemoPayload(c *gin.Context) (gossr.SSRPayload, error) {
-	locale := localeFromRequestPath(c.Request.URL.Path)
+func seoDemoPayload(r *http.Request) (gossr.SSRPayload, error) {
+	locale := localeFromRequestPath(r.URL.Path)
 	message := localizedText(locale, "payload.seo.message")
-	return buildPayload(c, message), nil
+	return buildPayload(r, message), nil
 }
 
-func sessionDemoPayload(c *gin.Context) (gossr.SSRPayload, error) {
-	locale := localeFromRequestPath(c.Request.URL.Path)
+func sessionDemoPayload(r *http.Request) (gossr.SSRPayload, error) {
+	locale := localeFromRequestPath(r.URL.Path)
 	message := localizedText(locale, "payload.session.message")
-	return buildPayload(c, message), nil
+	return buildPayload(r, message), nil
 }
 
-func slowSSRPayload(c *gin.Context) (gossr.SSRPayload, error) {
-	locale := localeFromRequestPath(c.Request.URL.Path)
+func slowSSRPayload(r *http.Request) (gossr.SSRPayload, error) {
+	locale := localeFromRequestPath(r.URL.Path)
 	message := localizedText(locale, "payload.slowSsr.message")
-	return buildPayload(c, message), nil
+	return buildPayload(r, message), nil
 }
 
-func slowFetchPayload(c *gin.Context) (gossr.SSRPayload, error) {
+func slowFetchPayload(r *http.Request) (gossr.SSRPayload, error) {
 	// 模拟 _ssr/data 慢查询：只延迟数据阶段，不影响 SSR 渲染阶段逻辑。
 	select {
 	case <-time.After(3500 * time.Millisecond):
-	case <-c.Request.Context().Done():
-		return nil, c.Request.Context().Err()
+	case <-r.Context().Done():
+		return nil, r.Context().Err()
 	}
 
-	locale := localeFromRequestPath(c.Request.URL.Path)
+	locale := localeFromRequestPath(r.URL.Path)
 	message := localizedText(locale, "payload.slowFetch.message")
-	return buildPayload(c, message), nil
+	return buildPayload(r, message), nil
 }
 
-func buildPayload(c *gin.Context, message string) greetingPayload {
-	locale := localeFromRequestPath(c.Request.URL.Path)
+func buildPayload(r *http.Request, message string) greetingPayload {
+	locale := localeFromRequestPath(r.URL.Path)
 	return greetingPayload{
 		Message:     message,
 		Locale:      locale,
-		Path:        c.Request.URL.Path,
-		Query:       c.Request.URL.RawQuery,
+		Path:        r.URL.Path,
+		Query:       r.URL.RawQuery,
 		GeneratedAt: time.Now().Format(time.RFC3339),
 	}
 }
 
-func registerLocalizedSSRRoute(basePath string, handler func(*gin.Context) (gossr.SSRPayload, error)) {
-	gossr.SsrEngine.GET(basePath, gossr.WrapSSR(handler))
+// registerLocalizedSSRRoute 用标准库 ServeMux 模式注册数据路由及其各 locale 前缀版本。
+func registerLocalizedSSRRoute(basePath string, handler gossr.DataHandler) {
+	if basePath == "/" {
+		// ServeMux 中 "/" 匹配所有路径，首页需用 "/{$}" 精确匹配。
+		gossr.HandleData("GET /{$}", handler)
+	} else {
+		gossr.HandleData("GET "+basePath, handler)
+	}
 	for _, locale := range demoLocales {
-		gossr.SsrEngine.GET(localizedRoutePath(locale, basePath), gossr.WrapSSR(handler))
+		gossr.HandleData("GET "+localizedRoutePath(locale, basePath), handler)
 	}
 }
 
@@ -158,11 +166,24 @@ func mustLoadLocaleMessages() *web.LocaleMessages {
 }
 
 func main() {
+	// go run . prerender -out prerender：把无需实时数据的页面预渲染为静态 HTML，
+	// 运行时设置 SSR_PRERENDER_DIR=prerender 优先返回这些页面。
+	if len(os.Args) > 1 && os.Args[1] == "prerender" {
+		build, err := gossr.LoadFS(web.Dist, gossr.Layout{ClientDir: "dist/client", ServerDir: "dist/server"})
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := gossr.PrerenderCommand(context.Background(), os.Args[2:], build, gossr.WithOptions(gossr.OptionsFromEnv())); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	router := gin.New()
 	router.Use(gin.Logger(), gin.Recovery())
 	registerSessionDemoRoutes(router)
 
-	if err := gossr.Ssr(router, web.Dist); err != nil {
+	if err := gossr.Ssr(router, web.Dist, gossr.WithOptions(gossr.OptionsFromEnv())); err != nil {
 		log.Fatal(err)
 	}
 
@@ -0,0 +1,124 @@
+import fs from 'node:fs/promises'
+import path from 'node:path'
+
+import { build } from 'vite'
+import type { Plugin, ViteDevServer } from 'vite'
+
+export interface GossrDevOptions {
+  // 打包 SSR 入口使用的配置文件，需与生产构建 server.js 保持一致
+  configFile?: string
+}
+
+interface BuiltEntry {
+  code: string
+  // 参与打包的源文件（绝对路径），只有它们变化时入口才会过期
+  files: Set<string>
+}
+
+// gossr-dev 在 Vite dev server 上暴露 /__gossr/server.js 与 /__gossr/index.html，
+// 供 Go 侧（gossr.WithDevSSR / DEV_SSR=1）获取经插件链转换后的 SSR 入口并在 goja/v8 中执行。
+//
+// 入口按"变更后重新打包"的方式生成：goja/v8 需要单文件脚本，而 server.ssrLoadModule 在 Node 中逐模块执行，
+// 无法直接复用，因此 SSR 模块图内的文件变化后，下次请求用 vite build（write: false）重新打包整个入口。
+// 两个端点以 ETag 标记源码版本，只有 SSR 模块图内的文件、index.html 与打包配置变化时版本才递增，Go 侧据此重新编译。
+export default function gossrDev(options: GossrDevOptions = {}): Plugin {
+  const configFile = options.configFile ?? 'vite.config.server.ts'
+  let version = 1
+  let entry: { version: number, built: Promise<BuiltEntry> } | undefined
+  // 最近一次成功打包的模块图；尚未成功或上次打包失败时为 undefined，任意变化都视为过期
+  let graph: Set<string> | undefined
+
+  const rebuildEntry = async (server: ViteDevServer): Promise<BuiltEntry> => {
+    const output = await build({
+      root: server.config.root,
+      configFile: path.resolve(server.config.root, configFile),
+      mode: server.config.mode,
+      logLevel: 'warn',
+      build: {
+        write: false,
+        watch: null,
+        emptyOutDir: false,
+        minify: false,
+      },
+    })
+
+    let code: string | undefined
+    const files = new Set<string>()
+    for (const result of Array.isArray(output) ? output : [output]) {
+      if (!('output' in result))
+        continue
+      for (const item of result.output) {
+        if (item.type !== 'chunk')
+          continue
+        if (item.isEntry && code === undefined)
+          code = item.code
+        for (const id of item.moduleIds) {
+          // 跳过 \0 开头的虚拟模块，去掉 ?vue&type=... 等查询参数
+          if (!id.startsWith('\0'))
+            files.add(path.normalize(id.split('?')[0]))
+        }
+      }
+    }
+    if (code === undefined)
+      throw new Error('gossr-dev: ssr entry chunk not found')
+    return { code, files }
+  }
+
+  return {
+    name: 'gossr-dev',
+    apply: 'serve',
+    configureServer(server) {
+      const root = server.config.root
+      const alwaysWatched = new Set([
+        path.normalize(path.resolve(root, 'index.html')),
+        path.normalize(path.resolve(root, configFile)),
+      ])
+
+      // 只有 SSR 模块图内的文件变化才使入口过期；新增文件要被已有模块 import 后才会进入模块图
+      server.watcher.on('all', (_event, file) => {
+        const changed = path.normalize(path.resolve(root, file))
+        if (!graph || graph.has(changed) || alwaysWatched.has(changed))
+          version++
+      })
+
+      server.middlewares.use('/__gossr/server.js', async (req, res) => {
+        if (req.headers['if-none-match'] === `"${version}"`) {
+          res.statusCode = 304
+          res.end()
+          return
+        }
+
+        try {
+          if (!entry || entry.version !== version) {
+            const built = rebuildEntry(server)
+            entry = { version, built }
+            // 打包失败时出错的文件可能不在旧模块图中，退回为任意变化都重新打包
+            built.then(({ files }) => {
+              graph = files
+            }, () => {
+              graph = undefined
+            })
+          }
+          const { version: builtVersion, built } = entry
+          const { code } = await built
+          res.setHeader('Content-Type', 'text/javascript; charset=utf-8')
+          res.setHeader('ETag', `"${builtVersion}"`)
+          res.end(code)
+        }
+        catch (err) {
+          entry = undefined
+          server.config.logger.error(`gossr-dev: ${String(err)}`)
+          res.statusCode = 500
+          res.end(String(err))
+        }
+      })
+
+      server.middlewares.use('/__gossr/index.html', async (_req, res) => {
+        const raw = await fs.readFile(path.resolve(root, 'index.html'), 'utf-8')
+        res.setHeader('Content-Type', 'text/html; charset=utf-8')
+        res.setHeader('ETag', `"${version}"`)
+        res.end(await server.transformIndexHtml('/', raw))
+      })
+    },
+  }
+}
@@ -1,14 +1,42 @@
-import { renderToString } from '@vue/server-renderer'
+import { renderToSimpleStream, renderToString } from '@vue/server-renderer'
 
 import { makeApp } from '~/main'
 import type { SsrState } from '~/composables/useSsrData'
 
-export async function render(url: string) {
+export interface SsrRequest {
+  id: string
+  url: string
+  path: string
+  query: string
+  method: string
+  headers: Record<string, string>
+  cookies: Record<string, string>
+  locale: string
+  origin: string
+  clientIP: string
+}
+
+export interface SsrRenderResult {
+  html: string
+  head: string
+  status?: number
+  redirect?: string
+  // 渲染用到的模块 ID，Go 侧据此按 ssr-manifest 注入 modulepreload/stylesheet
+  modules?: string[]
+}
+
+// url 为完整的请求 URI（含 query），保证 router.push 与客户端 hydration 一致
+export async function render(url: string, _request?: SsrRequest): Promise<SsrRenderResult> {
   const initialState: SsrState = (globalThis as any).__SSR_DATA__ ?? {}
   const { app, router } = makeApp(initialState)
   await router.push(url)
   await router.isReady()
 
+  // 路由守卫（如 requiresAuth）发生重定向时交给 Go 侧返回 302
+  const resolved = router.currentRoute.value
+  if (resolved.fullPath !== url && resolved.redirectedFrom)
+    return { html: '', head: '', redirect: resolved.fullPath }
+
   if (shouldSimulateSlowSSR(url))
     await sleep(3500)
 
@@ -19,14 +47,38 @@ export async function render(url: string) {
   const head = typeof ctx.teleports?.head === 'string' ? ctx.teleports.head : ''
   ;(globalThis as any).__SSR_HEAD__ = head
 
-  return html
+  const notFound = resolved.matched.some(record => record.meta.layout === 'not-found')
+  const modules = ctx.modules ? [...ctx.modules] : []
+  return { html, head, status: notFound ? 404 : 200, modules }
+}
+
+async function ssrRender(url: string, request?: SsrRequest) {
+  return await render(url, request)
 }
 
-async function ssrRender(url: string) {
-  return await render(url)
+// 开启 WithStreaming 后由 Go 侧调用：HTML 分块通过 write 输出。
+// 响应头此时已发送，重定向与状态码不再生效，需要它们的页面应保持缓冲渲染。
+async function ssrRenderStream(url: string, _request: SsrRequest | undefined, write: (chunk: string) => void) {
+  const initialState: SsrState = (globalThis as any).__SSR_DATA__ ?? {}
+  const { app, router } = makeApp(initialState)
+  await router.push(url)
+  await router.isReady()
+
+  await new Promise<void>((resolve, reject) => {
+    renderToSimpleStream(app, {}, {
+      push(chunk) {
+        if (chunk === null)
+          resolve()
+        else
+          write(chunk)
+      },
+      destroy: reject,
+    })
+  })
 }
 
 (globalThis as any).ssrRender = ssrRender
+;(globalThis as any).ssrRenderStream = ssrRenderStream
 
 function shouldSimulateSlowSSR(rawURL: string): boolean {
   const pathname = rawURL.split('#')[0].split('?')[0]
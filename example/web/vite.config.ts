@@ -4,6 +4,8 @@ import { defineConfig } from 'vite'
 import vue from '@vitejs/plugin-vue'
 import vueRouter from 'vue-router/vite'
 
+import gossrDev from './plugins/gossr-dev'
+
 export default defineConfig(({ command }) => ({
   plugins: [
     vueRouter({
@@ -12,6 +14,8 @@ export default defineConfig(({ command }) => ({
       watch: command === 'serve',
     }),
     vue(),
+    // DEV_SSR=1 时 Go 侧经由该插件获取 SSR 入口，在开发模式下执行真实的 SSR
+    gossrDev(),
   ],
   resolve: {
     alias: {
@@ -0,0 +1,154 @@
+package gossr
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/daodao97/gossr/renderer"
+)
+
+const maxFetchRedirects = 10
+
+// FetchOptions 配置 SSR 脚本中的 fetch()。
+// 同源的 /_ssr/data 请求始终在进程内经 SsrEngine 处理，不经过网络；其余地址需命中 AllowedHosts。
+type FetchOptions struct {
+	// Transport 访问外部地址使用的 RoundTripper，nil 时使用 http.DefaultTransport。
+	Transport http.RoundTripper
+	// AllowedHosts 允许访问的外部 host（如 api.example.com、*.example.com、127.0.0.1:8080），为空时禁止外部请求。
+	AllowedHosts []string
+}
+
+// WithFetch 设置 SSR 脚本中 fetch() 的外部访问配置。
+func WithFetch(fetch FetchOptions) Option {
+	return func(opts *Options) {
+		opts.Fetch = fetch
+	}
+}
+
+// ssrFetcher 为每次渲染构造 renderer.FetchFunc。
+type ssrFetcher struct {
+	options Options
+	client  *http.Client
+	allowed []string
+}
+
+func newSSRFetcher(options Options) *ssrFetcher {
+	f := &ssrFetcher{options: options}
+	for _, host := range options.Fetch.AllowedHosts {
+		if host = strings.ToLower(strings.TrimSpace(host)); host != "" {
+			f.allowed = append(f.allowed, host)
+		}
+	}
+
+	transport := options.Fetch.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	f.client = &http.Client{
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxFetchRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxFetchRedirects)
+			}
+			if !f.allows(req.URL) {
+				return fmt.Errorf("redirect to %s is not allowed", req.URL.Host)
+			}
+			return nil
+		},
+	}
+
+	return f
+}
+
+// forRequest 返回绑定到当前页面请求的 FetchFunc，同源数据请求会沿用页面请求的 cookie 等头部。
+func (f *ssrFetcher) forRequest(source *http.Request) renderer.FetchFunc {
+	return func(req *http.Request) (*http.Response, error) {
+		if f.isSSRDataRequest(source, req.URL) {
+			return f.resolveSSRData(source, req)
+		}
+		if !f.allows(req.URL) {
+			return nil, fmt.Errorf("fetch to %s is not allowed", req.URL.Host)
+		}
+		return f.client.Do(req)
+	}
+}
+
+func (f *ssrFetcher) isSSRDataRequest(source *http.Request, target *url.URL) bool {
+	p := ssrDataPath(target.Path, f.options.BasePath)
+	if p != DefaultSSRDataRoute && !strings.HasPrefix(p, DefaultSSRDataRoute+"/") {
+		return false
+	}
+
+	origin, err := url.Parse(requestOrigin(source, f.options.TrustForwardedHeaders))
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(origin.Host, target.Host)
+}
+
+func (f *ssrFetcher) allows(target *url.URL) bool {
+	if target.Scheme != "http" && target.Scheme != "https" {
+		return false
+	}
+
+	host := strings.ToLower(target.Host)
+	hostname := strings.ToLower(target.Hostname())
+	for _, pattern := range f.allowed {
+		if pattern == host || pattern == hostname {
+			return true
+		}
+		if suffix, ok := strings.CutPrefix(pattern, "*"); ok && strings.HasPrefix(suffix, ".") && strings.HasSuffix(hostname, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveSSRData 在进程内处理 /_ssr/data 请求，响应与浏览器直接请求时一致。
+func (f *ssrFetcher) resolveSSRData(source *http.Request, req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return fetchResponse(req, http.StatusMethodNotAllowed, nil), nil
+	}
+
+	inner := source.Clone(req.Context())
+	for name, values := range req.Header {
+		inner.Header[name] = values
+	}
+
+	requestPath := strings.TrimPrefix(ssrDataPath(req.URL.Path, f.options.BasePath), DefaultSSRDataRoute)
+	ctx := contextWithOptions(req.Context(), f.options)
+	status, body := serveSSRData(ctx, inner, requestPath, req.URL.RawQuery, f.options)
+	if err := req.Context().Err(); err != nil {
+		return nil, err
+	}
+	return fetchResponse(req, status, body), nil
+}
+
+// ssrDataPath 去掉应用的路径前缀：BasePath 下的脚本可请求 /admin/_ssr/data/...，也可省略前缀。
+func ssrDataPath(p, basePath string) string {
+	if basePath == "" {
+		return p
+	}
+	if rest, ok := trimBasePath(p, basePath); ok {
+		return rest
+	}
+	return p
+}
+
+func fetchResponse(req *http.Request, status int, body []byte) *http.Response {
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": []string{"application/json; charset=utf-8"}},
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
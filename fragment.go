@@ -0,0 +1,101 @@
+package gossr
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/daodao97/gossr/renderer"
+)
+
+// DefaultSSRFragmentRoute 为片段接口前缀：GET /_ssr/fragment/cart?page=2 按页面 /cart?page=2
+// 取数并渲染，只返回 ssrRender 的 HTML，不含 index.html 外壳，适合 HTMX 等局部更新。
+const DefaultSSRFragmentRoute = "/_ssr/fragment"
+
+// serveFragment 处理片段请求：访问校验与 /_ssr/data 相同，取数、并发名额与超时与页面渲染相同，
+// 渲染时 request.fragment 为 true。
+func (h *pageHandler) serveFragment(w http.ResponseWriter, r *http.Request) {
+	if code, ok := authorizeSSRFetch(r, h.options); !ok {
+		w.WriteHeader(code)
+		return
+	}
+
+	pageReq := fragmentPageRequest(r)
+	if h.route(pageReq).ClientOnly {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	w, pageReq, endSpan := h.startPageSpan(w, pageReq)
+	defer endSpan()
+
+	resp := h.renderFragment(pageReq)
+	// HTMX 的 XHR 会透明跟随 3xx 并把整页替换进片段位置，改用 HX-Redirect 让其整页跳转。
+	if resp.redirect != "" && r.Header.Get("HX-Request") == "true" {
+		applyResultHeaders(w.Header(), resp.header)
+		setHTMLNoCacheHeaders(w.Header())
+		w.Header().Set("HX-Redirect", withBasePath(h.options.BasePath, resp.redirect))
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	// 空片段（含失败时的 500）也要带上脚本声明的响应头与 X-SSR-Error-Id。
+	if resp.body == "" && resp.redirect == "" {
+		applyResultHeaders(w.Header(), resp.header)
+		setHTMLNoCacheHeaders(w.Header())
+		w.WriteHeader(resp.status)
+		return
+	}
+	h.writePage(w, pageReq, resp)
+}
+
+// renderFragment 取数并调用 ssrRender，返回不含外壳的 HTML；失败时返回 500 并在
+// X-SSR-Error-Id 中带上与日志一致的请求 ID。
+func (h *pageHandler) renderFragment(req *http.Request) pageResponse {
+	b := h.acquireBuild()
+	defer b.release()
+
+	reqID := newRequestID()
+	payloadMap, err := h.loadPayload(req)
+	if err != nil {
+		log.Printf("ssr fragment data failed id=%s path=%s err=%v", reqID, req.URL.Path, err)
+		return pageResponse{status: http.StatusInternalServerError, header: http.Header{"X-Ssr-Error-Id": {reqID}}}
+	}
+
+	ctx := h.renderContext(req, reqID)
+	if renderReq := renderer.RequestFromContext(ctx); renderReq != nil {
+		renderReq.Fragment = true
+	}
+	urlPath := req.URL.RequestURI()
+	result, err := h.runRender(ctx, h.renderTimeout(req), func(ctx context.Context) (renderer.Result, error) {
+		return b.ssr.Render(ctx, urlPath, payloadMap)
+	})
+	if err != nil {
+		log.Printf("ssr fragment render failed id=%s path=%s err=%v", reqID, req.URL.Path, err)
+		return pageResponse{status: http.StatusInternalServerError, header: http.Header{"X-Ssr-Error-Id": {reqID}}}
+	}
+
+	if result.Redirect != "" {
+		return pageResponse{
+			status:   redirectStatus(result.Status),
+			header:   result.Headers,
+			redirect: result.Redirect,
+		}
+	}
+	return pageResponse{
+		status: pageStatus(result.Status),
+		header: result.Headers,
+		body:   result.HTML,
+	}
+}
+
+// fragmentPageRequest 把片段请求改写为对应页面的请求，保留 header、cookie 与 query。
+func fragmentPageRequest(r *http.Request) *http.Request {
+	pagePath := path.Clean("/" + strings.TrimPrefix(r.URL.Path, DefaultSSRFragmentRoute))
+	pageReq := r.Clone(r.Context())
+	pageReq.URL.Path = pagePath
+	pageReq.URL.RawPath = ""
+	pageReq.RequestURI = pageReq.URL.RequestURI()
+	return pageReq
+}
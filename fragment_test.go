@@ -0,0 +1,66 @@
+package gossr
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestFragmentEndpoint(t *testing.T) {
+	withTestDataMux(t, func(*http.ServeMux) {
+		HandleData("GET /hi/{name}", func(r *http.Request) (SSRPayload, error) {
+			return mapPayload{"message": "hello " + r.PathValue("name")}, nil
+		})
+	})
+
+	build := testBuild(`globalThis.ssrRender = function(url, request) {
+		if (url === "/login") return { html: "", redirect: "/signin" }
+		if (url === "/broken") throw new Error("boom")
+		if (url === "/empty") return { html: "", headers: { "HX-Trigger": "cleared" } }
+		return (request.fragment ? "fragment:" : "page:") + url + "|" + (__SSR_DATA__.message || "none")
+	}`, nil)
+	server, err := NewHandler(build, Route("/private", ClientOnly()))
+	if err != nil {
+		t.Fatalf("new handler: %v", err)
+	}
+	sameOrigin := func(req *http.Request) {
+		req.Header.Set("Referer", "http://example.com/hi/bob")
+	}
+
+	w := performRequest(server, http.MethodGet, DefaultSSRFragmentRoute+"/hi/bob?tab=2", sameOrigin)
+	if w.Code != http.StatusOK || w.Body.String() != "fragment:/hi/bob?tab=2|hello bob" {
+		t.Fatalf("expected fragment html without shell, got %d %q", w.Code, w.Body.String())
+	}
+	if got := w.Header().Get("Cache-Control"); got != cacheNoStoreHTML {
+		t.Fatalf("expected fragment not to be cached, got %q", got)
+	}
+	if body := performRequest(server, http.MethodGet, "/hi/bob", nil).Body.String(); !strings.Contains(body, "page:/hi/bob|hello bob") {
+		t.Fatalf("expected page render without fragment flag, got %q", body)
+	}
+
+	if w := performRequest(server, http.MethodGet, DefaultSSRFragmentRoute+"/hi/bob", nil); w.Code != http.StatusForbidden {
+		t.Fatalf("expected cross-origin fragment request to be rejected, got %d", w.Code)
+	}
+	if w := performRequest(server, http.MethodGet, DefaultSSRFragmentRoute+"/private", sameOrigin); w.Code != http.StatusNotFound {
+		t.Fatalf("expected ClientOnly route to have no fragment, got %d", w.Code)
+	}
+
+	if w := performRequest(server, http.MethodGet, DefaultSSRFragmentRoute+"/login", sameOrigin); w.Code != http.StatusFound || w.Header().Get("Location") != "/signin" {
+		t.Fatalf("expected redirect, got %d %q", w.Code, w.Header().Get("Location"))
+	}
+	w = performRequest(server, http.MethodGet, DefaultSSRFragmentRoute+"/login", func(req *http.Request) {
+		sameOrigin(req)
+		req.Header.Set("HX-Request", "true")
+	})
+	if w.Code != http.StatusOK || w.Header().Get("HX-Redirect") != "/signin" {
+		t.Fatalf("expected HX-Redirect for htmx requests, got %d %q", w.Code, w.Header().Get("HX-Redirect"))
+	}
+
+	if w := performRequest(server, http.MethodGet, DefaultSSRFragmentRoute+"/empty", sameOrigin); w.Code != http.StatusOK || w.Body.Len() != 0 || w.Header().Get("HX-Trigger") != "cleared" {
+		t.Fatalf("expected empty fragment with script headers, got %d %q %v", w.Code, w.Body.String(), w.Header())
+	}
+	w = performRequest(server, http.MethodGet, DefaultSSRFragmentRoute+"/broken", sameOrigin)
+	if w.Code != http.StatusInternalServerError || w.Header().Get("X-SSR-Error-Id") == "" || strings.Contains(w.Body.String(), "<html") {
+		t.Fatalf("expected failed fragment to return 500 without fallback shell, got %d %q", w.Code, w.Body.String())
+	}
+}
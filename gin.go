@@ -0,0 +1,126 @@
+package gossr
+
+import (
+	"io/fs"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Gin 集成：SSR 流程由 Server（http.Handler）实现，这里只负责把它挂到 gin.Engine 上，
+// 并保留基于 gin 的数据路由写法（SsrEngine、WrapSSR、Router）。
+
+// SsrEngine 默认应用的 gin 引擎，用于 SSR 数据路由；DataMux 未匹配的请求交给它处理。
+// 独立应用使用 App.Engine，见 New。
+var SsrEngine *gin.Engine
+
+func init() {
+	SsrEngine = newDataEngine()
+}
+
+// newDataEngine 创建数据路由使用的 gin 引擎，带数据耗时指标与 panic 恢复。
+func newDataEngine() *gin.Engine {
+	engine := gin.New()
+	engine.Use(ssrDataMetrics(), gin.Recovery())
+	return engine
+}
+
+// WrapSSR 包装 SSR handler 为 gin handler，输出与 WrapData 一致。
+func WrapSSR(h func(*gin.Context) (SSRPayload, error)) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		WrapData(func(*http.Request) (SSRPayload, error) {
+			return h(c)
+		}).ServeHTTP(c.Writer, c.Request)
+	}
+}
+
+// Router 挂载 SSR 路由到外部 gin group（供客户端 fetch 调用），等价于挂在 group 前缀下的 DataRouter。
+func Router(group *gin.RouterGroup, opts ...Option) {
+	data := DataRouter(opts...)
+	group.GET("/*path", func(c *gin.Context) {
+		req := new(http.Request)
+		*req = *c.Request
+		u := *c.Request.URL
+		u.Path = c.Param("path")
+		req.URL = &u
+		data.ServeHTTP(c.Writer, req)
+	})
+}
+
+// Ssr 从 embed 产物（dist/client + dist/server）挂载 SSR 数据路由与页面渲染。
+func Ssr(r *gin.Engine, dist fs.FS, opts ...Option) error {
+	_, err := Mount(r, dist, opts...)
+	return err
+}
+
+// Mount 与 Ssr 相同，额外返回 Server，用于之后通过 SwapBuild 替换构建。
+func Mount(r *gin.Engine, dist fs.FS, opts ...Option) (*Server, error) {
+	build, err := LoadFS(dist, Layout{ClientDir: "dist/client", ServerDir: "dist/server"})
+	// 开发模式代理到 Vite，不读取 embed 产物（此时通常只有占位文件）。
+	if err != nil && !newOptions(opts...).DevMode {
+		return nil, err
+	}
+	return MountBuild(r, build, opts...)
+}
+
+// MountBuild 挂载 SSR 数据路由与页面渲染，构建产物可来自 LoadFS、LoadDir 或 LoadArchive。
+// 与 RunBlocking 不同，构建产物无法加载时返回错误而不是 panic。
+func MountBuild(r *gin.Engine, build FrontendBuild, opts ...Option) (*Server, error) {
+	server, err := NewHandler(build, opts...)
+	if err != nil {
+		return nil, err
+	}
+	r.NoRoute(ginHandler(server))
+	return server, nil
+}
+
+// RunBlocking 挂载 SSR 页面流程（NoRoute）与静态资源路由。
+// 未传入 Option 时使用 DefaultOptions，不读取环境变量；需要兼容旧的环境变量配置时
+// 可传入 WithOptions(OptionsFromEnv())。返回的 Server 可用于运行时替换构建（SwapBuild）。
+// 构建产物无法加载时 panic，需要以错误返回时使用 MountBuild。
+func RunBlocking(router *gin.Engine, frontendBuild FrontendBuild, fetcher BackendDataFetcher, opts ...Option) *Server {
+	server, err := newServer(frontendBuild, fetcher, nil, newOptions(opts...))
+	if err != nil {
+		panic(err)
+	}
+	router.NoRoute(ginHandler(server))
+	return server
+}
+
+// MountApps 把多个 App 挂到同一个 gin.Engine 的 NoRoute，apps 的 key 为 http.ServeMux 模式：
+// 路径前缀（"/admin/"，对应 WithBasePath("/admin")）、host（"admin.example.com/"）或兜底的 "/"。
+func MountApps(r *gin.Engine, apps map[string]*App) {
+	mux := http.NewServeMux()
+	for pattern, app := range apps {
+		mux.Handle(pattern, app)
+	}
+	r.NoRoute(ginHandler(mux))
+}
+
+// ginHandler 把 http.Handler 挂到 NoRoute：gin 进入 NoRoute 时已预置 404，
+// 先恢复为 200，使未显式 WriteHeader 的响应与 net/http 行为一致。
+func ginHandler(h http.Handler) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Status(http.StatusOK)
+		h.ServeHTTP(c.Writer, c.Request)
+	}
+}
+
+// ssrDataMetrics 记录 SsrEngine 数据 handler 的耗时，指标接收器来自请求 ctx 上的 Options。
+func ssrDataMetrics() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		m := optionsFromContext(c.Request.Context()).Metrics
+		if m == nil {
+			return
+		}
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		m.ObserveDataFetch(route, c.Writer.Status(), time.Since(start))
+	}
+}
@@ -0,0 +1,244 @@
+package gossr
+
+import (
+	"fmt"
+	"io/fs"
+	"log"
+	"net/http"
+	"net/http/pprof"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+)
+
+// Server 是完整的 SSR http.Handler：/_ssr/data 数据路由、/_ssr/fragment 片段接口、/assets 与根目录静态文件、页面渲染。
+// 可直接挂到 net/http、chi 等路由上，Gin 集成（Ssr/Mount/RunBlocking）只是把它挂到 NoRoute。
+type Server struct {
+	mux *http.ServeMux
+	// basePath 非空时请求先去掉该前缀再路由，见 WithBasePath。
+	basePath string
+	pages    *pageHandler
+	assets   *assetLayers
+
+	// swapMu 串行化 SwapBuild，避免并发替换时资源层与渲染器顺序不一致。
+	swapMu sync.Mutex
+
+	// done 在 Close 时关闭，通知 WithWatch 的轮询 goroutine 退出。
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// Close 停止 Server 的后台任务（WithWatch 的轮询），之后不再热重载；已挂载的路由仍可继续处理请求。
+func (s *Server) Close() error {
+	s.closeOnce.Do(func() {
+		if s.done != nil {
+			close(s.done)
+		}
+	})
+	return nil
+}
+
+// NewHandler 创建不依赖 Gin 的 SSR Handler，数据接口（/_ssr/data）由 HandleData/DataMux 注册的路由提供，
+// 构建产物无法加载时返回错误。
+//
+//	server, err := gossr.NewHandler(build, gossr.WithRenderTimeout(5*time.Second))
+//	mux.Handle("/", server)
+func NewHandler(build FrontendBuild, opts ...Option) (*Server, error) {
+	options := newOptions(opts...)
+	return newServer(build, dataFetcher(options), newDataHandler(options), options)
+}
+
+// ServeHTTP 实现 http.Handler。
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.basePath != "" {
+		stripped, ok := stripBasePath(r, s.basePath)
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		r = stripped
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+// stripBasePath 返回去掉路径前缀后的请求副本，路径不在前缀下时返回 false；前缀本身对应 "/"。
+func stripBasePath(r *http.Request, basePath string) (*http.Request, bool) {
+	p, ok := trimBasePath(r.URL.Path, basePath)
+	if !ok {
+		return nil, false
+	}
+	rp, _ := trimBasePath(r.URL.RawPath, basePath)
+
+	r2 := new(http.Request)
+	*r2 = *r
+	r2.URL = new(url.URL)
+	*r2.URL = *r.URL
+	r2.URL.Path = p
+	r2.URL.RawPath = rp
+	return r2, true
+}
+
+// trimBasePath 去掉 p 的路径前缀 basePath，p 不在前缀下时返回 false。
+func trimBasePath(p, basePath string) (string, bool) {
+	rest, ok := strings.CutPrefix(p, basePath)
+	if !ok || (rest != "" && rest[0] != '/') {
+		return "", false
+	}
+	if rest == "" {
+		rest = "/"
+	}
+	return rest, true
+}
+
+// withBasePath 为站内绝对路径补上 BasePath，外部地址与协议相对地址（//host）保持不变。
+func withBasePath(basePath, location string) string {
+	if basePath == "" || !strings.HasPrefix(location, "/") || strings.HasPrefix(location, "//") {
+		return location
+	}
+	return basePath + location
+}
+
+// newServer 组装 SSR Handler；data 为 nil 时不提供 /_ssr/data（RunBlocking 自带 fetcher 的场景）。
+func newServer(frontendBuild FrontendBuild, fetcher BackendDataFetcher, data http.Handler, options Options) (*Server, error) {
+	warnIgnoredEnv(options)
+	mux := http.NewServeMux()
+	registerPprof(mux, options.EnablePprof)
+	mux.HandleFunc("GET /i/{invite_code}", func(w http.ResponseWriter, r *http.Request) {
+		serveInvite(w, r, options.BasePath)
+	})
+	if data != nil {
+		mux.Handle("GET "+DefaultSSRDataRoute+"/", data)
+	}
+
+	if options.DevMode {
+		// Vite 使用 base 配置时同样带着前缀，代理时补回 BasePath。
+		proxy := newDevProxy(strings.TrimRight(options.DevServerURL, "/") + options.BasePath)
+		if options.DevSSR {
+			log.Printf("Development mode enabled. Rendering SSR through %s", options.DevServerURL)
+			mux.Handle("/", newDevSSRHandler(proxy, fetcher, options))
+			return &Server{mux: mux, basePath: options.BasePath}, nil
+		}
+		log.Printf("Development mode enabled. Proxying to %s", options.DevServerURL)
+		mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+			if strings.HasPrefix(r.URL.Path, DefaultSSRDataRoute) || strings.HasPrefix(r.URL.Path, DefaultSSRFragmentRoute) {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+
+			proxy.ServeHTTP(w, r)
+		})
+		return &Server{mux: mux, basePath: options.BasePath}, nil
+	}
+
+	var fingerprint string
+	if options.WatchDir != "" {
+		frontendBuild = layoutDirBuild(options.WatchDir, options.WatchLayout)
+		fingerprint = buildFingerprint(options.WatchDir, options.WatchLayout)
+	}
+	build, err := loadPageBuild(frontendBuild, options)
+	if err != nil {
+		return nil, err
+	}
+	prewarmRenderer(build.ssr)
+
+	// 静态资源经 assetLayers 读取，SwapBuild 后旧构建的资源在宽限期内仍可访问。
+	dist := newAssetLayers(frontendBuild.FrontendDist, options.AssetGracePeriod)
+	assetsFS, err := fs.Sub(dist, "assets")
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare assets filesystem: %w", err)
+	}
+
+	pages := newPageHandler(options, fetcher)
+	pages.registerPool(build)
+	pages.build.Store(build)
+	pages.initialBuild = build.version
+	server := &Server{mux: mux, basePath: options.BasePath, pages: pages, assets: dist, done: make(chan struct{})}
+	if options.WatchDir != "" {
+		go pages.watch(options.WatchDir, options.WatchLayout, frontendBuild, fingerprint, server.done)
+	}
+	// /assets 目录使用长期缓存（文件名带 hash）
+	mux.Handle("GET /assets/", assetsHandler(assetsFS, pages.handle))
+	mux.HandleFunc("GET "+DefaultSSRFragmentRoute+"/", pages.serveFragment)
+	if pages.isr != nil && pages.isr.token != "" {
+		mux.HandleFunc("POST "+DefaultSSRRevalidateRoute, pages.serveRevalidate)
+	}
+	mux.HandleFunc("/", server.handle)
+	return server, nil
+}
+
+// handle 先尝试根目录静态文件（favicon 等，短期缓存），未命中时进入页面流程。
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	if serveRootFile(w, r, s.assets) {
+		return
+	}
+	s.pages.handle(w, r)
+}
+
+// serveRootFile 提供构建根目录下的单层文件（不含 index.html），命中时返回 true。
+func serveRootFile(w http.ResponseWriter, r *http.Request, dist fs.FS) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	name := strings.TrimPrefix(r.URL.Path, "/")
+	if name == "" || name == "index.html" || strings.Contains(name, "/") || path.Ext(name) == "" {
+		return false
+	}
+	info, err := fs.Stat(dist, name)
+	if err != nil || info.IsDir() {
+		return false
+	}
+
+	w.Header().Set("Cache-Control", cacheShortRootFile)
+	http.ServeFileFS(w, r, dist, name)
+	return true
+}
+
+// assetsHandler 提供 /assets 下的文件：长期缓存、优先返回预压缩版本，文件不存在时交给 notFound。
+func assetsHandler(assets fs.FS, notFound http.HandlerFunc) http.Handler {
+	files := http.StripPrefix("/assets", http.FileServerFS(assets))
+	serve := precompressedAssets(assets, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if info, err := fs.Stat(assets, assetName(r)); err != nil || info.IsDir() {
+			notFound(w, r)
+			return
+		}
+		files.ServeHTTP(w, r)
+	}))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", cacheImmutableAsset)
+		serve.ServeHTTP(w, r)
+	})
+}
+
+// assetName 返回请求在 /assets 下对应的文件名。
+func assetName(r *http.Request) string {
+	return strings.TrimPrefix(path.Clean("/"+strings.TrimPrefix(r.URL.Path, "/assets")), "/")
+}
+
+func serveInvite(w http.ResponseWriter, r *http.Request, basePath string) {
+	inviteCode := strings.TrimSpace(r.PathValue("invite_code"))
+	if inviteCode != "" {
+		http.SetCookie(w, &http.Cookie{
+			Name:     "invite_code",
+			Value:    url.QueryEscape(inviteCode),
+			MaxAge:   60 * 60 * 24 * 30,
+			Path:     "/",
+			HttpOnly: true,
+		})
+	}
+	http.Redirect(w, r, withBasePath(basePath, "/"), http.StatusFound)
+}
+
+func registerPprof(mux *http.ServeMux, enabled bool) {
+	if !enabled {
+		return
+	}
+	log.Printf("pprof enabled at /debug/pprof")
+	// pprof.Index 同时按名称提供 allocs、heap、goroutine 等 profile。
+	mux.HandleFunc("GET /debug/pprof/", pprof.Index)
+	mux.HandleFunc("GET /debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("GET /debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("GET /debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("POST /debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("GET /debug/pprof/trace", pprof.Trace)
+}
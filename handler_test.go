@@ -0,0 +1,207 @@
+package gossr
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func withTestDataMux(t *testing.T, register func(*http.ServeMux)) {
+	t.Helper()
+
+	old, oldRoutes := DataMux, dataRoutes
+	DataMux, dataRoutes = http.NewServeMux(), nil
+	if register != nil {
+		register(DataMux)
+	}
+	t.Cleanup(func() {
+		DataMux, dataRoutes = old, oldRoutes
+	})
+}
+
+const testMessageScript = `globalThis.ssrRender = function() { return "<p>" + (__SSR_DATA__.message || "none") + "</p>" }`
+
+func TestNewHandlerOnStandardMux(t *testing.T) {
+	withTestDataMux(t, func(mux *http.ServeMux) {
+		mux.Handle("GET /hi/{name}", WrapData(func(r *http.Request) (SSRPayload, error) {
+			return mapPayload{"message": "hello " + r.PathValue("name") + r.URL.Query().Get("suffix")}, nil
+		}))
+	})
+
+	server, err := NewHandler(testBuild(testMessageScript, nil))
+	if err != nil {
+		t.Fatalf("new handler: %v", err)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/ping", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("pong"))
+	})
+	mux.Handle("/", server)
+
+	if body := performRequest(mux, http.MethodGet, "/hi/bob?suffix=!", nil).Body.String(); !strings.Contains(body, "<p>hello bob!</p>") {
+		t.Fatalf("expected page rendered with net/http data route, got %q", body)
+	}
+	if body := performRequest(mux, http.MethodGet, "/unknown", nil).Body.String(); !strings.Contains(body, "<p>none</p>") {
+		t.Fatalf("expected unmatched data route to render with empty payload, got %q", body)
+	}
+	if body := performRequest(mux, http.MethodGet, "/api/ping", nil).Body.String(); body != "pong" {
+		t.Fatalf("expected application route to win, got %q", body)
+	}
+
+	w := performRequest(mux, http.MethodGet, "/assets/app.js", nil)
+	if w.Code != http.StatusOK || w.Header().Get("Cache-Control") != cacheImmutableAsset {
+		t.Fatalf("expected immutable asset, got %d %q", w.Code, w.Header().Get("Cache-Control"))
+	}
+	if w := performRequest(mux, http.MethodGet, "/favicon.ico", nil); w.Code != http.StatusOK || w.Header().Get("Cache-Control") != cacheShortRootFile {
+		t.Fatalf("expected root file, got %d %q", w.Code, w.Header().Get("Cache-Control"))
+	}
+	if w := performRequest(mux, http.MethodGet, "/assets/missing.js", nil); w.Code != http.StatusNotFound {
+		t.Fatalf("expected missing asset to 404, got %d", w.Code)
+	}
+
+	if w := performRequest(mux, http.MethodGet, DefaultSSRDataRoute+"/hi/bob", nil); w.Code != http.StatusForbidden {
+		t.Fatalf("expected data route guard, got %d", w.Code)
+	}
+	w = performRequest(mux, http.MethodGet, DefaultSSRDataRoute+"/hi/bob", func(req *http.Request) {
+		req.Host = "example.com"
+		req.Header.Set("Origin", "http://example.com")
+	})
+	var data map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &data); err != nil || data["message"] != "hello bob" {
+		t.Fatalf("expected data route JSON, got %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestDataMuxTakesPrecedenceOverSsrEngine(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	withTestDataMux(t, func(mux *http.ServeMux) {
+		mux.Handle("GET /shared", WrapData(func(*http.Request) (SSRPayload, error) {
+			return mapPayload{"from": "mux"}, nil
+		}))
+	})
+	withTestSSREngine(t, func(engine *gin.Engine) {
+		for _, route := range []string{"/shared", "/legacy"} {
+			engine.GET(route, WrapSSR(func(*gin.Context) (SSRPayload, error) {
+				return mapPayload{"from": "gin"}, nil
+			}))
+		}
+	})
+
+	metrics := NewPrometheusMetrics()
+	ctx := contextWithOptions(context.Background(), newOptions(WithMetrics(metrics)))
+	for route, want := range map[string]string{"/shared": "mux", "/legacy": "gin"} {
+		payload, status, err := resolveRequest(ctx, nil, httptest.NewRequest(http.MethodGet, route, nil))
+		if err != nil || status != http.StatusOK || payload.AsMap()["from"] != want {
+			t.Fatalf("%s: expected payload from %s, got %v %d %v", route, want, payload, status, err)
+		}
+	}
+	assertMetricLine(t, scrapeMetrics(t, metrics), `gossr_data_fetch_duration_seconds_count{route="GET /shared",status="200"} 1`)
+}
+
+func TestDataMuxSubtreeRedirectFallsThrough(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	withTestDataMux(t, func(mux *http.ServeMux) {
+		mux.Handle("GET /docs/", WrapData(func(*http.Request) (SSRPayload, error) {
+			return mapPayload{"from": "mux"}, nil
+		}))
+	})
+	withTestSSREngine(t, func(engine *gin.Engine) {
+		engine.GET("/docs", WrapSSR(func(*gin.Context) (SSRPayload, error) {
+			return mapPayload{"from": "gin"}, nil
+		}))
+	})
+
+	metrics := NewPrometheusMetrics()
+	ctx := contextWithOptions(context.Background(), newOptions(WithMetrics(metrics)))
+	payload, status, err := resolveRequest(ctx, nil, httptest.NewRequest(http.MethodGet, "/docs", nil))
+	if err != nil || status != http.StatusOK || payload.AsMap()["from"] != "gin" {
+		t.Fatalf("expected the mux redirect to fall through to SsrEngine, got %v %d %v", payload, status, err)
+	}
+	if _, status, err := resolveRequest(ctx, nil, httptest.NewRequest(http.MethodGet, "/docs-missing", nil)); err != nil || status != http.StatusNotFound {
+		t.Fatalf("expected unmatched path to be a 404, got %d %v", status, err)
+	}
+	if body := scrapeMetrics(t, metrics); strings.Contains(body, `route="GET /docs/"`) {
+		t.Fatalf("expected the redirect not to be recorded as a data fetch:\n%s", body)
+	}
+}
+
+func TestDataMuxRouteRedirectIsKept(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var muxCalls, ginCalls int
+	withTestDataMux(t, func(mux *http.ServeMux) {
+		mux.HandleFunc("GET /account", func(w http.ResponseWriter, r *http.Request) {
+			muxCalls++
+			http.Redirect(w, r, "/login", http.StatusFound)
+		})
+		mux.Handle("GET /old", http.RedirectHandler("/new", http.StatusTemporaryRedirect))
+		mux.Handle("POST /form", WrapData(func(*http.Request) (SSRPayload, error) {
+			return mapPayload{"from": "mux"}, nil
+		}))
+	})
+	withTestSSREngine(t, func(engine *gin.Engine) {
+		for _, route := range []string{"/account", "/old", "/form"} {
+			engine.GET(route, WrapSSR(func(*gin.Context) (SSRPayload, error) {
+				ginCalls++
+				return mapPayload{"from": "gin"}, nil
+			}))
+		}
+	})
+
+	w, _ := callDataRoutes(context.Background(), nil, nil, "/account", "")
+	if w.Code != http.StatusFound || w.Header().Get("Location") != "/login" {
+		t.Fatalf("expected the data route redirect, got %d %q", w.Code, w.Header().Get("Location"))
+	}
+	if muxCalls != 1 || ginCalls != 0 {
+		t.Fatalf("expected only the data route to run once, got mux=%d gin=%d", muxCalls, ginCalls)
+	}
+
+	w, _ = callDataRoutes(context.Background(), nil, nil, "/old", "")
+	if w.Code != http.StatusTemporaryRedirect || w.Header().Get("Location") != "/new" || ginCalls != 0 {
+		t.Fatalf("expected the registered redirect handler, got %d %q gin=%d", w.Code, w.Header().Get("Location"), ginCalls)
+	}
+
+	payload, status, err := resolveRequest(context.Background(), nil, httptest.NewRequest(http.MethodGet, "/form", nil))
+	if err != nil || status != http.StatusOK || payload.AsMap()["from"] != "gin" || ginCalls != 1 {
+		t.Fatalf("expected a method mismatch to fall through to SsrEngine, got %v %d %v gin=%d", payload, status, err, ginCalls)
+	}
+}
+
+func TestWrapDataErrors(t *testing.T) {
+	handler := WrapData(func(*http.Request) (SSRPayload, error) {
+		return nil, errors.New("db password leaked")
+	})
+
+	w := performRequest(handler, http.MethodGet, "/", nil)
+	if w.Code != http.StatusInternalServerError || strings.Contains(w.Body.String(), "password") {
+		t.Fatalf("expected masked 500, got %d %s", w.Code, w.Body.String())
+	}
+
+	w = performRequest(handler, http.MethodGet, "/", func(req *http.Request) {
+		*req = *req.WithContext(contextWithOptions(req.Context(), newOptions(WithDevMode(true), WithExposeHandlerErrors(true))))
+	})
+	if !strings.Contains(w.Body.String(), "db password leaked") {
+		t.Fatalf("expected exposed error in dev mode, got %s", w.Body.String())
+	}
+}
+
+func TestServeDataMuxRecoversPanics(t *testing.T) {
+	withTestDataMux(t, func(mux *http.ServeMux) {
+		mux.Handle("GET /boom", WrapData(func(*http.Request) (SSRPayload, error) {
+			panic("boom")
+		}))
+	})
+
+	_, status, err := Resolve(context.Background(), "/boom", "")
+	if status != http.StatusInternalServerError || err == nil {
+		t.Fatalf("expected panic to surface as 500, got %d %v", status, err)
+	}
+}
@@ -0,0 +1,173 @@
+package gossr
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+var (
+	htmlAssetTagPattern  = regexp.MustCompile(`(?is)<(script|link)\b([^>]*)>`)
+	htmlAttributePattern = regexp.MustCompile(`([a-zA-Z][\w-]*)(?:\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"'>]+)))?`)
+)
+
+// HintRule 描述页面如何提前告知浏览器入口 JS/CSS。
+type HintRule struct {
+	// EarlyHints 在取数与渲染前发送 103 Early Hints（仅 HTTP/1.1 及以上）。
+	EarlyHints bool
+	// LinkHeader 在最终响应上附加 Link: rel=preload/modulepreload 头。
+	LinkHeader bool
+}
+
+// HintsOptions 资源提示配置，提示的资源来自 index.html 与 Vite manifest 的入口 chunk。
+type HintsOptions struct {
+	// Default 未命中 Routes 时使用的规则。
+	Default HintRule
+	// Routes 按 SsrEngine 同款路由模式（如 /hi/:name、/docs/*path）配置规则。
+	Routes map[string]HintRule
+}
+
+// WithAssetHints 启用 103 Early Hints / Link 响应头。
+func WithAssetHints(hints HintsOptions) Option {
+	return func(opts *Options) {
+		opts.Hints = &hints
+	}
+}
+
+// assetHints 持有启动时计算好的 Link 头，按路由规则写入响应。
+type assetHints struct {
+	links  []string
+	def    HintRule
+	routes routeTable[HintRule]
+}
+
+func newAssetHints(options Options, indexHTML string, manifest *assetManifest) *assetHints {
+	if options.Hints == nil {
+		return nil
+	}
+
+	links := criticalAssetLinks(indexHTML, manifest)
+	if len(links) == 0 {
+		return nil
+	}
+	return &assetHints{
+		links:  links,
+		def:    options.Hints.Default,
+		routes: newRouteTable(options.Hints.Routes),
+	}
+}
+
+// apply 按路由规则发送 103 Early Hints，并（可选）在最终响应上追加 Link 头；
+// 用户中间件已设置的 Link 头原样保留。
+func (h *assetHints) apply(w http.ResponseWriter, r *http.Request) {
+	if h == nil {
+		return
+	}
+
+	rule, ok := h.routes.lookup(r.URL.Path)
+	if !ok {
+		rule = h.def
+	}
+	if !rule.EarlyHints && !rule.LinkHeader {
+		return
+	}
+
+	header := w.Header()
+	if rule.EarlyHints && r.ProtoAtLeast(1, 1) {
+		// 103 只携带 hint，发送后恢复原有的 Link 头。
+		userLinks, hasUserLinks := header["Link"]
+		header["Link"] = h.links
+		// 包装层（Gin、statusWriter 等）会把 1xx 记为最终状态码，需写到最内层的 ResponseWriter。
+		unwrapResponseWriter(w).WriteHeader(http.StatusEarlyHints)
+		if hasUserLinks {
+			header["Link"] = userLinks
+		} else {
+			delete(header, "Link")
+		}
+	}
+	if rule.LinkHeader {
+		for _, link := range h.links {
+			header.Add("Link", link)
+		}
+	}
+}
+
+// criticalAssetLinks 从 index.html 的入口 script/stylesheet/modulepreload 与 manifest 入口 chunk
+// 生成 Link 头的值，仅包含站内路径（/assets 下的文件由 immutable 缓存组提供）。
+func criticalAssetLinks(indexHTML string, manifest *assetManifest) []string {
+	var links []string
+	seen := make(map[string]struct{})
+	add := func(href string, rel string, crossorigin bool) {
+		href = strings.TrimSpace(href)
+		if !strings.HasPrefix(href, "/") || strings.HasPrefix(href, "//") {
+			return
+		}
+		if _, ok := seen[href]; ok {
+			return
+		}
+		seen[href] = struct{}{}
+
+		link := "<" + href + ">; " + rel
+		if crossorigin {
+			link += "; crossorigin"
+		}
+		links = append(links, link)
+	}
+
+	for _, match := range htmlAssetTagPattern.FindAllStringSubmatch(indexHTML, -1) {
+		attrs := parseHTMLAttributes(match[2])
+		_, crossorigin := attrs["crossorigin"]
+		switch strings.ToLower(match[1]) {
+		case "script":
+			if src := attrs["src"]; src != "" {
+				if strings.EqualFold(attrs["type"], "module") {
+					add(src, "rel=modulepreload", crossorigin)
+				} else {
+					add(src, "rel=preload; as=script", crossorigin)
+				}
+			}
+		case "link":
+			switch strings.ToLower(attrs["rel"]) {
+			case "stylesheet":
+				add(attrs["href"], "rel=preload; as=style", crossorigin)
+			case "modulepreload":
+				add(attrs["href"], "rel=modulepreload", crossorigin)
+			}
+		}
+	}
+
+	if manifest != nil {
+		for _, file := range manifest.entryFiles() {
+			if strings.HasSuffix(strings.ToLower(file), ".css") {
+				add(file, "rel=preload; as=style", false)
+			} else {
+				add(file, "rel=modulepreload", true)
+			}
+		}
+	}
+
+	return links
+}
+
+func parseHTMLAttributes(raw string) map[string]string {
+	attrs := make(map[string]string)
+	for _, m := range htmlAttributePattern.FindAllStringSubmatch(raw, -1) {
+		name := strings.ToLower(m[1])
+		if _, exists := attrs[name]; exists {
+			continue
+		}
+		attrs[name] = m[2] + m[3] + m[4]
+	}
+	return attrs
+}
+
+// unwrapResponseWriter 沿 Unwrap 链返回最内层的 ResponseWriter。
+func unwrapResponseWriter(w http.ResponseWriter) http.ResponseWriter {
+	for {
+		u, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			return w
+		}
+		w = u.Unwrap()
+	}
+}
@@ -0,0 +1,111 @@
+package gossr
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"net/textproto"
+	"reflect"
+	"testing"
+	"testing/fstest"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestCriticalAssetLinks(t *testing.T) {
+	indexHTML := `<html><head>
+		<script type="module" crossorigin src="/assets/index-a1.js"></script>
+		<link rel="modulepreload" crossorigin href="/assets/vendor-f6.js">
+		<link rel="stylesheet" crossorigin href="/assets/index-a1.css">
+		<link rel="icon" href="/favicon.ico">
+		<script src="https://cdn.example.com/lib.js"></script>
+		<script src='/legacy.js'></script>
+	</head><body></body></html>`
+	manifest := &assetManifest{build: map[string]manifestChunk{
+		"index.html":    {File: "assets/index-a1.js", CSS: []string{"assets/index-a1.css"}, Imports: []string{"_vendor-f6.js"}, IsEntry: true},
+		"_vendor-f6.js": {File: "assets/vendor-f6.js", Imports: []string{"_shared-c3.js"}},
+		"_shared-c3.js": {File: "assets/shared-c3.js", CSS: []string{"assets/shared-c3.css"}, Assets: []string{"assets/logo.png"}},
+		"src/lazy.vue":  {File: "assets/lazy-g7.js"},
+	}}
+
+	got := criticalAssetLinks(indexHTML, manifest)
+	want := []string{
+		"</assets/index-a1.js>; rel=modulepreload; crossorigin",
+		"</assets/vendor-f6.js>; rel=modulepreload; crossorigin",
+		"</assets/index-a1.css>; rel=preload; as=style; crossorigin",
+		"</legacy.js>; rel=preload; as=script",
+		"</assets/shared-c3.js>; rel=modulepreload; crossorigin",
+		"</assets/shared-c3.css>; rel=preload; as=style",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected links:\n%q\nwant:\n%q", got, want)
+	}
+}
+
+func TestRunBlockingSendsEarlyHintsPerRoute(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	const userLink = "</fonts/inter.woff2>; rel=preload; as=font"
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Header("Link", userLink)
+	})
+	RunBlocking(router, FrontendBuild{
+		FrontendDist: testManifestDistFS(),
+		ServerDist: fstest.MapFS{
+			"server.js": {Data: []byte(`globalThis.ssrRender = function(url) { return "<p>" + url + "</p>" }`)},
+		},
+	}, nil, WithAssetHints(HintsOptions{
+		Default: HintRule{EarlyHints: true},
+		Routes: map[string]HintRule{
+			"/docs/*path": {LinkHeader: true},
+			"/plain":      {},
+		},
+	}))
+
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	wantLinks := []string{
+		"</assets/index-a1.js>; rel=modulepreload; crossorigin",
+		"</assets/index-a1.css>; rel=preload; as=style; crossorigin",
+	}
+
+	tests := []struct {
+		path       string
+		earlyHints []string
+		final      []string
+	}{
+		{path: "/about", earlyHints: wantLinks, final: []string{userLink}},
+		{path: "/docs/intro", final: append([]string{userLink}, wantLinks...)},
+		{path: "/plain", final: []string{userLink}},
+	}
+	for _, tt := range tests {
+		var hints []string
+		trace := &httptrace.ClientTrace{
+			Got1xxResponse: func(code int, header textproto.MIMEHeader) error {
+				if code == http.StatusEarlyHints {
+					hints = header.Values("Link")
+				}
+				return nil
+			},
+		}
+		req, _ := http.NewRequestWithContext(httptrace.WithClientTrace(context.Background(), trace), http.MethodGet, server.URL+tt.path, nil)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.path, err)
+		}
+		_ = resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("%s: unexpected status %d", tt.path, resp.StatusCode)
+		}
+		if !reflect.DeepEqual(hints, tt.earlyHints) {
+			t.Fatalf("%s: unexpected early hints %q, want %q", tt.path, hints, tt.earlyHints)
+		}
+		if got := resp.Header.Values("Link"); !reflect.DeepEqual(got, tt.final) {
+			t.Fatalf("%s: unexpected final Link headers %q, want %q", tt.path, got, tt.final)
+		}
+	}
+}
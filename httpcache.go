@@ -0,0 +1,141 @@
+package gossr
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// HTTPCacheRule 描述页面的浏览器/CDN 缓存策略。
+type HTTPCacheRule struct {
+	// CacheControl 替代默认的 no-cache, no-store，如 "public, s-maxage=60, stale-while-revalidate=300"；
+	// 为空时保持默认值。
+	CacheControl string
+	// ETag 为 true 时为页面计算强 ETag，并对匹配的 If-None-Match 返回 304。
+	ETag bool
+	// Vary 列出页面内容随之变化的请求头（如 Accept-Language）：版本 ETag 只涵盖这些请求头的值，
+	// 响应同时输出同名 Vary 头供共享缓存区分变体。Referer、User-Agent 这类逐次变化的请求头不宜声明。
+	Vary []string
+}
+
+// HTTPCacheOptions 渲染页面的 HTTP 缓存配置（opt-in），只作用于 200 且未设置 Cookie 的缓冲渲染结果。
+type HTTPCacheOptions struct {
+	// Default 未命中 Routes 时使用的规则。
+	Default HTTPCacheRule
+	// Routes 按 SsrEngine 同款路由模式（如 /hi/:name、/docs/*path）配置规则。
+	Routes map[string]HTTPCacheRule
+	// VersionField 非空时，若 payload 中该字段为非空字符串或数字，以其值（加构建版本、URI、origin 与规则声明的 Vary 请求头）作为 ETag 依据，
+	// 匹配 If-None-Match 时跳过渲染直接返回 304；否则对最终 HTML 计算 ETag。
+	VersionField string
+	// IncludeSessions 为 true 时携带有效 session 的请求也应用规则（默认保持 no-store，避免共享缓存泄露个人页面）。
+	IncludeSessions bool
+}
+
+// WithHTTPCache 启用页面 ETag / 条件请求与按路由的 Cache-Control。
+func WithHTTPCache(cache HTTPCacheOptions) Option {
+	return func(opts *Options) {
+		opts.HTTPCache = &cache
+	}
+}
+
+// httpCachePolicy 持有按路由查找的 HTTP 缓存规则。
+type httpCachePolicy struct {
+	opts   HTTPCacheOptions
+	routes routeTable[HTTPCacheRule]
+}
+
+func newHTTPCachePolicy(options Options) *httpCachePolicy {
+	if options.HTTPCache == nil {
+		return nil
+	}
+	return &httpCachePolicy{
+		opts:   *options.HTTPCache,
+		routes: newRouteTable(options.HTTPCache.Routes),
+	}
+}
+
+// rule 返回请求适用的规则，策略未启用或请求被跳过时 ok 为 false。
+func (p *httpCachePolicy) rule(req *http.Request) (HTTPCacheRule, bool) {
+	if p == nil {
+		return HTTPCacheRule{}, false
+	}
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return HTTPCacheRule{}, false
+	}
+	if !p.opts.IncludeSessions && sessionStateFromRequest(req) != nil {
+		return HTTPCacheRule{}, false
+	}
+
+	rule, ok := p.routes.lookup(req.URL.Path)
+	if !ok {
+		rule = p.opts.Default
+	}
+	return rule, rule.ETag || rule.CacheControl != ""
+}
+
+// versionETag 根据 payload 中的版本字段计算 ETag，字段不存在时返回空字符串。ETag 同时涵盖构建版本、
+// 请求 URI、origin 与 rule.Vary 声明的请求头，换构建或切换语言后不会误返回 304，而与页面无关的输入
+// （Referer、客户端 IP 等）不影响 ETag，共享缓存可以复用；带 session 的请求渲染结果因人而异，不计算版本 ETag。
+func (p *httpCachePolicy) versionETag(buildVersion string, req *http.Request, rule HTTPCacheRule, origin string, payload map[string]any) string {
+	if p == nil || p.opts.VersionField == "" || req == nil || req.URL == nil || sessionStateFromRequest(req) != nil {
+		return ""
+	}
+
+	var version string
+	switch v := payload[p.opts.VersionField].(type) {
+	case string:
+		version = strings.TrimSpace(v)
+	case int, int64, float64:
+		version = fmt.Sprint(v)
+	}
+	if version == "" {
+		return ""
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "v|%s|%q|%q|", buildVersion, req.URL.RequestURI(), origin)
+	for _, name := range rule.Vary {
+		fmt.Fprintf(&b, "%q=%q;", http.CanonicalHeaderKey(name), strings.Join(req.Header.Values(name), ","))
+	}
+	return strongETag(b.String() + "|" + version)
+}
+
+// strongETag 返回 data 的强 ETag（带引号）。
+func strongETag(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// encodedETag 为压缩后的表示派生独立的 ETag，如 "abc" -> "abc-br"。
+func encodedETag(etag string, encoding string) string {
+	if etag == "" || encoding == "" {
+		return etag
+	}
+	return strings.TrimSuffix(etag, `"`) + "-" + encoding + `"`
+}
+
+// matchETagVariants 判断 If-None-Match 是否命中 etag 或其任一压缩编码变体，返回命中的值。
+func matchETagVariants(ifNoneMatch string, etag string) (string, bool) {
+	for _, enc := range []string{"", encodingBrotli, encodingZstd, encodingGzip} {
+		if variant := encodedETag(etag, enc); etagMatches(ifNoneMatch, variant) {
+			return variant, true
+		}
+	}
+	return "", false
+}
+
+// etagMatches 按 If-None-Match 的弱比较规则判断 etag 是否命中。
+func etagMatches(ifNoneMatch string, etag string) bool {
+	if etag == "" || strings.TrimSpace(ifNoneMatch) == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
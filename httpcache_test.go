@@ -0,0 +1,177 @@
+package gossr
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestEtagMatches(t *testing.T) {
+	etag := `"abc"`
+	tests := map[string]bool{
+		``:                  false,
+		`"abc"`:             true,
+		`W/"abc"`:           true,
+		`"x", "abc"`:        true,
+		`*`:                 true,
+		`"abc-br"`:          false,
+		`"abcd"`:            false,
+		`"x",W/"abc" , "y"`: true,
+	}
+	for header, want := range tests {
+		if got := etagMatches(header, etag); got != want {
+			t.Fatalf("etagMatches(%q)=%v want %v", header, got, want)
+		}
+	}
+
+	if got, ok := matchETagVariants(`"abc-gzip"`, etag); !ok || got != `"abc-gzip"` {
+		t.Fatalf("expected encoded variant to match, got %q %v", got, ok)
+	}
+}
+
+func TestRunBlockingHTTPCacheETag(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	RunBlocking(router, FrontendBuild{
+		FrontendDist: testFrontendDistFS(),
+		ServerDist: fstest.MapFS{"server.js": {Data: []byte(`globalThis.ssrRender = function(url) {
+			if (url === "/cookie") return { html: "<p>c</p>", headers: { "Set-Cookie": "a=1" } }
+			return "<p>" + url + "</p>" + "x".repeat(2048)
+		}`)}},
+	}, nil,
+		WithCompression(CompressionOptions{Encodings: []string{"gzip"}}),
+		WithHTTPCache(HTTPCacheOptions{
+			Default: HTTPCacheRule{ETag: true},
+			Routes: map[string]HTTPCacheRule{
+				"/docs/*path": {ETag: true, CacheControl: "public, s-maxage=60, stale-while-revalidate=300"},
+				"/live":       {},
+			},
+		}),
+	)
+
+	first := performRequest(router, http.MethodGet, "/docs/intro", nil)
+	etag := first.Header().Get("ETag")
+	if first.Code != http.StatusOK || etag == "" {
+		t.Fatalf("expected 200 with ETag, got %d %v", first.Code, first.Header())
+	}
+	if cc := first.Header().Get("Cache-Control"); cc != "public, s-maxage=60, stale-while-revalidate=300" {
+		t.Fatalf("expected route Cache-Control, got %q", cc)
+	}
+	if first.Header().Get("Pragma") != "" {
+		t.Fatalf("expected no Pragma with custom Cache-Control, got %q", first.Header().Get("Pragma"))
+	}
+
+	second := performRequest(router, http.MethodGet, "/docs/intro", func(req *http.Request) {
+		req.Header.Set("If-None-Match", etag)
+	})
+	if second.Code != http.StatusNotModified || second.Body.Len() != 0 || second.Header().Get("ETag") != etag {
+		t.Fatalf("expected 304 with same ETag, got %d %v body=%q", second.Code, second.Header(), second.Body.String())
+	}
+
+	gzipped := performRequest(router, http.MethodGet, "/docs/intro", func(req *http.Request) {
+		req.Header.Set("Accept-Encoding", "gzip")
+		req.Header.Set("If-None-Match", etag)
+	})
+	if gzipped.Code != http.StatusOK || gzipped.Header().Get("ETag") != strings.TrimSuffix(etag, `"`)+`-gzip"` {
+		t.Fatalf("expected distinct ETag for gzip representation, got %d %v", gzipped.Code, gzipped.Header())
+	}
+
+	other := performRequest(router, http.MethodGet, "/page", nil)
+	if other.Header().Get("ETag") == "" || other.Header().Get("ETag") == etag {
+		t.Fatalf("expected a different ETag for another page, got %q", other.Header().Get("ETag"))
+	}
+	assertNoCacheHeaders(t, other.Header())
+
+	live := performRequest(router, http.MethodGet, "/live", nil)
+	if live.Header().Get("ETag") != "" {
+		t.Fatalf("expected route without ETag, got %q", live.Header().Get("ETag"))
+	}
+
+	cookie := performRequest(router, http.MethodGet, "/cookie", nil)
+	if cookie.Header().Get("ETag") != "" {
+		t.Fatalf("expected pages setting cookies to skip ETag, got %q", cookie.Header().Get("ETag"))
+	}
+
+	session := performRequest(router, http.MethodGet, "/docs/intro", func(req *http.Request) {
+		addSessionTokenCookie(req, mustSessionToken(t, map[string]any{"email": "a@example.com"}))
+	})
+	if session.Header().Get("ETag") != "" {
+		t.Fatalf("expected session requests to keep no-store, got %v", session.Header())
+	}
+	assertNoCacheHeaders(t, session.Header())
+}
+
+func TestRunBlockingHTTPCacheVersionSkipsRender(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	metrics := NewPrometheusMetrics()
+	script := `globalThis.ssrRender = function(url) { return "<p>" + (globalThis.__SSR_DATA__ || {}).version + "</p>" }`
+	router := gin.New()
+	server := RunBlocking(router, testBuild(script, nil), func(context.Context, *http.Request) (SSRPayload, error) {
+		return mapPayload{"version": "v1"}, nil
+	}, WithMetrics(metrics), WithHTTPCache(HTTPCacheOptions{
+		Default:      HTTPCacheRule{ETag: true, Vary: []string{"accept-language"}},
+		VersionField: "version",
+	}))
+
+	first := performRequest(router, http.MethodGet, "/post?page=1", nil)
+	if got := first.Header().Values("Vary"); !reflect.DeepEqual(got, []string{"Accept-Language"}) {
+		t.Fatalf("expected Vary to match the declared request headers, got %q", got)
+	}
+	etag := first.Header().Get("ETag")
+	if first.Code != http.StatusOK || etag == "" || !strings.Contains(first.Body.String(), "<p>v1</p>") {
+		t.Fatalf("expected rendered page with ETag, got %d %v", first.Code, first.Header())
+	}
+
+	second := performRequest(router, http.MethodGet, "/post?page=1", func(req *http.Request) {
+		req.Header.Set("If-None-Match", etag)
+	})
+	if second.Code != http.StatusNotModified || second.Header().Get("ETag") != etag || second.Header().Get("Vary") != "Accept-Language" {
+		t.Fatalf("expected 304 from payload version, got %d %v", second.Code, second.Header())
+	}
+
+	// Referer、User-Agent 与客户端 IP 未在 Vary 中声明，不应影响 ETag。
+	navigated := performRequest(router, http.MethodGet, "/post?page=1", func(req *http.Request) {
+		req.Header.Set("If-None-Match", etag)
+		req.Header.Set("Referer", "https://example.com/elsewhere")
+		req.Header.Set("User-Agent", "other-agent")
+		req.RemoteAddr = "203.0.113.9:1234"
+	})
+	if navigated.Code != http.StatusNotModified {
+		t.Fatalf("expected undeclared request inputs not to change the version ETag, got %d", navigated.Code)
+	}
+	assertMetricLine(t, scrapeMetrics(t, metrics), `gossr_render_duration_seconds_count{engine="goja",result="ok"} 1`)
+
+	other := performRequest(router, http.MethodGet, "/post?page=2", func(req *http.Request) {
+		req.Header.Set("If-None-Match", etag)
+	})
+	if other.Code != http.StatusOK {
+		t.Fatalf("expected version ETag to be scoped to the request URI, got %d", other.Code)
+	}
+	lang := performRequest(router, http.MethodGet, "/post?page=1", func(req *http.Request) {
+		req.Header.Set("If-None-Match", etag)
+		req.Header.Set("Accept-Language", "en")
+	})
+	if lang.Code != http.StatusOK {
+		t.Fatalf("expected version ETag to cover the declared Vary headers, got %d", lang.Code)
+	}
+
+	// 新构建引用不同的 hash 资源，旧 HTML 不能再以 304 复用。
+	if err := server.SwapBuild(testBuild(script, map[string]string{
+		"index.html": strings.Replace(testIndexHTML, "</head>", `<script src="/assets/app-v2.js"></script></head>`, 1),
+	})); err != nil {
+		t.Fatalf("swap build: %v", err)
+	}
+	swapped := performRequest(router, http.MethodGet, "/post?page=1", func(req *http.Request) {
+		req.Header.Set("If-None-Match", etag)
+	})
+	if swapped.Code != http.StatusOK || swapped.Header().Get("ETag") == etag {
+		t.Fatalf("expected a new build to invalidate the version ETag, got %d %q", swapped.Code, swapped.Header().Get("ETag"))
+	}
+}
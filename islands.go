@@ -0,0 +1,151 @@
+package gossr
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/daodao97/gossr/renderer"
+)
+
+// islandOutletPattern 匹配 index.html 中的命名出口 <!--ssr:name-->。
+var islandOutletPattern = regexp.MustCompile(`<!--ssr:([A-Za-z0-9_-]+)-->`)
+
+// islandOutlet 是 index.html 中的一个命名出口。marker 为需要替换的完整片段：
+// 写成 <!--ssr:name-->兜底内容<!--/ssr:name--> 时包含闭合标记，其间的 HTML 作为渲染失败时的兜底。
+type islandOutlet struct {
+	name     string
+	marker   string
+	fallback string
+}
+
+// islandResult 是一个 island 的渲染结果，err 非 nil 时使用出口的兜底内容。
+type islandResult struct {
+	outlet islandOutlet
+	result renderer.Result
+	err    error
+}
+
+// WithIslandTimeout 为命名出口 name 单独设置渲染超时，未设置时与页面渲染超时相同。
+func WithIslandTimeout(name string, timeout time.Duration) Option {
+	return func(opts *Options) {
+		timeouts := make(map[string]time.Duration, len(opts.IslandTimeouts)+1)
+		for n, d := range opts.IslandTimeouts {
+			timeouts[n] = d
+		}
+		timeouts[name] = timeout
+		opts.IslandTimeouts = timeouts
+	}
+}
+
+// parseIslandOutlets 按出现顺序解析 index.html 中的命名出口，同名出口只渲染一次。
+func parseIslandOutlets(indexHTML string) []islandOutlet {
+	var outlets []islandOutlet
+	seen := make(map[string]struct{})
+	for _, loc := range islandOutletPattern.FindAllStringSubmatchIndex(indexHTML, -1) {
+		name := indexHTML[loc[2]:loc[3]]
+		if _, ok := seen[name]; ok {
+			continue
+		}
+		seen[name] = struct{}{}
+
+		outlet := islandOutlet{name: name, marker: indexHTML[loc[0]:loc[1]]}
+		closing := "<!--/ssr:" + name + "-->"
+		if end := strings.Index(indexHTML[loc[1]:], closing); end >= 0 {
+			outlet.fallback = indexHTML[loc[1] : loc[1]+end]
+			outlet.marker += outlet.fallback + closing
+		}
+		outlets = append(outlets, outlet)
+	}
+	return outlets
+}
+
+// islandTimeout 返回出口的渲染超时，未单独设置时使用页面渲染超时。
+func (h *pageHandler) islandTimeout(req *http.Request, name string) time.Duration {
+	if timeout := h.options.IslandTimeouts[name]; timeout > 0 {
+		return timeout
+	}
+	return h.renderTimeout(req)
+}
+
+// renderIslands 在独立的 runtime 中并行渲染全部命名出口，返回的 wait 阻塞到所有渲染结束。
+// island 不再单独占用并发名额，调用方需先为页面 reserveRenderSlot；每个 island 拿到 payload 的独立副本，
+// 避免与主渲染并发读写同一个 map。
+func (h *pageHandler) renderIslands(ctx context.Context, req *http.Request, b *pageBuild, urlPath string, payload map[string]any) (wait func() []islandResult) {
+	results := make([]islandResult, len(b.islands))
+	ir, ok := b.ssr.(renderer.IslandRenderer)
+
+	var wg sync.WaitGroup
+	for i, outlet := range b.islands {
+		results[i].outlet = outlet
+		if !ok {
+			results[i].err = fmt.Errorf("%w: %s", renderer.ErrIslandNotFound, outlet.name)
+			continue
+		}
+
+		islandPayload, err := clonePayload(payload)
+		if err != nil {
+			results[i].err = err
+			continue
+		}
+		timeout := h.islandTimeout(req, outlet.name)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			start := time.Now()
+			results[i].result, results[i].err = runWithRenderSlot(ctx, timeout, nil, h.metrics, func(ctx context.Context) (renderer.Result, error) {
+				return ir.RenderIsland(ctx, outlet.name, urlPath, islandPayload)
+			})
+			h.metrics.ObserveIslandRender(outlet.name, renderResult(results[i].err), time.Since(start))
+		}()
+	}
+
+	return func() []islandResult {
+		wg.Wait()
+		return results
+	}
+}
+
+// composeIslands 把 island 的 HTML 写入页面模板，失败的出口保留兜底内容并在 head 中追加 ssr-island-error。
+// 返回合并后的 head 与模块，island 的 status/redirect/headers 不生效；ok 为 false 表示有 island 降级。
+func (h *pageHandler) composeIslands(page string, results []islandResult, reqID string, path string) (_ string, head string, modules []string, ok bool) {
+	var headBuf strings.Builder
+	ok = true
+	for _, res := range results {
+		if res.err != nil {
+			log.Printf("ssr island render failed id=%s island=%s path=%s err=%v", reqID, res.outlet.name, path, res.err)
+			h.metrics.IncFallback(fallbackReasonIsland)
+			ok = false
+			fmt.Fprintf(&headBuf, `<meta name="ssr-island-error" content="%s">`, template.HTMLEscapeString(res.outlet.name))
+			page = strings.ReplaceAll(page, res.outlet.marker, res.outlet.fallback)
+			continue
+		}
+		page = strings.ReplaceAll(page, res.outlet.marker, res.result.HTML)
+		headBuf.WriteString(res.result.Head)
+		modules = append(modules, res.result.Modules...)
+	}
+	return page, headBuf.String(), modules, ok
+}
+
+// clonePayload 经 JSON 深拷贝 payload，与写入 __SSR_DATA__ 时的序列化结果一致。
+func clonePayload(payload map[string]any) (map[string]any, error) {
+	if len(payload) == 0 {
+		return nil, nil
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	var cloned map[string]any
+	if err := json.Unmarshal(data, &cloned); err != nil {
+		return nil, err
+	}
+	return cloned, nil
+}
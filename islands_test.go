@@ -0,0 +1,132 @@
+package gossr
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testIslandsScript = `
+globalThis.ssrRender = function() { return "<main>" + __SSR_DATA__.message + "</main>" }
+globalThis.ssrRenderers = {
+	header: function(url, data) {
+		data.message = "mutated"
+		globalThis.__SSR_HEAD__ = "<style>.header{}</style>"
+		return "<nav>" + url + "|" + data.user + "</nav>"
+	},
+	cart: function() { throw new Error("cart service down") },
+	slow: function() { while (true) {} }
+}`
+
+func TestParseIslandOutlets(t *testing.T) {
+	outlets := parseIslandOutlets(`<!--ssr:a--><!--app-html--><!--ssr:b--><i>b</i><!--/ssr:b--><!--ssr:a-->`)
+	want := []islandOutlet{
+		{name: "a", marker: "<!--ssr:a-->"},
+		{name: "b", marker: "<!--ssr:b--><i>b</i><!--/ssr:b-->", fallback: "<i>b</i>"},
+	}
+	if !reflect.DeepEqual(outlets, want) {
+		t.Fatalf("expected %+v, got %+v", want, outlets)
+	}
+}
+
+func TestIslandsRenderWithPerIslandFallback(t *testing.T) {
+	withTestDataMux(t, func(*http.ServeMux) {
+		HandleData("GET /shop", func(*http.Request) (SSRPayload, error) {
+			return mapPayload{"message": "catalog", "user": "alice"}, nil
+		})
+	})
+
+	metrics := NewPrometheusMetrics()
+	index := `<!doctype html><html><head></head><body><header><!--ssr:header--></header><!--app-html-->` +
+		`<aside><!--ssr:cart--><p>cart unavailable</p><!--/ssr:cart--></aside><!--ssr:slow--></body></html>`
+	server, err := NewHandler(testBuild(testIslandsScript, map[string]string{"index.html": index}),
+		WithMetrics(metrics),
+		WithRenderTimeout(2*time.Second),
+		WithIslandTimeout("slow", 50*time.Millisecond),
+	)
+	if err != nil {
+		t.Fatalf("new handler: %v", err)
+	}
+
+	start := time.Now()
+	body := performRequest(server, http.MethodGet, "/shop", nil).Body.String()
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("expected slow island to be cut off by its own timeout, took %s", elapsed)
+	}
+	for _, want := range []string{
+		"<header><nav>/shop|alice</nav></header>",
+		"<main>catalog</main>",
+		"<aside><p>cart unavailable</p></aside></body>",
+		"<style>.header{}</style>",
+		`<meta name="ssr-island-error" content="cart">`,
+		`<meta name="ssr-island-error" content="slow">`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("expected page to contain %q, got %q", want, body)
+		}
+	}
+	if strings.Contains(body, "<!--") || strings.Contains(body, "ssr-error-id") || strings.Contains(body, "mutated") {
+		t.Fatalf("expected outlets replaced without affecting the main render or payload, got %q", body)
+	}
+
+	assertMetricLine(t, scrapeMetrics(t, metrics), `gossr_render_fallbacks_total{reason="island"} 2`)
+}
+
+func TestIslandsShareThePageRenderSlot(t *testing.T) {
+	script := `
+globalThis.ssrRender = function() { return new Promise(function(resolve) { setTimeout(function() { resolve("<main>slow</main>") }, 300) }) }
+globalThis.ssrRenderers = {
+	header: function(url) { return "<nav>" + url + "</nav>" },
+	footer: function() { return "<small>footer</small>" }
+}`
+	index := `<!doctype html><html><head></head><body><!--ssr:header--><!--app-html--><!--ssr:footer--></body></html>`
+	metrics := NewPrometheusMetrics()
+	server, err := NewHandler(testBuild(script, map[string]string{"index.html": index}),
+		WithMetrics(metrics),
+		WithRenderLimit(1),
+		WithRenderTimeout(2*time.Second),
+		WithIslandTimeout("header", 150*time.Millisecond),
+		WithIslandTimeout("footer", 150*time.Millisecond),
+	)
+	if err != nil {
+		t.Fatalf("new handler: %v", err)
+	}
+
+	// 只有一个并发名额时，island 不应排在主渲染之后等到超时。
+	body := performRequest(server, http.MethodGet, "/shop", nil).Body.String()
+	for _, want := range []string{"<nav>/shop</nav>", "<main>slow</main>", "<small>footer</small>"} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("expected page to contain %q, got %q", want, body)
+		}
+	}
+	if strings.Contains(body, "ssr-island-error") {
+		t.Fatalf("expected islands not to be starved by the main render, got %q", body)
+	}
+
+	metricsBody := scrapeMetrics(t, metrics)
+	assertMetricLine(t, metricsBody, `gossr_render_duration_seconds_count{engine="goja",result="ok"} 1`)
+	assertMetricLine(t, metricsBody, `gossr_island_render_duration_seconds_count{island="header",result="ok"} 1`)
+	assertMetricLine(t, metricsBody, `gossr_island_render_duration_seconds_count{island="footer",result="ok"} 1`)
+	assertMetricLine(t, metricsBody, `gossr_render_queue_wait_seconds_count 1`)
+}
+
+func TestIslandsOnlyPageSkipsMainRender(t *testing.T) {
+	script := `
+globalThis.ssrRender = function() { throw new Error("should not run") }
+globalThis.ssrRenderers = { clock: function(url) { return "<time>" + url + "</time>" } }`
+	index := `<!doctype html><html><head></head><body><!--ssr:clock--><!--ssr:missing--></body></html>`
+	server, err := NewHandler(testBuild(script, map[string]string{"index.html": index}))
+	if err != nil {
+		t.Fatalf("new handler: %v", err)
+	}
+
+	body := performRequest(server, http.MethodGet, "/now", nil).Body.String()
+	if !strings.Contains(body, "<body><time>/now</time></body>") || strings.Contains(body, "ssr-error-id") {
+		t.Fatalf("expected islands-only page rendered without ssrRender, got %q", body)
+	}
+	if !strings.Contains(body, `<meta name="ssr-island-error" content="missing">`) {
+		t.Fatalf("expected missing island renderer to degrade, got %q", body)
+	}
+}
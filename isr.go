@@ -0,0 +1,381 @@
+package gossr
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// DefaultSSRRevalidateRoute 为按需重新生成页面的接口，见 ISROptions.RevalidateToken。
+const DefaultSSRRevalidateRoute = "/_ssr/revalidate"
+
+// StoredPage 是 PageStore 中的一个预渲染页面。
+type StoredPage struct {
+	Body   []byte
+	Header http.Header
+	// GeneratedAt 为生成时间，Revalidate > 0 时超过 GeneratedAt+Revalidate 的页面在返回后后台重新生成。
+	GeneratedAt time.Time
+	// Revalidate 为生成时路由声明的重新生成间隔（见 Revalidate），0 表示不自动重新生成。
+	Revalidate time.Duration
+	// Build 为生成页面的构建版本（index.html 与 SSR 脚本的摘要）。与当前构建不一致的页面引用的是旧的 hash 资源，
+	// 不再返回，按未生成处理；为空（如手工放入的 HTML）时不校验。
+	Build string
+}
+
+// PageStore 预渲染页面存储，key 为页面路径；页面不存在时 Get 返回 fs.ErrNotExist。
+type PageStore interface {
+	Get(ctx context.Context, urlPath string) (*StoredPage, error)
+	Put(ctx context.Context, urlPath string, page *StoredPage) error
+	Delete(ctx context.Context, urlPath string) error
+}
+
+// ISROptions 增量静态生成（ISR）配置。
+type ISROptions struct {
+	// Store 页面存储，nil 时使用内存存储；NewDirPageStore 可直接读取 Prerender 的输出目录。
+	Store PageStore
+	// RevalidateToken 非空时挂载 POST /_ssr/revalidate?path=...，请求需携带 Authorization: Bearer <token>。
+	RevalidateToken string
+	// IncludeSessions 为 true 时携带有效 session 的请求同样返回存储中的（不含登录态的）页面；
+	// 默认走实时渲染，保证 __SSR_DATA__ 中带有 session。
+	IncludeSessions bool
+}
+
+// WithISR 启用增量静态生成：存储中的页面（GET/HEAD 且不带 query）直接返回，过期后后台重新生成；
+// 声明了 Revalidate 但尚未生成的页面在首次请求时生成并写入存储。
+func WithISR(isr ISROptions) Option {
+	return func(opts *Options) {
+		opts.ISR = &isr
+	}
+}
+
+// Revalidate 设置路由页面的重新生成间隔，用于 WithISR 与 Prerender。
+func Revalidate(interval time.Duration) RouteOption {
+	return func(ro *RouteOptions) {
+		ro.Revalidate = interval
+	}
+}
+
+// isrState 为 pageHandler 的 ISR 状态，同一页面的重新生成经 singleflight 合并。
+type isrState struct {
+	store           PageStore
+	token           string
+	includeSessions bool
+	flight          singleflight.Group
+}
+
+func newISRState(options Options) *isrState {
+	if options.ISR == nil {
+		return nil
+	}
+	store := options.ISR.Store
+	if store == nil {
+		store = NewMemoryPageStore()
+	}
+	return &isrState{
+		store:           store,
+		token:           strings.TrimSpace(options.ISR.RevalidateToken),
+		includeSessions: options.ISR.IncludeSessions,
+	}
+}
+
+// regenResult 为一次重新生成的渲染结果，stored 表示已写入存储。
+type regenResult struct {
+	resp   pageResponse
+	stored bool
+}
+
+// storedPage 返回存储中的页面及缓存状态（HIT/STALE/MISS）；未启用 ISR、请求不适用（含带 session 的请求）、
+// 页面未生成且路由未声明 Revalidate 或生成的页面未能写入存储时返回 false，交给后续流程以真实请求渲染。
+func (h *pageHandler) storedPage(r *http.Request) (pageResponse, string, bool) {
+	s := h.isr
+	if s == nil || r.URL.RawQuery != "" || (r.Method != http.MethodGet && r.Method != http.MethodHead) {
+		return pageResponse{}, "", false
+	}
+	if !s.includeSessions && sessionStateFromRequest(r) != nil {
+		return pageResponse{}, "", false
+	}
+
+	page, err := s.store.Get(r.Context(), r.URL.Path)
+	if err == nil && !page.builtBy(h.build.Load().version) {
+		// SwapBuild 或热重载后旧构建生成的页面失效，与未生成的页面一样重新生成。
+		page, err = nil, fs.ErrNotExist
+	}
+	if err == nil {
+		if page.stale(time.Now()) {
+			// 后台生成在本次请求返回后才执行，而 r 可能随之被复用（如 gin 回收 Context），需先构造好生成用的请求。
+			req := h.isrRequest(context.WithoutCancel(r.Context()), r, r.URL.Path)
+			go func() {
+				if _, err := h.regenerate(req); err != nil {
+					log.Printf("isr regenerate failed path=%s err=%v", req.URL.Path, err)
+				}
+			}()
+			return page.response(), cacheStateStale, true
+		}
+		return page.response(), cacheStateHit, true
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		log.Printf("isr store get failed path=%s err=%v", r.URL.Path, err)
+		return pageResponse{}, "", false
+	}
+	if h.route(r).Revalidate <= 0 {
+		return pageResponse{}, "", false
+	}
+
+	result, err := h.regenerate(h.isrRequest(r.Context(), r, r.URL.Path))
+	if err != nil {
+		log.Printf("isr generate failed path=%s err=%v", r.URL.Path, err)
+	}
+	// 生成结果来自不带 cookie 的请求，未写入存储（重定向、404、渲染失败等）时不能代替本次请求的渲染。
+	if !result.stored {
+		return pageResponse{}, "", false
+	}
+	return result.resp, cacheStateMiss, true
+}
+
+// regenerate 以 isrRequest 构造的请求（不带 cookie 等用户信息）重新渲染页面，成功时写入存储，
+// 页面返回 404 时从存储删除。
+func (h *pageHandler) regenerate(req *http.Request) (regenResult, error) {
+	ctx, urlPath := req.Context(), req.URL.Path
+	v, err, _ := h.isr.flight.Do(urlPath, func() (any, error) {
+		build := h.build.Load().version
+		resp := h.render(req)
+		switch {
+		case resp.status == http.StatusNotFound:
+			return regenResult{resp: resp}, h.isr.store.Delete(ctx, urlPath)
+		case resp.redirect != "" || resp.status != http.StatusOK || !resp.cacheable:
+			return regenResult{resp: resp}, fmt.Errorf("page not cacheable (status %d)", resp.status)
+		}
+
+		page := &StoredPage{
+			Body:        []byte(resp.body),
+			Header:      resp.header.Clone(),
+			GeneratedAt: time.Now(),
+			Revalidate:  h.route(req).Revalidate,
+			Build:       build,
+		}
+		if err := h.isr.store.Put(ctx, urlPath, page); err != nil {
+			return regenResult{resp: resp}, err
+		}
+		return regenResult{resp: resp, stored: true}, nil
+	})
+	return v.(regenResult), err
+}
+
+// isrRequest 构造重新生成用的请求：只保留 Host/TLS（及受信任的 X-Forwarded-*），
+// 避免把触发请求的 cookie、session 渲染进共享页面。
+func (h *pageHandler) isrRequest(ctx context.Context, src *http.Request, urlPath string) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, urlPath, nil).WithContext(ctx)
+	if src == nil {
+		return req
+	}
+	req.Host, req.TLS = src.Host, src.TLS
+	if h.options.TrustForwardedHeaders {
+		for _, name := range []string{"X-Forwarded-Host", "X-Forwarded-Proto", "X-Forwarded-Port"} {
+			if v := src.Header.Get(name); v != "" {
+				req.Header.Set(name, v)
+			}
+		}
+	}
+	return req
+}
+
+// serveRevalidate 处理 POST /_ssr/revalidate?path=/a&path=/b，按页面返回 regenerated/removed 或错误原因，
+// 有页面失败时返回 500，失败页面保留旧内容。
+func (h *pageHandler) serveRevalidate(w http.ResponseWriter, r *http.Request) {
+	auth := r.Header.Get("Authorization")
+	token, ok := strings.CutPrefix(auth, "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(h.isr.token)) != 1 {
+		writeJSON(w, http.StatusUnauthorized, map[string]any{"error": "unauthorized"})
+		return
+	}
+
+	paths := r.URL.Query()["path"]
+	if len(paths) == 0 {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"error": "missing path"})
+		return
+	}
+
+	status := http.StatusOK
+	results := make(map[string]string, len(paths))
+	for _, raw := range paths {
+		if !strings.HasPrefix(raw, "/") {
+			status = http.StatusBadRequest
+			results[raw] = "invalid path"
+			continue
+		}
+		urlPath := path.Clean(raw)
+		result, err := h.regenerate(h.isrRequest(r.Context(), r, urlPath))
+		switch {
+		case err != nil:
+			status = http.StatusInternalServerError
+			results[urlPath] = err.Error()
+		case result.stored:
+			results[urlPath] = "regenerated"
+		default:
+			results[urlPath] = "removed"
+		}
+	}
+	writeJSON(w, status, map[string]any{"results": results})
+}
+
+func (p *StoredPage) stale(now time.Time) bool {
+	return p.Revalidate > 0 && now.After(p.GeneratedAt.Add(p.Revalidate))
+}
+
+// builtBy 判断页面是否可用于版本为 build 的当前构建，未记录构建版本的页面不校验。
+func (p *StoredPage) builtBy(build string) bool {
+	return p.Build == "" || p.Build == build
+}
+
+func (p *StoredPage) response() pageResponse {
+	return pageResponse{status: http.StatusOK, header: p.Header, body: string(p.Body), cacheable: true}
+}
+
+// memoryPageStore 是 PageStore 的内存实现，进程重启后需重新生成。
+type memoryPageStore struct {
+	mu    sync.RWMutex
+	pages map[string]*StoredPage
+}
+
+// NewMemoryPageStore 创建内存页面存储。
+func NewMemoryPageStore() PageStore {
+	return &memoryPageStore{pages: make(map[string]*StoredPage)}
+}
+
+func (m *memoryPageStore) Get(_ context.Context, urlPath string) (*StoredPage, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	page, ok := m.pages[path.Clean("/"+urlPath)]
+	if !ok {
+		return nil, fs.ErrNotExist
+	}
+	return page, nil
+}
+
+func (m *memoryPageStore) Put(_ context.Context, urlPath string, page *StoredPage) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.pages[path.Clean("/"+urlPath)] = page
+	return nil
+}
+
+func (m *memoryPageStore) Delete(_ context.Context, urlPath string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.pages, path.Clean("/"+urlPath))
+	return nil
+}
+
+// dirPageStore 把页面写为 <dir>/<path>/index.html，生成时间与间隔写在同目录的 index.meta.json。
+// 没有 meta 文件的页面（如手工放入的 HTML）以文件修改时间为生成时间且不自动重新生成。
+type dirPageStore struct {
+	dir string
+}
+
+type storedPageMeta struct {
+	GeneratedAt time.Time     `json:"generatedAt"`
+	Revalidate  time.Duration `json:"revalidate,omitempty"`
+	Header      http.Header   `json:"header,omitempty"`
+	Build       string        `json:"build,omitempty"`
+}
+
+// NewDirPageStore 创建目录页面存储，布局与 Prerender 输出及 WithPrerendered(os.DirFS(dir)) 一致。
+func NewDirPageStore(dir string) PageStore {
+	return &dirPageStore{dir: dir}
+}
+
+func (d *dirPageStore) Get(_ context.Context, urlPath string) (*StoredPage, error) {
+	htmlFile, metaFile := d.files(urlPath)
+	body, err := os.ReadFile(htmlFile)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &StoredPage{Body: body}
+	var meta storedPageMeta
+	switch data, err := os.ReadFile(metaFile); {
+	case err == nil:
+		if err := json.Unmarshal(data, &meta); err != nil {
+			return nil, fmt.Errorf("parse %s: %w", metaFile, err)
+		}
+		page.GeneratedAt, page.Revalidate, page.Header, page.Build = meta.GeneratedAt, meta.Revalidate, meta.Header, meta.Build
+	case errors.Is(err, fs.ErrNotExist):
+		if info, err := os.Stat(htmlFile); err == nil {
+			page.GeneratedAt = info.ModTime()
+		}
+	default:
+		return nil, err
+	}
+	return page, nil
+}
+
+func (d *dirPageStore) Put(_ context.Context, urlPath string, page *StoredPage) error {
+	htmlFile, metaFile := d.files(urlPath)
+	if err := os.MkdirAll(filepath.Dir(htmlFile), 0o755); err != nil {
+		return err
+	}
+
+	meta, err := json.Marshal(storedPageMeta{GeneratedAt: page.GeneratedAt, Revalidate: page.Revalidate, Header: page.Header, Build: page.Build})
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(metaFile, meta); err != nil {
+		return err
+	}
+	return writeFileAtomic(htmlFile, page.Body)
+}
+
+func (d *dirPageStore) Delete(_ context.Context, urlPath string) error {
+	htmlFile, metaFile := d.files(urlPath)
+	for _, file := range []string{htmlFile, metaFile} {
+		if err := os.Remove(file); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *dirPageStore) files(urlPath string) (string, string) {
+	htmlFile := filepath.Join(d.dir, filepath.FromSlash(prerenderFile(urlPath)))
+	return htmlFile, filepath.Join(filepath.Dir(htmlFile), "index.meta.json")
+}
+
+// writeFileAtomic 先写临时文件再 rename，读取方不会看到写了一半的页面。
+func writeFileAtomic(file string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(file), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = os.Remove(tmp.Name())
+	}()
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), file)
+}
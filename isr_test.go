@@ -0,0 +1,249 @@
+package gossr
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestISRServesStoredPagesAndRegenerates(t *testing.T) {
+	var renders atomic.Int64
+	withTestDataMux(t, func(*http.ServeMux) {
+		HandleData("GET /news", func(r *http.Request) (SSRPayload, error) {
+			user := ""
+			if cookie, err := r.Cookie("user"); err == nil {
+				user = cookie.Value
+			}
+			return mapPayload{"message": "news " + strconv.FormatInt(renders.Add(1), 10) + " user=" + user}, nil
+		})
+	})
+
+	store := NewMemoryPageStore()
+	server, err := NewHandler(testBuild(testMessageScript, nil), WithISR(ISROptions{Store: store}), Route("/news", Revalidate(time.Hour)))
+	if err != nil {
+		t.Fatalf("new handler: %v", err)
+	}
+	withUser := func(req *http.Request) {
+		req.AddCookie(&http.Cookie{Name: "user", Value: "alice"})
+	}
+
+	w := performRequest(server, http.MethodGet, "/news", withUser)
+	if w.Header().Get(cacheStatusHeader) != cacheStateMiss || !strings.Contains(w.Body.String(), "<p>news 1 user=</p>") {
+		t.Fatalf("expected first request to generate the page without user cookies, got %q %q", w.Header().Get(cacheStatusHeader), w.Body.String())
+	}
+	w = performRequest(server, http.MethodGet, "/news", nil)
+	if w.Header().Get(cacheStatusHeader) != cacheStateHit || !strings.Contains(w.Body.String(), "<p>news 1 user=</p>") {
+		t.Fatalf("expected stored page, got %q %q", w.Header().Get(cacheStatusHeader), w.Body.String())
+	}
+	if w := performRequest(server, http.MethodGet, "/news?ref=x", nil); w.Header().Get(cacheStatusHeader) != "" || !strings.Contains(w.Body.String(), "<p>news 2 user=</p>") {
+		t.Fatalf("expected query request to render live, got %q %q", w.Header().Get(cacheStatusHeader), w.Body.String())
+	}
+
+	page, err := store.Get(context.Background(), "/news")
+	if err != nil || page.Revalidate != time.Hour {
+		t.Fatalf("expected page stored with revalidate interval, got %+v %v", page, err)
+	}
+	expired := *page
+	expired.GeneratedAt = time.Now().Add(-2 * time.Hour)
+	if err := store.Put(context.Background(), "/news", &expired); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+
+	var served *http.Request
+	w = performRequest(server, http.MethodGet, "/news", func(req *http.Request) { served = req })
+	if w.Header().Get(cacheStatusHeader) != cacheStateStale || !strings.Contains(w.Body.String(), "<p>news 1 user=</p>") {
+		t.Fatalf("expected stale page served immediately, got %q %q", w.Header().Get(cacheStatusHeader), w.Body.String())
+	}
+	// 模拟 gin 在请求返回后复用 Context：后台生成不能再读取原请求。
+	served.URL.Path, served.Host, served.Header = "/recycled", "recycled.example", http.Header{}
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if page, err := store.Get(context.Background(), "/news"); err == nil && strings.Contains(string(page.Body), "<p>news 3 user=</p>") {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected stale page to be regenerated in the background")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if _, err := store.Get(context.Background(), "/recycled"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("expected background regeneration to use the original path, got %v", err)
+	}
+
+	if w := performRequest(server, http.MethodGet, "/other", nil); w.Header().Get(cacheStatusHeader) != "" {
+		t.Fatalf("expected route without Revalidate to render live, got %q", w.Header().Get(cacheStatusHeader))
+	}
+	if _, err := store.Get(context.Background(), "/other"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("expected route without Revalidate not to be stored, got %v", err)
+	}
+}
+
+func TestISRRendersSignedInRequestsLive(t *testing.T) {
+	withTestDataMux(t, func(*http.ServeMux) {
+		HandleData("GET /news", func(*http.Request) (SSRPayload, error) {
+			return mapPayload{"message": "news"}, nil
+		})
+	})
+	script := `globalThis.ssrRender = function(url) {
+		const session = __SSR_DATA__.session
+		if (url === "/account" && !session) return { html: "", redirect: "/login" }
+		return "<p>" + url + ":" + (session ? session.user.email : "anonymous") + "</p>"
+	}`
+	signedIn := func(req *http.Request) {
+		addSessionTokenCookie(req, mustSessionToken(t, map[string]any{"email": "a@example.com"}))
+	}
+
+	server, err := NewHandler(testBuild(script, nil), WithISR(ISROptions{}), Route("/*path", Revalidate(time.Hour)))
+	if err != nil {
+		t.Fatalf("new handler: %v", err)
+	}
+	if w := performRequest(server, http.MethodGet, "/news", nil); w.Header().Get(cacheStatusHeader) != cacheStateMiss {
+		t.Fatalf("expected anonymous request to generate the page, got %q", w.Header().Get(cacheStatusHeader))
+	}
+	w := performRequest(server, http.MethodGet, "/news", signedIn)
+	if w.Header().Get(cacheStatusHeader) != "" || !strings.Contains(w.Body.String(), "<p>/news:a@example.com</p>") {
+		t.Fatalf("expected signed-in request to render live, got %q %q", w.Header().Get(cacheStatusHeader), w.Body.String())
+	}
+
+	// 不带 cookie 的生成结果是重定向，未写入存储，请求按自身的 session 渲染。
+	w = performRequest(server, http.MethodGet, "/account", nil)
+	if w.Code != http.StatusFound || w.Header().Get(cacheStatusHeader) != "" {
+		t.Fatalf("expected live redirect for anonymous request, got %d %q", w.Code, w.Header().Get(cacheStatusHeader))
+	}
+
+	shared, err := NewHandler(testBuild(script, nil), WithISR(ISROptions{IncludeSessions: true}), Route("/*path", Revalidate(time.Hour)))
+	if err != nil {
+		t.Fatalf("new handler: %v", err)
+	}
+	performRequest(shared, http.MethodGet, "/news", nil)
+	if w := performRequest(shared, http.MethodGet, "/news", signedIn); w.Header().Get(cacheStatusHeader) != cacheStateHit {
+		t.Fatalf("expected IncludeSessions to serve the stored page, got %q", w.Header().Get(cacheStatusHeader))
+	}
+}
+
+func TestISRDropsPagesFromPreviousBuild(t *testing.T) {
+	server, err := NewHandler(testBuild(`globalThis.ssrRender = function() { return "<p>v1</p>" }`, nil),
+		WithISR(ISROptions{}), Route("/news", Revalidate(time.Hour)))
+	if err != nil {
+		t.Fatalf("new handler: %v", err)
+	}
+	if w := performRequest(server, http.MethodGet, "/news", nil); w.Header().Get(cacheStatusHeader) != cacheStateMiss {
+		t.Fatalf("expected page to be generated, got %q", w.Header().Get(cacheStatusHeader))
+	}
+
+	if err := server.SwapBuild(testBuild(`globalThis.ssrRender = function() { return "<p>v2</p>" }`, nil)); err != nil {
+		t.Fatalf("swap build: %v", err)
+	}
+	w := performRequest(server, http.MethodGet, "/news", nil)
+	if w.Header().Get(cacheStatusHeader) != cacheStateMiss || !strings.Contains(w.Body.String(), "<p>v2</p>") {
+		t.Fatalf("expected page from the previous build to be regenerated, got %q %q", w.Header().Get(cacheStatusHeader), w.Body.String())
+	}
+	if w := performRequest(server, http.MethodGet, "/news", nil); w.Header().Get(cacheStatusHeader) != cacheStateHit {
+		t.Fatalf("expected regenerated page to be stored, got %q", w.Header().Get(cacheStatusHeader))
+	}
+}
+
+func TestISRRevalidateEndpoint(t *testing.T) {
+	var renders atomic.Int64
+	withTestDataMux(t, func(*http.ServeMux) {
+		HandleData("GET /docs/{slug}", func(r *http.Request) (SSRPayload, error) {
+			return mapPayload{"message": r.PathValue("slug") + " v" + strconv.FormatInt(renders.Add(1), 10)}, nil
+		})
+	})
+
+	dir := t.TempDir()
+	result, err := Prerender(context.Background(), testBuild(testMessageScript, nil), PrerenderOptions{
+		OutDir: dir,
+		Params: map[string][]map[string]string{"/docs/:slug": {{"slug": "intro"}}},
+	})
+	if err != nil || len(result.Written) != 1 {
+		t.Fatalf("prerender: %v %v", result, err)
+	}
+
+	server, err := NewHandler(testBuild(testMessageScript, nil), WithISR(ISROptions{Store: NewDirPageStore(dir), RevalidateToken: "secret"}))
+	if err != nil {
+		t.Fatalf("new handler: %v", err)
+	}
+	if body := performRequest(server, http.MethodGet, "/docs/intro", nil).Body.String(); !strings.Contains(body, "<p>intro v1</p>") {
+		t.Fatalf("expected prerendered page, got %q", body)
+	}
+
+	revalidate := func(token string, paths ...string) *http.Response {
+		target := DefaultSSRRevalidateRoute + "?"
+		for _, p := range paths {
+			target += "path=" + p + "&"
+		}
+		w := performRequest(server, http.MethodPost, target, func(req *http.Request) {
+			req.Header.Set("Authorization", "Bearer "+token)
+		})
+		return w.Result()
+	}
+
+	if resp := revalidate("wrong", "/docs/intro"); resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected unauthorized, got %d", resp.StatusCode)
+	}
+	if resp := revalidate("secret", "docs"); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected invalid path to be rejected, got %d", resp.StatusCode)
+	}
+
+	resp := revalidate("secret", "/docs/intro", "/docs/new")
+	var body struct {
+		Results map[string]string `json:"results"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("revalidate: %d %v", resp.StatusCode, err)
+	}
+	if body.Results["/docs/intro"] != "regenerated" || body.Results["/docs/new"] != "regenerated" {
+		t.Fatalf("expected both pages regenerated, got %v", body.Results)
+	}
+	if body := performRequest(server, http.MethodGet, "/docs/intro", nil).Body.String(); !strings.Contains(body, "<p>intro v2</p>") {
+		t.Fatalf("expected regenerated page, got %q", body)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "docs", "new", "index.html")); err != nil {
+		t.Fatalf("expected on-demand page written to the store: %v", err)
+	}
+}
+
+func TestDirPageStore(t *testing.T) {
+	dir := t.TempDir()
+	store := NewDirPageStore(dir)
+	ctx := context.Background()
+
+	if _, err := store.Get(ctx, "/missing"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("expected not exist, got %v", err)
+	}
+
+	generated := time.Now().Add(-2 * time.Minute).Round(time.Second)
+	header := http.Header{"X-Page": {"1"}}
+	if err := store.Put(ctx, "/a/b", &StoredPage{Body: []byte("<p>a</p>"), Header: header, GeneratedAt: generated, Revalidate: time.Minute}); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	page, err := store.Get(ctx, "/a/b/")
+	if err != nil || string(page.Body) != "<p>a</p>" || !page.GeneratedAt.Equal(generated) || page.Revalidate != time.Minute || page.Header.Get("X-Page") != "1" {
+		t.Fatalf("unexpected page %+v %v", page, err)
+	}
+	if !page.stale(time.Now()) {
+		t.Fatal("expected page past its revalidate interval to be stale")
+	}
+
+	writeBuildFile(t, dir, "static/index.html", "<p>static</p>")
+	if page, err := store.Get(ctx, "/static"); err != nil || page.Revalidate != 0 || page.GeneratedAt.IsZero() || page.stale(time.Now()) {
+		t.Fatalf("expected page without metadata to never expire, got %+v %v", page, err)
+	}
+
+	if err := store.Delete(ctx, "/a/b"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, err := store.Get(ctx, "/a/b"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("expected deleted page, got %v", err)
+	}
+}
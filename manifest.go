@@ -0,0 +1,162 @@
+package gossr
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"log"
+	"path"
+	"strings"
+)
+
+// Vite 构建产物中的 manifest 位置，Vite 5 起默认写入 .vite/ 目录。
+var (
+	ssrManifestPaths   = []string{".vite/ssr-manifest.json", "ssr-manifest.json"}
+	buildManifestPaths = []string{".vite/manifest.json", "manifest.json"}
+)
+
+// manifestChunk 是 Vite manifest.json 中的单个条目。
+type manifestChunk struct {
+	File    string   `json:"file"`
+	CSS     []string `json:"css"`
+	Assets  []string `json:"assets"`
+	Imports []string `json:"imports"`
+	IsEntry bool     `json:"isEntry"`
+}
+
+// assetManifest 汇总 ssr-manifest.json（模块 ID -> 资源）与 manifest.json（源文件 -> chunk），
+// 按渲染时用到的模块生成预加载链接。
+type assetManifest struct {
+	ssr   map[string][]string
+	build map[string]manifestChunk
+}
+
+// loadAssetManifest 从前端产物中读取 Vite manifest，两者都不存在时返回 nil。
+func loadAssetManifest(dist fs.FS) *assetManifest {
+	if dist == nil {
+		return nil
+	}
+
+	m := &assetManifest{}
+	if err := readManifestJSON(dist, ssrManifestPaths, &m.ssr); err != nil {
+		log.Printf("ignore invalid ssr manifest: %v", err)
+	}
+	if err := readManifestJSON(dist, buildManifestPaths, &m.build); err != nil {
+		log.Printf("ignore invalid build manifest: %v", err)
+	}
+	if len(m.ssr) == 0 && len(m.build) == 0 {
+		return nil
+	}
+	return m
+}
+
+func readManifestJSON(dist fs.FS, candidates []string, out any) error {
+	for _, name := range candidates {
+		data, err := fs.ReadFile(dist, name)
+		if err != nil {
+			continue
+		}
+		if err := json.Unmarshal(data, out); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		return nil
+	}
+	return nil
+}
+
+// preloadLinks 返回 modules 对应资源的 <link> 标签：CSS 为 stylesheet，JS 为 modulepreload，字体为 preload。
+// index.html 中已引用的资源（入口脚本与样式）会被跳过。
+func (m *assetManifest) preloadLinks(modules []string, indexHTML string) string {
+	if m == nil || len(modules) == 0 {
+		return ""
+	}
+
+	seen := make(map[string]struct{})
+	var files []string
+	add := func(file string) {
+		file = assetURL(file)
+		if _, ok := seen[file]; ok || file == "/" {
+			return
+		}
+		seen[file] = struct{}{}
+		if strings.Contains(indexHTML, `"`+file+`"`) {
+			return
+		}
+		files = append(files, file)
+	}
+
+	visited := make(map[string]struct{})
+	for _, id := range modules {
+		for _, file := range m.ssr[id] {
+			add(file)
+		}
+		m.collectChunk(id, visited, true, add)
+	}
+
+	var css, scripts, fonts strings.Builder
+	for _, file := range files {
+		href := template.HTMLEscapeString(file)
+		switch ext := strings.ToLower(path.Ext(file)); ext {
+		case ".css":
+			fmt.Fprintf(&css, `<link rel="stylesheet" href="%s">`+"\n", href)
+		case ".js", ".mjs":
+			fmt.Fprintf(&scripts, `<link rel="modulepreload" crossorigin href="%s">`+"\n", href)
+		case ".woff", ".woff2", ".ttf", ".otf":
+			fmt.Fprintf(&fonts, `<link rel="preload" href="%s" as="font" type="font/%s" crossorigin>`+"\n", href, ext[1:])
+		}
+	}
+	return css.String() + scripts.String() + fonts.String()
+}
+
+// entryFiles 返回 manifest.json 中入口 chunk 及其静态 imports 的 JS/CSS 文件（站点绝对路径）。
+func (m *assetManifest) entryFiles() []string {
+	if m == nil {
+		return nil
+	}
+
+	var files []string
+	visited := make(map[string]struct{})
+	for _, key := range sortedKeys(m.build) {
+		if m.build[key].IsEntry {
+			m.collectChunk(key, visited, false, func(file string) {
+				files = append(files, assetURL(file))
+			})
+		}
+	}
+	return files
+}
+
+// collectChunk 递归展开 chunk 的 file、css、imports（withAssets 时包含 assets），每个 chunk 只访问一次。
+func (m *assetManifest) collectChunk(key string, visited map[string]struct{}, withAssets bool, add func(string)) {
+	if _, ok := visited[key]; ok {
+		return
+	}
+	visited[key] = struct{}{}
+
+	chunk, ok := m.build[key]
+	if !ok {
+		return
+	}
+	add(chunk.File)
+	for _, css := range chunk.CSS {
+		add(css)
+	}
+	if withAssets {
+		for _, asset := range chunk.Assets {
+			add(asset)
+		}
+	}
+	for _, imported := range chunk.Imports {
+		m.collectChunk(imported, visited, withAssets, add)
+	}
+}
+
+// assetURL 把 manifest 中的相对路径转为站点绝对路径，ssr-manifest 中的路径已带 base。
+func assetURL(file string) string {
+	file = strings.TrimSpace(file)
+	if strings.HasPrefix(file, "/") || strings.Contains(file, "://") {
+		return file
+	}
+	return "/" + file
+}
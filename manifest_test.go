@@ -0,0 +1,76 @@
+package gossr
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/gin-gonic/gin"
+)
+
+func testManifestDistFS() fstest.MapFS {
+	return fstest.MapFS{
+		"index.html": {Data: []byte(`<html><head><script type="module" crossorigin src="/assets/index-a1.js"></script><link rel="stylesheet" crossorigin href="/assets/index-a1.css"></head><body><div id="app"><!--app-html--></div></body></html>`)},
+		".vite/ssr-manifest.json": {Data: []byte(`{
+			"src/pages/about.vue": ["/assets/about-b2.js", "/assets/about-b2.css"],
+			"src/main.ts": ["/assets/index-a1.js", "/assets/index-a1.css"]
+		}`)},
+		".vite/manifest.json": {Data: []byte(`{
+			"src/pages/about.vue": {"file": "assets/about-b2.js", "css": ["assets/about-b2.css"], "imports": ["_shared-c3.js"]},
+			"_shared-c3.js": {"file": "assets/shared-c3.js", "assets": ["assets/inter-d4.woff2", "assets/logo-e5.png"], "imports": ["src/pages/about.vue"]}
+		}`)},
+	}
+}
+
+func TestAssetManifestPreloadLinks(t *testing.T) {
+	dist := testManifestDistFS()
+	m := loadAssetManifest(dist)
+	if m == nil {
+		t.Fatal("expected manifest to be loaded")
+	}
+
+	indexHTML := string(dist["index.html"].Data)
+	got := m.preloadLinks([]string{"src/main.ts", "src/pages/about.vue", "unknown"}, indexHTML)
+	want := `<link rel="stylesheet" href="/assets/about-b2.css">
+<link rel="modulepreload" crossorigin href="/assets/about-b2.js">
+<link rel="modulepreload" crossorigin href="/assets/shared-c3.js">
+<link rel="preload" href="/assets/inter-d4.woff2" as="font" type="font/woff2" crossorigin>
+`
+	if got != want {
+		t.Fatalf("unexpected preload links:\n%s\nwant:\n%s", got, want)
+	}
+
+	if links := m.preloadLinks(nil, indexHTML); links != "" {
+		t.Fatalf("expected no links without modules, got %q", links)
+	}
+	if loadAssetManifest(fstest.MapFS{"index.html": {}}) != nil {
+		t.Fatal("expected nil manifest when dist has no manifest files")
+	}
+}
+
+func TestRunBlockingInjectsManifestPreloadLinks(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	RunBlocking(router, FrontendBuild{
+		FrontendDist: testManifestDistFS(),
+		ServerDist: fstest.MapFS{
+			"server.js": {Data: []byte(`globalThis.ssrRender = function(url) {
+				return { html: "<p>about</p>", head: "<title>About</title>", modules: ["src/pages/about.vue"] }
+			}`)},
+		},
+	}, nil)
+
+	body := performRequest(router, http.MethodGet, "/about", nil).Body.String()
+	links := `<link rel="stylesheet" href="/assets/about-b2.css">`
+	if !strings.Contains(body, links) {
+		t.Fatalf("expected route css link, got %s", body)
+	}
+	if strings.Index(body, links) > strings.Index(body, "<title>About</title>") {
+		t.Fatalf("expected preload links before rendered head, got %s", body)
+	}
+	if strings.Count(body, "/assets/index-a1.js") != 1 {
+		t.Fatalf("expected entry chunk not to be preloaded twice, got %s", body)
+	}
+}
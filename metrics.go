@@ -0,0 +1,375 @@
+package gossr
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/daodao97/gossr/renderer"
+)
+
+const (
+	renderResultOK      = "ok"
+	renderResultError   = "error"
+	renderResultTimeout = "timeout"
+	renderResultPanic   = "panic"
+
+	fallbackReasonRender = "render"
+	fallbackReasonData   = "data"
+	fallbackReasonIsland = "island"
+)
+
+// Metrics 接收 gossr 的运行指标。默认实现为 NewPrometheusMetrics，也可自行实现对接其他系统。
+// 所有方法都可能被并发调用。
+type Metrics interface {
+	// ObserveRender 记录一次渲染（含等待并发名额）的耗时，result 为 ok/error/timeout/panic。
+	ObserveRender(engine string, result string, d time.Duration)
+	// ObserveIslandRender 记录一次命名出口的渲染耗时，island 为出口名，result 取值同 ObserveRender；
+	// island 渲染不计入 ObserveRender。
+	ObserveIslandRender(island string, result string, d time.Duration)
+	// IncFallback 记录一次降级输出，reason 为 render（渲染失败）、data（流式模式下取数失败）或 island（命名出口渲染失败）。
+	IncFallback(reason string)
+	// ObserveDataFetch 记录一次 SsrEngine 数据 handler 的耗时，route 为 gin 路由模式。
+	ObserveDataFetch(route string, status int, d time.Duration)
+	// AddRenderQueueDepth 在等待并发名额的请求数变化时调用。
+	AddRenderQueueDepth(delta int)
+	// ObserveRenderQueueWait 记录等待并发名额的耗时。
+	ObserveRenderQueueWait(d time.Duration)
+	// RegisterPool 注册一个引擎池，采集时调用 stats 读取快照。每个构建（含多个 App 与 SwapBuild
+	// 之后的新构建）各注册一次，构建退役时调用返回的 unregister。
+	RegisterPool(engine string, stats func() renderer.PoolStats) (unregister func())
+}
+
+// WithMetrics 设置指标接收器，nil 表示不采集。
+func WithMetrics(m Metrics) Option {
+	return func(opts *Options) {
+		opts.Metrics = m
+	}
+}
+
+type noopMetrics struct{}
+
+func (noopMetrics) ObserveRender(string, string, time.Duration)           {}
+func (noopMetrics) ObserveIslandRender(string, string, time.Duration)     {}
+func (noopMetrics) IncFallback(string)                                    {}
+func (noopMetrics) ObserveDataFetch(string, int, time.Duration)           {}
+func (noopMetrics) AddRenderQueueDepth(int)                               {}
+func (noopMetrics) ObserveRenderQueueWait(time.Duration)                  {}
+func (noopMetrics) RegisterPool(string, func() renderer.PoolStats) func() { return func() {} }
+
+func metricsOrNoop(m Metrics) Metrics {
+	if m == nil {
+		return noopMetrics{}
+	}
+	return m
+}
+
+// renderResult 把渲染错误归类为指标中的 result 标签。
+func renderResult(err error) string {
+	switch {
+	case err == nil:
+		return renderResultOK
+	case errors.Is(err, errRenderTimeout):
+		return renderResultTimeout
+	case errors.Is(err, errRenderPanic):
+		return renderResultPanic
+	default:
+		return renderResultError
+	}
+}
+
+// defaultDurationBuckets 为耗时直方图的默认分桶（秒）。
+var defaultDurationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// PrometheusMetrics 是 Metrics 的内置实现，同时是输出 Prometheus 文本格式的 http.Handler，
+// 可直接挂载到路由：router.GET("/metrics", gin.WrapH(m))。
+type PrometheusMetrics struct {
+	renderDuration *histogramVec
+	renderTimeouts *counterVec
+	renderPanics   *counterVec
+	islandDuration *histogramVec
+	fallbacks      *counterVec
+	dataFetch      *histogramVec
+	queueWait      *histogramVec
+
+	mu         sync.Mutex
+	queueDepth int64
+	pools      map[uint64]registeredPool
+	nextPool   uint64
+}
+
+// registeredPool 为 RegisterPool 登记的引擎池，id 作为 pool 标签区分同一引擎的多个池。
+type registeredPool struct {
+	id     uint64
+	engine string
+	stats  func() renderer.PoolStats
+}
+
+// NewPrometheusMetrics 创建内置的 Prometheus 指标实现。
+func NewPrometheusMetrics() *PrometheusMetrics {
+	return &PrometheusMetrics{
+		renderDuration: newHistogramVec("gossr_render_duration_seconds", "SSR render duration including render slot wait.", defaultDurationBuckets, "engine", "result"),
+		renderTimeouts: newCounterVec("gossr_render_timeouts_total", "SSR renders that exceeded the render timeout.", "engine"),
+		renderPanics:   newCounterVec("gossr_render_panics_total", "SSR renders that panicked.", "engine"),
+		islandDuration: newHistogramVec("gossr_island_render_duration_seconds", "SSR island render duration.", defaultDurationBuckets, "island", "result"),
+		fallbacks:      newCounterVec("gossr_render_fallbacks_total", "Responses served with the fallback page.", "reason"),
+		dataFetch:      newHistogramVec("gossr_data_fetch_duration_seconds", "SsrEngine data handler duration.", defaultDurationBuckets, "route", "status"),
+		queueWait:      newHistogramVec("gossr_render_queue_wait_seconds", "Time spent waiting for a render slot.", defaultDurationBuckets),
+		pools:          make(map[uint64]registeredPool),
+	}
+}
+
+func (m *PrometheusMetrics) ObserveRender(engine string, result string, d time.Duration) {
+	m.renderDuration.observe(d.Seconds(), engine, result)
+	switch result {
+	case renderResultTimeout:
+		m.renderTimeouts.inc(engine)
+	case renderResultPanic:
+		m.renderPanics.inc(engine)
+	}
+}
+
+func (m *PrometheusMetrics) ObserveIslandRender(island string, result string, d time.Duration) {
+	m.islandDuration.observe(d.Seconds(), island, result)
+}
+
+func (m *PrometheusMetrics) IncFallback(reason string) {
+	m.fallbacks.inc(reason)
+}
+
+func (m *PrometheusMetrics) ObserveDataFetch(route string, status int, d time.Duration) {
+	m.dataFetch.observe(d.Seconds(), route, strconv.Itoa(status))
+}
+
+func (m *PrometheusMetrics) AddRenderQueueDepth(delta int) {
+	m.mu.Lock()
+	m.queueDepth += int64(delta)
+	m.mu.Unlock()
+}
+
+func (m *PrometheusMetrics) ObserveRenderQueueWait(d time.Duration) {
+	m.queueWait.observe(d.Seconds())
+}
+
+func (m *PrometheusMetrics) RegisterPool(engine string, stats func() renderer.PoolStats) func() {
+	m.mu.Lock()
+	m.nextPool++
+	id := m.nextPool
+	m.pools[id] = registeredPool{id: id, engine: engine, stats: stats}
+	m.mu.Unlock()
+
+	return func() {
+		m.mu.Lock()
+		delete(m.pools, id)
+		m.mu.Unlock()
+	}
+}
+
+// ServeHTTP 以 Prometheus 文本格式输出全部指标。
+func (m *PrometheusMetrics) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_ = m.writeText(w)
+}
+
+// writeText 把全部指标按 Prometheus 文本格式写入 w。
+func (m *PrometheusMetrics) writeText(w io.Writer) error {
+	var b strings.Builder
+	m.renderDuration.write(&b)
+	m.renderTimeouts.write(&b)
+	m.renderPanics.write(&b)
+	m.islandDuration.write(&b)
+	m.fallbacks.write(&b)
+	m.dataFetch.write(&b)
+	m.queueWait.write(&b)
+
+	m.mu.Lock()
+	depth := m.queueDepth
+	pools := make([]registeredPool, 0, len(m.pools))
+	for _, pool := range m.pools {
+		pools = append(pools, pool)
+	}
+	sort.Slice(pools, func(i, j int) bool {
+		if pools[i].engine != pools[j].engine {
+			return pools[i].engine < pools[j].engine
+		}
+		return pools[i].id < pools[j].id
+	})
+	stats := make([]renderer.PoolStats, len(pools))
+	for i, pool := range pools {
+		stats[i] = pool.stats()
+	}
+	m.mu.Unlock()
+
+	writeMetricHeader(&b, "gossr_render_queue_depth", "Requests currently waiting for a render slot.", "gauge")
+	fmt.Fprintf(&b, "gossr_render_queue_depth %d\n", depth)
+
+	poolMetrics := []struct {
+		name, help, kind string
+		value            func(renderer.PoolStats) float64
+	}{
+		{"gossr_pool_size", "Runtimes currently created by the engine pool.", "gauge", func(s renderer.PoolStats) float64 { return float64(s.Size) }},
+		{"gossr_pool_max_size", "Engine pool capacity.", "gauge", func(s renderer.PoolStats) float64 { return float64(s.MaxSize) }},
+		{"gossr_pool_idle", "Idle runtimes in the engine pool.", "gauge", func(s renderer.PoolStats) float64 { return float64(s.Idle) }},
+		{"gossr_pool_waits_total", "Pool acquisitions that had to wait for a runtime.", "counter", func(s renderer.PoolStats) float64 { return float64(s.Waits) }},
+		{"gossr_pool_wait_timeouts_total", "Pool acquisitions that timed out.", "counter", func(s renderer.PoolStats) float64 { return float64(s.WaitTimeouts) }},
+	}
+	for _, pm := range poolMetrics {
+		if len(pools) == 0 {
+			break
+		}
+		writeMetricHeader(&b, pm.name, pm.help, pm.kind)
+		for i, pool := range pools {
+			labels := formatLabels([]string{"engine", "pool"}, []string{pool.engine, strconv.FormatUint(pool.id, 10)})
+			fmt.Fprintf(&b, "%s%s %s\n", pm.name, labels, formatMetricValue(pm.value(stats[i])))
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+type counterVec struct {
+	name, help string
+	labels     []string
+
+	mu     sync.Mutex
+	values map[string]*counterEntry
+}
+
+type counterEntry struct {
+	labelValues []string
+	value       float64
+}
+
+func newCounterVec(name, help string, labels ...string) *counterVec {
+	return &counterVec{name: name, help: help, labels: labels, values: make(map[string]*counterEntry)}
+}
+
+func (c *counterVec) inc(labelValues ...string) {
+	key := strings.Join(labelValues, "\xff")
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.values[key]
+	if !ok {
+		entry = &counterEntry{labelValues: labelValues}
+		c.values[key] = entry
+	}
+	entry.value++
+}
+
+func (c *counterVec) write(b *strings.Builder) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	writeMetricHeader(b, c.name, c.help, "counter")
+	for _, key := range sortedKeys(c.values) {
+		entry := c.values[key]
+		fmt.Fprintf(b, "%s%s %s\n", c.name, formatLabels(c.labels, entry.labelValues), formatMetricValue(entry.value))
+	}
+}
+
+type histogramVec struct {
+	name, help string
+	labels     []string
+	buckets    []float64
+
+	mu     sync.Mutex
+	values map[string]*histogramEntry
+}
+
+type histogramEntry struct {
+	labelValues []string
+	counts      []uint64
+	count       uint64
+	sum         float64
+}
+
+func newHistogramVec(name, help string, buckets []float64, labels ...string) *histogramVec {
+	return &histogramVec{name: name, help: help, labels: labels, buckets: buckets, values: make(map[string]*histogramEntry)}
+}
+
+func (h *histogramVec) observe(value float64, labelValues ...string) {
+	key := strings.Join(labelValues, "\xff")
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	entry, ok := h.values[key]
+	if !ok {
+		entry = &histogramEntry{labelValues: labelValues, counts: make([]uint64, len(h.buckets))}
+		h.values[key] = entry
+	}
+	for i, upper := range h.buckets {
+		if value <= upper {
+			entry.counts[i]++
+		}
+	}
+	entry.count++
+	entry.sum += value
+}
+
+func (h *histogramVec) write(b *strings.Builder) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	writeMetricHeader(b, h.name, h.help, "histogram")
+	bucketLabels := append(append([]string(nil), h.labels...), "le")
+	for _, key := range sortedKeys(h.values) {
+		entry := h.values[key]
+		for i, upper := range h.buckets {
+			values := append(append([]string(nil), entry.labelValues...), formatMetricValue(upper))
+			fmt.Fprintf(b, "%s_bucket%s %d\n", h.name, formatLabels(bucketLabels, values), entry.counts[i])
+		}
+		values := append(append([]string(nil), entry.labelValues...), "+Inf")
+		fmt.Fprintf(b, "%s_bucket%s %d\n", h.name, formatLabels(bucketLabels, values), entry.count)
+		fmt.Fprintf(b, "%s_sum%s %s\n", h.name, formatLabels(h.labels, entry.labelValues), formatMetricValue(entry.sum))
+		fmt.Fprintf(b, "%s_count%s %d\n", h.name, formatLabels(h.labels, entry.labelValues), entry.count)
+	}
+}
+
+func writeMetricHeader(b *strings.Builder, name, help, kind string) {
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+func formatLabels(names []string, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+
+	parts := make([]string, len(names))
+	for i, name := range names {
+		value := ""
+		if i < len(values) {
+			value = values[i]
+		}
+		parts[i] = name + `="` + escapeLabelValue(value) + `"`
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+func escapeLabelValue(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+func formatMetricValue(value float64) string {
+	if math.IsInf(value, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
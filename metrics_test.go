@@ -0,0 +1,185 @@
+package gossr
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/daodao97/gossr/renderer"
+)
+
+func scrapeMetrics(t *testing.T, m *PrometheusMetrics) string {
+	t.Helper()
+
+	w := performRequest(m, http.MethodGet, "/metrics", nil)
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Fatalf("unexpected metrics content type %q", ct)
+	}
+	return w.Body.String()
+}
+
+func assertMetricLine(t *testing.T, body string, line string) {
+	t.Helper()
+	for _, got := range strings.Split(body, "\n") {
+		if got == line {
+			return
+		}
+	}
+	t.Fatalf("expected metric line %q in:\n%s", line, body)
+}
+
+func TestRunBlockingRecordsRenderMetrics(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	withTestSSREngine(t, func(engine *gin.Engine) {
+		engine.GET("/hello/:name", WrapSSR(func(c *gin.Context) (SSRPayload, error) {
+			return mapPayload{"name": c.Param("name")}, nil
+		}))
+	})
+
+	metrics := NewPrometheusMetrics()
+	router := gin.New()
+	RunBlocking(router, FrontendBuild{
+		FrontendDist: testFrontendDistFS(),
+		ServerDist: fstest.MapFS{
+			"server.js": {Data: []byte(`globalThis.ssrRender = async function(url) {
+				if (url === "/boom") throw new Error("boom")
+				const data = await (await fetch("/_ssr/data/hello/gossr")).json()
+				return "<p>" + data.name + "</p>"
+			}`)},
+		},
+	}, nil, WithMetrics(metrics), WithRenderLimit(1))
+	router.GET("/metrics", gin.WrapH(metrics))
+
+	if w := performRequest(router, http.MethodGet, "/page", nil); !strings.Contains(w.Body.String(), "<p>gossr</p>") {
+		t.Fatalf("unexpected page body %s", w.Body.String())
+	}
+	captureLogOutput(t, func() {
+		performRequest(router, http.MethodGet, "/boom", nil)
+	})
+
+	body := scrapeMetrics(t, metrics)
+	assertMetricLine(t, body, `gossr_render_duration_seconds_count{engine="goja",result="ok"} 1`)
+	assertMetricLine(t, body, `gossr_render_duration_seconds_count{engine="goja",result="error"} 1`)
+	assertMetricLine(t, body, `gossr_render_fallbacks_total{reason="render"} 1`)
+	assertMetricLine(t, body, `gossr_data_fetch_duration_seconds_count{route="/hello/:name",status="200"} 1`)
+	assertMetricLine(t, body, `gossr_render_queue_wait_seconds_count 2`)
+	assertMetricLine(t, body, `gossr_render_queue_depth 0`)
+	if !strings.Contains(body, `gossr_pool_max_size{engine="goja",pool="1"} `) || !strings.Contains(body, `gossr_pool_waits_total{engine="goja",pool="1"} `) {
+		t.Fatalf("expected pool metrics in:\n%s", body)
+	}
+}
+
+func TestPoolMetricsPerBuild(t *testing.T) {
+	metrics := NewPrometheusMetrics()
+	script := `globalThis.ssrRender = function(url) { return "<p>" + url + "</p>" }`
+	first, err := NewHandler(testBuild(script, nil), WithMetrics(metrics))
+	if err != nil {
+		t.Fatalf("new handler: %v", err)
+	}
+	if _, err := NewHandler(testBuild(script, nil), WithMetrics(metrics)); err != nil {
+		t.Fatalf("new handler: %v", err)
+	}
+	body := scrapeMetrics(t, metrics)
+	for _, want := range []string{`gossr_pool_max_size{engine="goja",pool="1"} `, `gossr_pool_max_size{engine="goja",pool="2"} `} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("expected each handler to report its own pool %q in:\n%s", want, body)
+		}
+	}
+
+	if err := first.SwapBuild(testBuild(script, nil)); err != nil {
+		t.Fatalf("swap build: %v", err)
+	}
+	// 旧构建在后台退役，退役后其池不再上报。
+	deadline := time.Now().Add(time.Second)
+	for {
+		body = scrapeMetrics(t, metrics)
+		if !strings.Contains(body, `pool="1"`) && strings.Contains(body, `gossr_pool_max_size{engine="goja",pool="3"} `) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected retired pool to be unregistered and the new one reported, got:\n%s", body)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestRunWithRenderSlotClassifiesOutcomes(t *testing.T) {
+	metrics := NewPrometheusMetrics()
+	h := &pageHandler{
+		options: newOptions(WithRenderTimeout(20 * time.Millisecond)),
+		metrics: metrics,
+		engine:  "test",
+	}
+
+	_, err := h.runRender(context.Background(), h.options.RenderTimeout, func(ctx context.Context) (renderer.Result, error) {
+		<-ctx.Done()
+		return renderer.Result{}, ctx.Err()
+	})
+	if err == nil || !strings.Contains(err.Error(), "render timeout after") {
+		t.Fatalf("expected timeout error, got %v", err)
+	}
+
+	_, err = h.runRender(context.Background(), h.options.RenderTimeout, func(context.Context) (renderer.Result, error) {
+		panic("kaboom")
+	})
+	if err == nil || err.Error() != "panic: kaboom" {
+		t.Fatalf("expected panic error, got %v", err)
+	}
+
+	_, err = h.runRender(context.Background(), h.options.RenderTimeout, func(context.Context) (renderer.Result, error) {
+		return renderer.Result{}, renderer.ErrStreamingUnsupported
+	})
+	if !errors.Is(err, renderer.ErrStreamingUnsupported) {
+		t.Fatalf("expected streaming unsupported error, got %v", err)
+	}
+
+	body := scrapeMetrics(t, metrics)
+	assertMetricLine(t, body, `gossr_render_timeouts_total{engine="test"} 1`)
+	assertMetricLine(t, body, `gossr_render_panics_total{engine="test"} 1`)
+	assertMetricLine(t, body, `gossr_render_duration_seconds_count{engine="test",result="timeout"} 1`)
+	assertMetricLine(t, body, `gossr_render_duration_seconds_count{engine="test",result="panic"} 1`)
+	if strings.Contains(body, `result="error"`) {
+		t.Fatalf("streaming unsupported must not be recorded as a render error:\n%s", body)
+	}
+}
+
+func TestAcquireRenderSlotTracksQueueDepth(t *testing.T) {
+	metrics := NewPrometheusMetrics()
+	sem := make(chan struct{}, 1)
+	sem <- struct{}{}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- acquireRenderSlot(context.Background(), sem, metrics)
+	}()
+
+	deadline := time.Now().Add(time.Second)
+	for !strings.Contains(scrapeMetrics(t, metrics), "gossr_render_queue_depth 1\n") {
+		if time.Now().After(deadline) {
+			t.Fatal("expected queued render to be counted")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	<-sem
+	if err := <-done; err != nil {
+		t.Fatalf("acquireRenderSlot returned %v", err)
+	}
+	assertMetricLine(t, scrapeMetrics(t, metrics), "gossr_render_queue_depth 0")
+}
+
+func TestPrometheusMetricsEscapesLabels(t *testing.T) {
+	metrics := NewPrometheusMetrics()
+	metrics.ObserveDataFetch("/a\"b\\c\nd", 500, 30*time.Millisecond)
+
+	body := scrapeMetrics(t, metrics)
+	assertMetricLine(t, body, `gossr_data_fetch_duration_seconds_bucket{route="/a\"b\\c\nd",status="500",le="0.025"} 0`)
+	assertMetricLine(t, body, `gossr_data_fetch_duration_seconds_bucket{route="/a\"b\\c\nd",status="500",le="0.05"} 1`)
+	assertMetricLine(t, body, `gossr_data_fetch_duration_seconds_bucket{route="/a\"b\\c\nd",status="500",le="+Inf"} 1`)
+}
@@ -0,0 +1,502 @@
+package gossr
+
+import (
+	"context"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/daodao97/gossr/renderer"
+)
+
+const (
+	defaultDevServerURL  = "http://127.0.0.1:3333"
+	defaultRenderTimeout = 3 * time.Second
+)
+
+// Options 汇总 gossr 的全部运行配置，由 Ssr/RunBlocking 显式接收。
+// 同一进程内可为不同应用或测试用例传入不同的 Options，互不影响。
+type Options struct {
+	// DevMode 开启后非数据请求会被代理到 DevServerURL。
+	DevMode      bool
+	DevServerURL string
+	// DevSSR 在 DevMode 下经 Vite 模块图执行 SSR（需启用 gossr-dev Vite 插件），资源与 HMR 请求仍代理，见 WithDevSSR。
+	DevSSR bool
+
+	// Engine 选择渲染引擎：goja（默认）或 v8，nov8 构建下忽略。
+	Engine string
+	// RenderTimeout 单次渲染（含等待并发名额）的超时时间，<=0 时使用 3s。
+	RenderTimeout time.Duration
+	// IslandTimeouts 按命名出口覆盖 island 的渲染超时，见 WithIslandTimeout。
+	IslandTimeouts map[string]time.Duration
+	// RenderLimit 并发渲染上限，0 表示不限制，超过 1024 会被 clamp。
+	RenderLimit int
+
+	// FetchToken 非空时 /_ssr/data 请求必须携带 X-SSR-Token。
+	FetchToken string
+	// AllowUnsafeFetchHeader 允许仅凭 X-SSR-Fetch: 1 绕过同源校验（仅兼容用途）。
+	AllowUnsafeFetchHeader bool
+	// TrustForwardedHeaders 信任 X-Forwarded-Host/Proto/Port。
+	TrustForwardedHeaders bool
+	// ExposeHandlerErrors 让 WrapSSR 返回原始错误文本，仅 DevMode 下生效。
+	ExposeHandlerErrors bool
+	// EnablePprof 挂载 /debug/pprof。
+	EnablePprof bool
+	// WatchDir 非空时从该目录（含 client/ 与 server/）读取构建产物并在变化时热重载，见 WithWatch。
+	WatchDir string
+	// WatchLayout 为 WatchDir 下的目录与脚本布局，零值使用 client/、server/ 与 server.js。
+	WatchLayout Layout
+	// Prerendered 非 nil 时优先返回 Prerender 生成的静态页面，见 WithPrerendered。
+	Prerendered fs.FS
+	// ISR 非 nil 时从页面存储返回预渲染页面并按 Revalidate 重新生成，见 WithISR。
+	ISR *ISROptions
+	// BasePath 非空时（如 /admin）应用挂在该路径前缀下：请求去掉前缀后再路由，
+	// 站内重定向自动补上前缀，见 WithBasePath。
+	BasePath string
+	// AssetGracePeriod 为 SwapBuild 后旧构建静态资源的保留时长，<=0 时使用 10 分钟，见 WithAssetGracePeriod。
+	AssetGracePeriod time.Duration
+
+	// RequestHeaders 透传给 ssrRender(url, request).headers 的请求头白名单。
+	RequestHeaders []string
+	// RequestCookies 透传给 ssrRender(url, request).cookies 的 cookie 白名单，默认为空。
+	RequestCookies []string
+
+	// Routes 按页面路由模式覆盖渲染超时、关闭 SSR 或声明缓存规则，见 Route。
+	Routes map[string]RouteOptions
+
+	// Cache 非 nil 时启用整页 HTML 缓存，见 WithRenderCache。
+	Cache *CacheOptions
+	// HTTPCache 非 nil 时按路由为页面设置 Cache-Control 并支持 ETag/304，见 WithHTTPCache。
+	HTTPCache *HTTPCacheOptions
+	// Compression 非 nil 时按 Accept-Encoding 压缩渲染后的 HTML，见 WithCompression。
+	Compression *CompressionOptions
+	// Hints 非 nil 时按路由发送 103 Early Hints 或 Link 响应头，见 WithAssetHints。
+	Hints *HintsOptions
+	// Fetch 配置 SSR 脚本中 fetch() 可访问的外部地址，见 WithFetch。
+	Fetch FetchOptions
+	// Streaming 开启后先输出 index.html 中 <!--app-html--> 之前的部分，再流式输出
+	// ssrRenderStream 的结果；命中缓存规则的请求及未提供 ssrRenderStream 的脚本仍走缓冲渲染。
+	Streaming bool
+
+	// Console 配置 SSR 脚本中 console.* 的输出（slog Logger 与限流），默认写入 slog.Default()。
+	Console renderer.ConsoleConfig
+
+	// Metrics 非 nil 时记录渲染、数据取数与引擎池指标，见 NewPrometheusMetrics。
+	Metrics Metrics
+	// Tracer 非 nil 时为取数、排队、取池、JS 执行与 HTML 注入记录 span，并传播 W3C traceparent，
+	// 见 gossr/oteltrace。
+	Tracer renderer.Tracer
+
+	GojaPool renderer.PoolConfig
+	V8Pool   renderer.PoolConfig
+
+	// app 为 New 创建的应用实例，数据路由走它的 Mux/Engine；nil 时使用包级 DataMux 与 SsrEngine。
+	app *App
+	// fromEnv 标记配置由 OptionsFromEnv 构造，用于判断历史环境变量是否被忽略。
+	fromEnv bool
+}
+
+// Option 以函数式方式修改 Options。
+type Option func(*Options)
+
+// DefaultOptions 返回不依赖环境变量的默认配置。
+func DefaultOptions() Options {
+	return Options{
+		DevServerURL:   defaultDevServerURL,
+		RenderTimeout:  defaultRenderTimeout,
+		RenderLimit:    runtime.GOMAXPROCS(0),
+		RequestHeaders: []string{"Accept-Language", "User-Agent", "Referer"},
+	}
+}
+
+// OptionsFromEnv 按历史环境变量（DEV_MODE、SSR_RENDER_LIMIT 等）构造配置。
+func OptionsFromEnv() Options {
+	opts := DefaultOptions()
+	opts.DevMode = isDevMode()
+	opts.DevServerURL = devServerURL()
+	opts.DevSSR = envEnabled("DEV_SSR")
+	opts.Engine = strings.ToLower(strings.TrimSpace(os.Getenv("SSR_ENGINE")))
+	opts.RenderLimit = renderConcurrencyLimit()
+	opts.FetchToken = strings.TrimSpace(os.Getenv("SSR_FETCH_TOKEN"))
+	opts.AllowUnsafeFetchHeader = allowUnsafeSSRFetchHeaderBypass()
+	opts.TrustForwardedHeaders = trustForwardedHeaders()
+	opts.ExposeHandlerErrors = exposeSSRErrors()
+	opts.EnablePprof = isPprofEnabled()
+	opts.Streaming = envEnabled("SSR_STREAMING")
+	opts.WatchDir = strings.TrimSpace(os.Getenv("SSR_WATCH_DIR"))
+	opts.AssetGracePeriod = durationFromEnv("SSR_ASSET_GRACE_PERIOD")
+	if dir := strings.TrimSpace(os.Getenv("SSR_PRERENDER_DIR")); dir != "" {
+		opts.Prerendered = os.DirFS(dir)
+	}
+	if dir, token := strings.TrimSpace(os.Getenv("SSR_ISR_DIR")), strings.TrimSpace(os.Getenv("SSR_REVALIDATE_TOKEN")); dir != "" || token != "" {
+		opts.ISR = &ISROptions{RevalidateToken: token}
+		if dir != "" {
+			opts.ISR.Store = NewDirPageStore(dir)
+		}
+	}
+	opts.Fetch.AllowedHosts = fetchAllowedHostsFromEnv()
+	opts.GojaPool = renderer.PoolConfig{
+		Size:    poolSizeFromEnv("GOJA_POOL_SIZE"),
+		Timeout: poolTimeoutFromEnv("GOJA_POOL_TIMEOUT"),
+	}
+	opts.V8Pool = renderer.PoolConfig{
+		Size:    poolSizeFromEnv("V8_POOL_SIZE"),
+		Timeout: poolTimeoutFromEnv("V8_POOL_TIMEOUT"),
+	}
+	opts.fromEnv = true
+	return opts
+}
+
+// legacyEnvVars 为 OptionsFromEnv 读取的环境变量，旧版本的入口会直接读取它们。
+var legacyEnvVars = []string{
+	"DEV_MODE", "DEV_SERVER_URL", "DEV_SSR", "SSR_ENGINE", "SSR_RENDER_LIMIT",
+	"SSR_FETCH_TOKEN", "SSR_ALLOW_UNSAFE_FETCH_HEADER", "TRUST_FORWARDED_HEADERS",
+	"SSR_EXPOSE_HANDLER_ERROR", "ENABLE_PPROF", "SSR_STREAMING", "SSR_WATCH_DIR",
+	"SSR_ASSET_GRACE_PERIOD", "SSR_PRERENDER_DIR", "SSR_ISR_DIR", "SSR_REVALIDATE_TOKEN",
+	"SSR_FETCH_ALLOWED_HOSTS", "GOJA_POOL_SIZE", "GOJA_POOL_TIMEOUT", "V8_POOL_SIZE", "V8_POOL_TIMEOUT",
+}
+
+var warnIgnoredEnvOnce sync.Once
+
+// ignoredEnvVars 返回已设置、但因配置不是由 OptionsFromEnv 构造而不会生效的环境变量。
+func ignoredEnvVars(options Options) []string {
+	if options.fromEnv {
+		return nil
+	}
+	var ignored []string
+	for _, name := range legacyEnvVars {
+		if strings.TrimSpace(os.Getenv(name)) != "" {
+			ignored = append(ignored, name)
+		}
+	}
+	return ignored
+}
+
+// warnIgnoredEnv 在进程内首次启动时提示被忽略的环境变量，避免升级后 SSR_FETCH_TOKEN 等配置静默失效。
+func warnIgnoredEnv(options Options) {
+	ignored := ignoredEnvVars(options)
+	if len(ignored) == 0 {
+		return
+	}
+	warnIgnoredEnvOnce.Do(func() {
+		log.Printf("WARNING: environment variables %s are set but ignored; pass gossr.WithOptions(gossr.OptionsFromEnv()) to apply them", strings.Join(ignored, ", "))
+	})
+}
+
+// WithOptions 整体替换配置，常与 OptionsFromEnv 搭配使用。
+func WithOptions(o Options) Option {
+	return func(opts *Options) {
+		*opts = o
+	}
+}
+
+// WithDevMode 开启或关闭开发模式。
+func WithDevMode(enabled bool) Option {
+	return func(opts *Options) {
+		opts.DevMode = enabled
+	}
+}
+
+// WithDevServerURL 设置开发模式的代理地址。
+func WithDevServerURL(rawURL string) Option {
+	return func(opts *Options) {
+		opts.DevServerURL = rawURL
+	}
+}
+
+// WithEngine 选择渲染引擎（goja / v8）。
+func WithEngine(engine string) Option {
+	return func(opts *Options) {
+		opts.Engine = strings.ToLower(strings.TrimSpace(engine))
+	}
+}
+
+// WithRenderTimeout 设置单次渲染超时。
+func WithRenderTimeout(timeout time.Duration) Option {
+	return func(opts *Options) {
+		opts.RenderTimeout = timeout
+	}
+}
+
+// WithRenderLimit 设置并发渲染上限，0 表示不限制。
+func WithRenderLimit(limit int) Option {
+	return func(opts *Options) {
+		opts.RenderLimit = limit
+	}
+}
+
+// WithFetchToken 设置 /_ssr/data 共享 token。
+func WithFetchToken(token string) Option {
+	return func(opts *Options) {
+		opts.FetchToken = strings.TrimSpace(token)
+	}
+}
+
+// WithUnsafeFetchHeaderBypass 允许 X-SSR-Fetch: 1 绕过同源校验（不推荐生产开启）。
+func WithUnsafeFetchHeaderBypass(enabled bool) Option {
+	return func(opts *Options) {
+		opts.AllowUnsafeFetchHeader = enabled
+	}
+}
+
+// WithTrustForwardedHeaders 设置是否信任 X-Forwarded-* 头。
+func WithTrustForwardedHeaders(enabled bool) Option {
+	return func(opts *Options) {
+		opts.TrustForwardedHeaders = enabled
+	}
+}
+
+// WithExposeHandlerErrors 设置 WrapSSR 是否返回原始错误（仅 DevMode 生效）。
+func WithExposeHandlerErrors(enabled bool) Option {
+	return func(opts *Options) {
+		opts.ExposeHandlerErrors = enabled
+	}
+}
+
+// WithPprof 设置是否挂载 /debug/pprof。
+func WithPprof(enabled bool) Option {
+	return func(opts *Options) {
+		opts.EnablePprof = enabled
+	}
+}
+
+// WithRequestHeaders 设置透传给 ssrRender 的请求头白名单。
+func WithRequestHeaders(names ...string) Option {
+	return func(opts *Options) {
+		opts.RequestHeaders = append([]string(nil), names...)
+	}
+}
+
+// WithRequestCookies 设置透传给 ssrRender 的 cookie 白名单。
+func WithRequestCookies(names ...string) Option {
+	return func(opts *Options) {
+		opts.RequestCookies = append([]string(nil), names...)
+	}
+}
+
+// WithStreaming 设置是否启用流式 SSR 输出。
+func WithStreaming(enabled bool) Option {
+	return func(opts *Options) {
+		opts.Streaming = enabled
+	}
+}
+
+// WithConsole 设置 SSR 脚本中 console.* 的输出配置。
+func WithConsole(cfg renderer.ConsoleConfig) Option {
+	return func(opts *Options) {
+		opts.Console = cfg
+	}
+}
+
+// WithTracer 设置链路追踪实现，nil 表示不追踪。
+func WithTracer(tracer renderer.Tracer) Option {
+	return func(opts *Options) {
+		opts.Tracer = tracer
+	}
+}
+
+// WithBasePath 设置应用的路径前缀，挂到 http.ServeMux 时使用以 / 结尾的同名模式：
+//
+//	mux.Handle("/admin/", admin)
+func WithBasePath(prefix string) Option {
+	return func(opts *Options) {
+		opts.BasePath = prefix
+	}
+}
+
+// WithGojaPool 设置 goja runtime 池配置。
+func WithGojaPool(cfg renderer.PoolConfig) Option {
+	return func(opts *Options) {
+		opts.GojaPool = cfg
+	}
+}
+
+// WithV8Pool 设置 v8 isolate 池配置。
+func WithV8Pool(cfg renderer.PoolConfig) Option {
+	return func(opts *Options) {
+		opts.V8Pool = cfg
+	}
+}
+
+func newOptions(opts ...Option) Options {
+	options := DefaultOptions()
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&options)
+		}
+	}
+
+	if strings.TrimSpace(options.DevServerURL) == "" {
+		options.DevServerURL = defaultDevServerURL
+	}
+	if options.RenderTimeout <= 0 {
+		options.RenderTimeout = defaultRenderTimeout
+	}
+	options.BasePath = cleanBasePath(options.BasePath)
+
+	return options
+}
+
+// cleanBasePath 把路径前缀规范为 /admin 形式，根路径返回空串。
+func cleanBasePath(prefix string) string {
+	prefix = strings.Trim(strings.TrimSpace(prefix), "/")
+	if prefix == "" {
+		return ""
+	}
+	return path.Clean("/" + prefix)
+}
+
+type optionsContextKey struct{}
+
+// contextWithOptions 把配置挂到 ctx 上，供经由 SsrEngine 执行的 WrapSSR handler 读取。
+func contextWithOptions(ctx context.Context, opts Options) context.Context {
+	return context.WithValue(ctx, optionsContextKey{}, opts)
+}
+
+func optionsFromContext(ctx context.Context) Options {
+	if ctx != nil {
+		if opts, ok := ctx.Value(optionsContextKey{}).(Options); ok {
+			return opts
+		}
+	}
+	return DefaultOptions()
+}
+
+func envEnabled(name string) bool {
+	switch strings.ToLower(strings.TrimSpace(os.Getenv(name))) {
+	case "1", "true", "yes", "on":
+		return true
+	default:
+		return false
+	}
+}
+
+func isDevMode() bool {
+	switch strings.ToLower(strings.TrimSpace(os.Getenv("DEV_MODE"))) {
+	case "1", "true", "yes", "on", "dev":
+		return true
+	default:
+		return false
+	}
+}
+
+func devServerURL() string {
+	if raw := strings.TrimSpace(os.Getenv("DEV_SERVER_URL")); raw != "" {
+		return raw
+	}
+
+	return defaultDevServerURL
+}
+
+func trustForwardedHeaders() bool {
+	return envEnabled("TRUST_FORWARDED_HEADERS")
+}
+
+func allowUnsafeSSRFetchHeaderBypass() bool {
+	return envEnabled("SSR_ALLOW_UNSAFE_FETCH_HEADER")
+}
+
+func exposeSSRErrors() bool {
+	return envEnabled("SSR_EXPOSE_HANDLER_ERROR")
+}
+
+func isPprofEnabled() bool {
+	if raw := strings.TrimSpace(os.Getenv("ENABLE_PPROF")); raw != "" {
+		return envEnabled("ENABLE_PPROF")
+	}
+	return isDevMode()
+}
+
+func renderConcurrencyLimit() int {
+	defaultLimit := runtime.GOMAXPROCS(0)
+
+	if raw := strings.TrimSpace(os.Getenv("SSR_RENDER_LIMIT")); raw != "" {
+		v, err := strconv.Atoi(raw)
+		if err != nil || v < 0 {
+			log.Printf("config: invalid SSR_RENDER_LIMIT=%q, fallback to %d", raw, defaultLimit)
+			return defaultLimit
+		}
+
+		if v == 0 {
+			log.Printf("config: SSR_RENDER_LIMIT=0 (unlimited)")
+			return 0
+		}
+
+		if v > maxSSRRenderLimit {
+			log.Printf("config: SSR_RENDER_LIMIT=%d exceeds max %d, clamped", v, maxSSRRenderLimit)
+			return maxSSRRenderLimit
+		}
+
+		return v
+	}
+
+	return defaultLimit
+}
+
+// poolSizeFromEnv 读取池大小，未设置或非法时返回 0（交给引擎使用默认值）。
+// fetchAllowedHostsFromEnv 读取逗号分隔的 SSR_FETCH_ALLOWED_HOSTS。
+func fetchAllowedHostsFromEnv() []string {
+	var hosts []string
+	for _, host := range strings.Split(os.Getenv("SSR_FETCH_ALLOWED_HOSTS"), ",") {
+		if host = strings.TrimSpace(host); host != "" {
+			hosts = append(hosts, host)
+		}
+	}
+	return hosts
+}
+
+func poolSizeFromEnv(name string) int {
+	raw := strings.TrimSpace(os.Getenv(name))
+	if raw == "" {
+		return 0
+	}
+
+	size, err := strconv.Atoi(raw)
+	if err != nil || size <= 0 {
+		log.Printf("config: invalid %s=%q, use default", name, raw)
+		return 0
+	}
+	return size
+}
+
+// durationFromEnv 读取时长配置，未设置或非法时返回 0（使用默认值）。
+func durationFromEnv(name string) time.Duration {
+	raw := strings.TrimSpace(os.Getenv(name))
+	if raw == "" {
+		return 0
+	}
+
+	d, err := time.ParseDuration(raw)
+	if err != nil {
+		log.Printf("config: invalid %s=%q, use default", name, raw)
+		return 0
+	}
+	return d
+}
+
+// poolTimeoutFromEnv 读取池获取超时，未设置或非法时返回 0（默认值），
+// 0 或负值按不设超时处理。
+func poolTimeoutFromEnv(name string) time.Duration {
+	raw := strings.TrimSpace(os.Getenv(name))
+	if raw == "" {
+		return 0
+	}
+
+	timeout, err := time.ParseDuration(raw)
+	if err != nil {
+		log.Printf("config: invalid %s=%q, use default", name, raw)
+		return 0
+	}
+
+	if timeout <= 0 {
+		log.Printf("config: %s=%q is not positive, pool wait timeout disabled", name, raw)
+		return renderer.NoPoolTimeout
+	}
+	return timeout
+}
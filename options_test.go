@@ -0,0 +1,115 @@
+package gossr
+
+import (
+	"reflect"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/daodao97/gossr/renderer"
+)
+
+func TestNewOptionsDefaultsIgnoreEnv(t *testing.T) {
+	t.Setenv("DEV_MODE", "1")
+	t.Setenv("SSR_FETCH_TOKEN", "env-token")
+	t.Setenv("TRUST_FORWARDED_HEADERS", "1")
+
+	opts := newOptions()
+	if opts.DevMode || opts.FetchToken != "" || opts.TrustForwardedHeaders {
+		t.Fatalf("expected defaults to ignore env, got %+v", opts)
+	}
+	if opts.RenderTimeout != defaultRenderTimeout {
+		t.Fatalf("expected default render timeout %s, got %s", defaultRenderTimeout, opts.RenderTimeout)
+	}
+	if opts.RenderLimit != runtime.GOMAXPROCS(0) {
+		t.Fatalf("expected default render limit %d, got %d", runtime.GOMAXPROCS(0), opts.RenderLimit)
+	}
+	if opts.DevServerURL != defaultDevServerURL {
+		t.Fatalf("expected default dev server url, got %q", opts.DevServerURL)
+	}
+}
+
+func TestNewOptionsAppliesFunctionalOptions(t *testing.T) {
+	opts := newOptions(
+		WithOptions(Options{DevServerURL: "http://vite:5173"}),
+		WithRenderTimeout(5*time.Second),
+		WithRenderLimit(0),
+		WithFetchToken(" secret "),
+		WithEngine(" V8 "),
+		WithGojaPool(renderer.PoolConfig{Size: 16}),
+	)
+
+	if opts.DevServerURL != "http://vite:5173" {
+		t.Fatalf("unexpected dev server url %q", opts.DevServerURL)
+	}
+	if opts.RenderTimeout != 5*time.Second {
+		t.Fatalf("unexpected render timeout %s", opts.RenderTimeout)
+	}
+	if opts.RenderLimit != 0 {
+		t.Fatalf("expected unlimited render limit, got %d", opts.RenderLimit)
+	}
+	if opts.FetchToken != "secret" {
+		t.Fatalf("expected trimmed fetch token, got %q", opts.FetchToken)
+	}
+	if opts.Engine != "v8" {
+		t.Fatalf("expected normalized engine, got %q", opts.Engine)
+	}
+	if opts.GojaPool.Size != 16 {
+		t.Fatalf("expected goja pool size 16, got %d", opts.GojaPool.Size)
+	}
+}
+
+func TestOptionsFromEnv(t *testing.T) {
+	t.Setenv("DEV_MODE", "dev")
+	t.Setenv("DEV_SERVER_URL", "http://127.0.0.1:5173")
+	t.Setenv("SSR_ENGINE", "V8")
+	t.Setenv("SSR_RENDER_LIMIT", "4")
+	t.Setenv("SSR_FETCH_TOKEN", " token ")
+	t.Setenv("SSR_ALLOW_UNSAFE_FETCH_HEADER", "yes")
+	t.Setenv("TRUST_FORWARDED_HEADERS", "on")
+	t.Setenv("SSR_EXPOSE_HANDLER_ERROR", "1")
+	t.Setenv("ENABLE_PPROF", "")
+	t.Setenv("GOJA_POOL_SIZE", "abc")
+	t.Setenv("GOJA_POOL_TIMEOUT", "250ms")
+	t.Setenv("V8_POOL_SIZE", "64")
+	t.Setenv("V8_POOL_TIMEOUT", "-1s")
+	t.Setenv("SSR_FETCH_ALLOWED_HOSTS", "api.example.com, ,*.cdn.example.com")
+
+	var opts Options
+	captureLogOutput(t, func() {
+		opts = OptionsFromEnv()
+	})
+
+	want := Options{
+		DevMode:                true,
+		DevServerURL:           "http://127.0.0.1:5173",
+		Engine:                 "v8",
+		RenderTimeout:          defaultRenderTimeout,
+		RenderLimit:            4,
+		FetchToken:             "token",
+		AllowUnsafeFetchHeader: true,
+		TrustForwardedHeaders:  true,
+		ExposeHandlerErrors:    true,
+		EnablePprof:            true,
+		RequestHeaders:         DefaultOptions().RequestHeaders,
+		Fetch:                  FetchOptions{AllowedHosts: []string{"api.example.com", "*.cdn.example.com"}},
+		GojaPool:               renderer.PoolConfig{Size: 0, Timeout: 250 * time.Millisecond},
+		V8Pool:                 renderer.PoolConfig{Size: 64, Timeout: renderer.NoPoolTimeout},
+		fromEnv:                true,
+	}
+	if !reflect.DeepEqual(opts, want) {
+		t.Fatalf("OptionsFromEnv()=%+v, want %+v", opts, want)
+	}
+}
+
+func TestIgnoredEnvVars(t *testing.T) {
+	t.Setenv("SSR_FETCH_TOKEN", "token")
+	t.Setenv("DEV_MODE", "")
+
+	if got := ignoredEnvVars(newOptions(WithRenderLimit(2))); !reflect.DeepEqual(got, []string{"SSR_FETCH_TOKEN"}) {
+		t.Fatalf("expected SSR_FETCH_TOKEN to be reported as ignored, got %v", got)
+	}
+	if got := ignoredEnvVars(newOptions(WithOptions(OptionsFromEnv()), WithRenderLimit(2))); got != nil {
+		t.Fatalf("expected no warning when options come from env, got %v", got)
+	}
+}
@@ -0,0 +1,123 @@
+// Package oteltrace 把 gossr 的链路追踪接入 OpenTelemetry。
+//
+//	gossr.Ssr(r, web.Dist, gossr.WithTracer(oteltrace.New()))
+package oteltrace
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/daodao97/gossr/renderer"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/daodao97/gossr"
+
+// Option 调整 Tracer 的 TracerProvider 与传播格式。
+type Option func(*Tracer)
+
+// WithTracerProvider 指定 TracerProvider，默认使用 otel.GetTracerProvider()。
+func WithTracerProvider(provider trace.TracerProvider) Option {
+	return func(t *Tracer) {
+		t.provider = provider
+	}
+}
+
+// WithPropagator 指定链路传播格式，默认使用 W3C Trace Context（traceparent/tracestate）。
+func WithPropagator(propagator propagation.TextMapPropagator) Option {
+	return func(t *Tracer) {
+		t.propagator = propagator
+	}
+}
+
+// Tracer 实现 renderer.Tracer。
+type Tracer struct {
+	provider   trace.TracerProvider
+	propagator propagation.TextMapPropagator
+	tracer     trace.Tracer
+}
+
+var _ renderer.Tracer = (*Tracer)(nil)
+
+// New 创建基于 OpenTelemetry 的 Tracer。
+func New(opts ...Option) *Tracer {
+	t := &Tracer{}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(t)
+		}
+	}
+	if t.provider == nil {
+		t.provider = otel.GetTracerProvider()
+	}
+	if t.propagator == nil {
+		t.propagator = propagation.TraceContext{}
+	}
+	t.tracer = t.provider.Tracer(instrumentationName)
+	return t
+}
+
+// Start 开始一个 span；页面 span 为 server 类型，出站 fetch 为 client 类型，其余为 internal。
+func (t *Tracer) Start(ctx context.Context, name string) (context.Context, renderer.Span) {
+	kind := trace.SpanKindInternal
+	switch name {
+	case renderer.SpanPage:
+		kind = trace.SpanKindServer
+	case renderer.SpanFetch:
+		kind = trace.SpanKindClient
+	}
+
+	ctx, span := t.tracer.Start(ctx, name, trace.WithSpanKind(kind))
+	return ctx, otelSpan{span}
+}
+
+// Extract 从请求头恢复上游链路。
+func (t *Tracer) Extract(ctx context.Context, header http.Header) context.Context {
+	return t.propagator.Extract(ctx, propagation.HeaderCarrier(header))
+}
+
+// Inject 把当前链路写入请求头。
+func (t *Tracer) Inject(ctx context.Context, header http.Header) {
+	t.propagator.Inject(ctx, propagation.HeaderCarrier(header))
+}
+
+type otelSpan struct {
+	span trace.Span
+}
+
+func (s otelSpan) SetAttribute(key string, value any) {
+	s.span.SetAttributes(attributeOf(key, value))
+}
+
+func (s otelSpan) RecordError(err error) {
+	s.span.RecordError(err)
+	s.span.SetStatus(codes.Error, err.Error())
+}
+
+func (s otelSpan) End() {
+	s.span.End()
+}
+
+func attributeOf(key string, value any) attribute.KeyValue {
+	switch v := value.(type) {
+	case string:
+		return attribute.String(key, v)
+	case bool:
+		return attribute.Bool(key, v)
+	case int:
+		return attribute.Int(key, v)
+	case int64:
+		return attribute.Int64(key, v)
+	case float64:
+		return attribute.Float64(key, v)
+	case fmt.Stringer:
+		return attribute.String(key, v.String())
+	default:
+		return attribute.String(key, fmt.Sprint(v))
+	}
+}
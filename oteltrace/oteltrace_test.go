@@ -0,0 +1,108 @@
+package oteltrace_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/daodao97/gossr"
+	"github.com/daodao97/gossr/oteltrace"
+	"github.com/daodao97/gossr/renderer"
+	"github.com/gin-gonic/gin"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const incomingTraceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+func TestTracerRecordsPageSpansAndPropagatesTraceparent(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	outbound := make(chan string, 1)
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		outbound <- r.Header.Get("traceparent")
+		_, _ = w.Write([]byte(`"ok"`))
+	}))
+	defer api.Close()
+
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	router := gin.New()
+	gossr.RunBlocking(router, gossr.FrontendBuild{
+		FrontendDist: fstest.MapFS{
+			"index.html": {Data: []byte(`<html><head></head><body><div id="app"><!--app-html--></div></body></html>`)},
+		},
+		ServerDist: fstest.MapFS{
+			"server.js": {Data: []byte(`globalThis.ssrRender = async function(url) {
+				return await (await fetch("` + api.URL + `/data")).text()
+			}`)},
+		},
+	}, func(context.Context, *http.Request) (gossr.SSRPayload, error) {
+		return nil, nil
+	},
+		gossr.WithTracer(oteltrace.New(oteltrace.WithTracerProvider(provider))),
+		gossr.WithFetch(gossr.FetchOptions{AllowedHosts: []string{strings.TrimPrefix(api.URL, "http://")}}),
+		gossr.WithRenderLimit(1),
+	)
+
+	req := httptest.NewRequest(http.MethodGet, "/page", nil)
+	req.Header.Set("traceparent", incomingTraceparent)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if !strings.Contains(w.Body.String(), `"ok"`) {
+		t.Fatalf("unexpected page body %s", w.Body.String())
+	}
+
+	spans := map[string]sdktrace.ReadOnlySpan{}
+	for _, span := range recorder.Ended() {
+		spans[span.Name()] = span
+	}
+
+	page, ok := spans[renderer.SpanPage]
+	if !ok {
+		t.Fatalf("missing page span, got %v", spanNames(recorder.Ended()))
+	}
+	if got := page.SpanContext().TraceID().String(); got != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Fatalf("expected page span to continue incoming trace, got %s", got)
+	}
+	if page.SpanKind() != trace.SpanKindServer {
+		t.Fatalf("expected server span kind, got %v", page.SpanKind())
+	}
+
+	for _, name := range []string{renderer.SpanDataFetch, renderer.SpanRenderQueue, renderer.SpanPoolAcquire, renderer.SpanJSExecute, renderer.SpanInject} {
+		span, ok := spans[name]
+		if !ok {
+			t.Fatalf("missing %s span, got %v", name, spanNames(recorder.Ended()))
+		}
+		if span.Parent().SpanID() != page.SpanContext().SpanID() {
+			t.Fatalf("expected %s to be a child of the page span", name)
+		}
+	}
+
+	fetchSpan, ok := spans[renderer.SpanFetch]
+	if !ok {
+		t.Fatalf("missing fetch span, got %v", spanNames(recorder.Ended()))
+	}
+	if fetchSpan.Parent().SpanID() != spans[renderer.SpanJSExecute].SpanContext().SpanID() {
+		t.Fatal("expected fetch span to be a child of the js execution span")
+	}
+
+	got := <-outbound
+	want := "00-4bf92f3577b34da6a3ce929d0e0e4736-" + fetchSpan.SpanContext().SpanID().String() + "-01"
+	if got != want {
+		t.Fatalf("expected outbound traceparent %q, got %q", want, got)
+	}
+}
+
+func spanNames(spans []sdktrace.ReadOnlySpan) []string {
+	names := make([]string, 0, len(spans))
+	for _, span := range spans {
+		names = append(names, span.Name())
+	}
+	return names
+}
@@ -0,0 +1,389 @@
+package gossr
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/daodao97/gossr/locales"
+)
+
+const defaultPrerenderOrigin = "http://localhost"
+
+// servemuxParamPattern 匹配 ServeMux 路由模式中的 {name} 与 {name...} 参数段。
+var servemuxParamPattern = regexp.MustCompile(`^\{([A-Za-z_][A-Za-z0-9_]*)(\.\.\.)?\}$`)
+
+// PrerenderOptions 配置 Prerender 生成哪些页面及输出位置。
+type PrerenderOptions struct {
+	// OutDir 为输出目录，页面写为 <path>/index.html（"/" 写为 index.html）。
+	OutDir string
+	// Store 非 nil 时页面写入该存储（如 WithISR 使用的存储），忽略 OutDir。
+	Store PageStore
+	// Origin 为离线请求使用的站点地址，决定 payload.siteOrigin，默认 http://localhost。
+	Origin string
+	// Locales 非空时为每个页面额外生成 /<locale> 前缀版本，已注册的同名路由会去重。
+	Locales []string
+	// Params 为带参数的路由提供取值，key 为 gin 写法的路由模式（/hi/:name、/docs/*path），
+	// ServeMux 模式（/hi/{name}）按相同写法匹配；带 locale 前缀的路由也会查找去掉前缀后的模式。
+	// 每组取值生成一个页面，没有取值的参数路由会被跳过。
+	Params map[string][]map[string]string
+	// Paths 为额外生成的具体路径（如直接注册在 DataMux 上、无法枚举的路由）。
+	Paths []string
+	// Exclude 中的路由模式命中的页面不生成，适用于依赖 cookie 或实时数据的页面。
+	Exclude []string
+}
+
+// PrerenderResult 为一次 Prerender 的结果。
+type PrerenderResult struct {
+	// Written 为已写出的页面路径。
+	Written []string
+	// Skipped 为未生成的路由或页面及原因。
+	Skipped []PrerenderSkip
+}
+
+// PrerenderSkip 记录一个未生成的路由或页面。
+type PrerenderSkip struct {
+	Route  string
+	Reason string
+}
+
+var (
+	dataRoutesMu sync.Mutex
+	// dataRoutes 记录经 HandleData 注册的路由模式，供 Prerender 枚举。
+	dataRoutes []string
+)
+
+// WithPrerendered 设置 Prerender 的输出，页面请求（GET/HEAD 且不带 query）命中时直接返回预渲染的 HTML，
+// 未命中时回退到实时 SSR。
+func WithPrerendered(fsys fs.FS) Option {
+	return func(opts *Options) {
+		opts.Prerendered = fsys
+	}
+}
+
+// Prerender 离线执行 取数据 -> 渲染 -> 注入 流程，把 SsrEngine 与 HandleData 注册的 GET 路由写为静态 HTML，
+// 页面带上路由声明的 Revalidate 间隔，供 WithISR 到期后重新生成。
+// 渲染失败、非 200、重定向或设置了 Cookie 的页面不会写出，记录在 Skipped 中。
+func Prerender(ctx context.Context, build FrontendBuild, popts PrerenderOptions, opts ...Option) (PrerenderResult, error) {
+	return prerender(ctx, build, popts, newOptions(opts...))
+}
+
+// prerender 实现 Prerender，数据路由与枚举的页面路由来自 options.app。
+func prerender(ctx context.Context, build FrontendBuild, popts PrerenderOptions, options Options) (PrerenderResult, error) {
+	var result PrerenderResult
+	store := popts.Store
+	if store == nil {
+		if popts.OutDir == "" {
+			return result, errors.New("prerender: OutDir or Store is required")
+		}
+		store = NewDirPageStore(popts.OutDir)
+	}
+	origin := strings.TrimRight(popts.Origin, "/")
+	if origin == "" {
+		origin = defaultPrerenderOrigin
+	}
+	if u, err := url.Parse(origin); err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return result, fmt.Errorf("prerender: invalid origin %q", popts.Origin)
+	}
+
+	b, err := loadPageBuild(build, options)
+	if err != nil {
+		return result, fmt.Errorf("prerender: %w", err)
+	}
+	defer b.retire()
+
+	pages := newPageHandler(options, dataFetcher(options))
+	pages.build.Store(b)
+
+	paths, skipped := prerenderPaths(options.app, popts)
+	result.Skipped = skipped
+	for _, p := range paths {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+
+		req := httptest.NewRequest(http.MethodGet, origin+p, nil).WithContext(ctx)
+		resp := pages.render(req)
+		switch {
+		case resp.redirect != "":
+			result.Skipped = append(result.Skipped, PrerenderSkip{Route: p, Reason: "redirect to " + resp.redirect})
+			continue
+		case resp.status != http.StatusOK:
+			result.Skipped = append(result.Skipped, PrerenderSkip{Route: p, Reason: fmt.Sprintf("status %d", resp.status)})
+			continue
+		case !resp.cacheable:
+			result.Skipped = append(result.Skipped, PrerenderSkip{Route: p, Reason: "render failed or response sets cookies"})
+			continue
+		}
+
+		page := &StoredPage{
+			Body:        []byte(resp.body),
+			Header:      resp.header.Clone(),
+			GeneratedAt: time.Now(),
+			Revalidate:  pages.route(req).Revalidate,
+			Build:       b.version,
+		}
+		if err := store.Put(ctx, req.URL.Path, page); err != nil {
+			return result, fmt.Errorf("prerender %s: %w", p, err)
+		}
+		result.Written = append(result.Written, p)
+	}
+	return result, nil
+}
+
+// PrerenderCommand 解析命令行参数并执行 Prerender，供应用实现 `yourapp prerender` 子命令：
+//
+//	if len(os.Args) > 1 && os.Args[1] == "prerender" {
+//		err := gossr.PrerenderCommand(ctx, os.Args[2:], build)
+//	}
+//
+// 支持 -out、-origin、-locales（逗号分隔）、-params（JSON 文件，格式同 PrerenderOptions.Params）、
+// -paths 与 -exclude（逗号分隔）。
+func PrerenderCommand(ctx context.Context, args []string, build FrontendBuild, opts ...Option) error {
+	flags := flag.NewFlagSet("prerender", flag.ContinueOnError)
+	out := flags.String("out", "prerender", "output directory")
+	origin := flags.String("origin", defaultPrerenderOrigin, "site origin used for offline requests")
+	localeList := flags.String("locales", "", "comma separated locales to prefix, e.g. en,zh")
+	paramsFile := flags.String("params", "", "JSON file mapping route patterns to param lists")
+	pathList := flags.String("paths", "", "comma separated extra paths")
+	excludeList := flags.String("exclude", "", "comma separated route patterns to skip")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	popts := PrerenderOptions{
+		OutDir:  *out,
+		Origin:  *origin,
+		Locales: splitList(*localeList),
+		Paths:   splitList(*pathList),
+		Exclude: splitList(*excludeList),
+	}
+	if *paramsFile != "" {
+		data, err := os.ReadFile(*paramsFile)
+		if err != nil {
+			return fmt.Errorf("prerender: %w", err)
+		}
+		if err := json.Unmarshal(data, &popts.Params); err != nil {
+			return fmt.Errorf("prerender: parse %s: %w", *paramsFile, err)
+		}
+	}
+
+	result, err := Prerender(ctx, build, popts, opts...)
+	for _, skip := range result.Skipped {
+		log.Printf("prerender skipped %s: %s", skip.Route, skip.Reason)
+	}
+	if err != nil {
+		return err
+	}
+	log.Printf("prerendered %d pages into %s", len(result.Written), popts.OutDir)
+	return nil
+}
+
+// prerenderPaths 枚举需要生成的页面路径（已去重、排序），以及因缺少参数等原因跳过的路由。
+func prerenderPaths(app *App, popts PrerenderOptions) ([]string, []PrerenderSkip) {
+	var skipped []PrerenderSkip
+	set := make(map[string]struct{})
+	add := func(p string) {
+		p = path.Clean("/" + p)
+		set[p] = struct{}{}
+		for _, locale := range popts.Locales {
+			set[path.Join("/", locale, p)] = struct{}{}
+		}
+	}
+
+	for _, pattern := range registeredPageRoutes(app) {
+		paths, reason := expandRoutePattern(pattern, popts)
+		if reason != "" {
+			skipped = append(skipped, PrerenderSkip{Route: pattern, Reason: reason})
+			continue
+		}
+		for _, p := range paths {
+			add(p)
+		}
+	}
+	for _, p := range popts.Paths {
+		add(p)
+	}
+
+	exclude := make(map[string]struct{}, len(popts.Exclude))
+	for _, pattern := range popts.Exclude {
+		exclude[pattern] = struct{}{}
+	}
+	excluded := newRouteTable(exclude)
+
+	paths := make([]string, 0, len(set))
+	for p := range set {
+		if _, ok := excluded.lookup(p); ok {
+			continue
+		}
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	return paths, skipped
+}
+
+// registeredPageRoutes 返回 app 的 gin 引擎与 HandleData 注册的 GET 路由，ServeMux 模式统一转换为 gin 写法。
+func registeredPageRoutes(app *App) []string {
+	var patterns []string
+	for _, route := range app.dataEngine().Routes() {
+		if route.Method == http.MethodGet {
+			patterns = append(patterns, route.Path)
+		}
+	}
+
+	for _, pattern := range app.dataRoutePatterns() {
+		if p, ok := ginRoutePattern(pattern); ok {
+			patterns = append(patterns, p)
+		}
+	}
+	return patterns
+}
+
+// ginRoutePattern 把 ServeMux 模式（"GET /hi/{name}"）转换为 gin 写法（"/hi/:name"），
+// 非 GET 或带 host 的模式返回 false。
+func ginRoutePattern(pattern string) (string, bool) {
+	if method, rest, ok := strings.Cut(pattern, " "); ok {
+		if method != http.MethodGet {
+			return "", false
+		}
+		pattern = strings.TrimSpace(rest)
+	}
+	if !strings.HasPrefix(pattern, "/") {
+		return "", false
+	}
+
+	pattern = strings.TrimSuffix(pattern, "{$}")
+	segments := strings.Split(pattern, "/")
+	for i, segment := range segments {
+		if m := servemuxParamPattern.FindStringSubmatch(segment); m != nil {
+			if m[2] != "" {
+				segments[i] = "*" + m[1]
+			} else {
+				segments[i] = ":" + m[1]
+			}
+		}
+	}
+	return strings.Join(segments, "/"), true
+}
+
+// expandRoutePattern 用 Params 展开路由中的参数段，返回具体路径；无法展开时返回原因。
+func expandRoutePattern(pattern string, popts PrerenderOptions) ([]string, string) {
+	segments := splitRoutePath(pattern)
+	hasParams := false
+	for _, segment := range segments {
+		if strings.HasPrefix(segment, ":") || strings.HasPrefix(segment, "*") {
+			hasParams = true
+			break
+		}
+	}
+	if !hasParams {
+		return []string{"/" + strings.Join(segments, "/")}, ""
+	}
+
+	values, ok := popts.Params[pattern]
+	if !ok && len(segments) > 0 && isPrerenderLocale(segments[0], popts.Locales) {
+		values, ok = popts.Params["/"+strings.Join(segments[1:], "/")]
+	}
+	if !ok || len(values) == 0 {
+		return nil, "no params provided"
+	}
+
+	paths := make([]string, 0, len(values))
+	for _, params := range values {
+		parts := make([]string, len(segments))
+		for i, segment := range segments {
+			switch {
+			case strings.HasPrefix(segment, ":"):
+				v, ok := params[segment[1:]]
+				if !ok || v == "" {
+					return nil, fmt.Sprintf("missing param %q", segment[1:])
+				}
+				parts[i] = url.PathEscape(v)
+			case strings.HasPrefix(segment, "*"):
+				parts[i] = strings.Trim(params[segment[1:]], "/")
+			default:
+				parts[i] = segment
+			}
+		}
+		paths = append(paths, "/"+strings.Join(parts, "/"))
+	}
+	return paths, ""
+}
+
+func isPrerenderLocale(segment string, extra []string) bool {
+	if locales.IsSupported(segment) {
+		return true
+	}
+	for _, locale := range extra {
+		if strings.EqualFold(locale, segment) {
+			return true
+		}
+	}
+	return false
+}
+
+// prerenderFile 返回页面路径对应的输出文件名。
+func prerenderFile(urlPath string) string {
+	p := strings.Trim(path.Clean("/"+urlPath), "/")
+	if p == "" {
+		return "index.html"
+	}
+	return p + "/index.html"
+}
+
+// prerenderedPage 查找请求对应的预渲染页面；带 query 的请求可能依赖参数、带 session 的请求需要
+// 渲染登录态，始终走实时渲染。
+// 预渲染页面引用生成时构建的 hash 资源，须与构建一同发布：index.meta.json 记录了构建版本时须与当前构建一致，
+// 未记录时只在启动时的构建生效期间返回，SwapBuild 或热重载后改走实时渲染。
+func (h *pageHandler) prerenderedPage(r *http.Request) (pageResponse, bool) {
+	if h.options.Prerendered == nil || r.URL.RawQuery != "" {
+		return pageResponse{}, false
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return pageResponse{}, false
+	}
+	if sessionStateFromRequest(r) != nil {
+		return pageResponse{}, false
+	}
+
+	file := prerenderFile(r.URL.Path)
+	body, err := fs.ReadFile(h.options.Prerendered, file)
+	if err != nil {
+		return pageResponse{}, false
+	}
+	build := h.initialBuild
+	if data, err := fs.ReadFile(h.options.Prerendered, path.Join(path.Dir(file), "index.meta.json")); err == nil {
+		var meta storedPageMeta
+		if json.Unmarshal(data, &meta) == nil && meta.Build != "" {
+			build = meta.Build
+		}
+	}
+	if build != h.build.Load().version {
+		return pageResponse{}, false
+	}
+	return pageResponse{status: http.StatusOK, body: string(body), cacheable: true}, true
+}
+
+func splitList(raw string) []string {
+	var items []string
+	for _, item := range strings.Split(raw, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
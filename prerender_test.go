@@ -0,0 +1,141 @@
+package gossr
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestPrerender(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	withTestSSREngine(t, func(engine *gin.Engine) {
+		engine.GET("/users/:id", WrapSSR(func(c *gin.Context) (SSRPayload, error) {
+			return mapPayload{"message": "user " + c.Param("id")}, nil
+		}))
+		engine.GET("/docs/*path", WrapSSR(func(*gin.Context) (SSRPayload, error) {
+			return mapPayload{"message": "docs"}, nil
+		}))
+	})
+	withTestDataMux(t, func(*http.ServeMux) {
+		HandleData("GET /{$}", func(*http.Request) (SSRPayload, error) {
+			return mapPayload{"message": "home"}, nil
+		})
+		HandleData("GET /hi/{name}", func(r *http.Request) (SSRPayload, error) {
+			return mapPayload{"message": "hi " + r.PathValue("name")}, nil
+		})
+		HandleData("GET /account", func(*http.Request) (SSRPayload, error) {
+			return mapPayload{"message": "account"}, nil
+		})
+		HandleData("GET /broken", func(*http.Request) (SSRPayload, error) {
+			return nil, os.ErrInvalid
+		})
+	})
+
+	out := t.TempDir()
+	result, err := Prerender(context.Background(), testBuild(testMessageScript, nil), PrerenderOptions{
+		OutDir:  out,
+		Locales: []string{"zh"},
+		Params: map[string][]map[string]string{
+			"/users/:id": {{"id": "1"}, {"id": "2"}},
+			"/hi/:name":  {{"name": "bob"}},
+		},
+		Exclude: []string{"/account", "/zh/account"},
+	})
+	if err != nil {
+		t.Fatalf("prerender: %v", err)
+	}
+
+	// /zh/broken 未注册数据路由，与实时 SSR 一样按空 payload 渲染。
+	want := []string{"/", "/hi/bob", "/users/1", "/users/2", "/zh", "/zh/broken", "/zh/hi/bob", "/zh/users/1", "/zh/users/2"}
+	if !reflect.DeepEqual(result.Written, want) {
+		t.Fatalf("expected written pages %v, got %v", want, result.Written)
+	}
+	skipped := make(map[string]string)
+	for _, skip := range result.Skipped {
+		skipped[skip.Route] = skip.Reason
+	}
+	if skipped["/docs/*path"] != "no params provided" || skipped["/broken"] == "" {
+		t.Fatalf("expected param route and failing route to be skipped, got %v", result.Skipped)
+	}
+
+	for file, content := range map[string]string{
+		"index.html":         "<p>home</p>",
+		"users/2/index.html": "<p>user 2</p>",
+		"hi/bob/index.html":  "<p>hi bob</p>",
+		"zh/index.html":      `lang="zh"`,
+	} {
+		data, err := os.ReadFile(filepath.Join(out, file))
+		if err != nil || !strings.Contains(string(data), content) || !strings.Contains(string(data), "window.__SSR_DATA__") {
+			t.Fatalf("expected %s to contain %q, got %q %v", file, content, data, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(out, "account", "index.html")); !os.IsNotExist(err) {
+		t.Fatalf("expected excluded page not to be written, got %v", err)
+	}
+}
+
+func TestServePrerenderedPages(t *testing.T) {
+	withTestDataMux(t, func(*http.ServeMux) {
+		HandleData("GET /about", func(*http.Request) (SSRPayload, error) {
+			return mapPayload{"message": "live"}, nil
+		})
+	})
+
+	out := t.TempDir()
+	writeBuildFile(t, out, "about/index.html", "<p>static</p>")
+	writeBuildFile(t, out, "faq/index.html", "<p>other build</p>")
+	writeBuildFile(t, out, "faq/index.meta.json", `{"build":"0123456789abcdef"}`)
+
+	server, err := NewHandler(testBuild(testMessageScript, nil), WithPrerendered(os.DirFS(out)))
+	if err != nil {
+		t.Fatalf("new handler: %v", err)
+	}
+	if body := performRequest(server, http.MethodGet, "/about", nil).Body.String(); body != "<p>static</p>" {
+		t.Fatalf("expected prerendered page, got %q", body)
+	}
+	if body := performRequest(server, http.MethodGet, "/about?ref=x", nil).Body.String(); !strings.Contains(body, "<p>live</p>") {
+		t.Fatalf("expected query request to render live, got %q", body)
+	}
+	if body := performRequest(server, http.MethodGet, "/contact", nil).Body.String(); !strings.Contains(body, "<p>none</p>") {
+		t.Fatalf("expected missing page to fall back to live SSR, got %q", body)
+	}
+	signedIn := performRequest(server, http.MethodGet, "/about", func(req *http.Request) {
+		addSessionTokenCookie(req, mustSessionToken(t, map[string]any{"email": "a@example.com"}))
+	}).Body.String()
+	if !strings.Contains(signedIn, "<p>live</p>") || !strings.Contains(signedIn, "a@example.com") {
+		t.Fatalf("expected signed-in request to render live with its session, got %q", signedIn)
+	}
+	if body := performRequest(server, http.MethodGet, "/faq", nil).Body.String(); !strings.Contains(body, "<p>none</p>") {
+		t.Fatalf("expected page prerendered by another build to render live, got %q", body)
+	}
+
+	if err := server.SwapBuild(testBuild(`globalThis.ssrRender = function() { return "<p>v2</p>" }`, nil)); err != nil {
+		t.Fatalf("swap build: %v", err)
+	}
+	if body := performRequest(server, http.MethodGet, "/about", nil).Body.String(); !strings.Contains(body, "<p>v2</p>") {
+		t.Fatalf("expected prerendered pages to be ignored after the build changes, got %q", body)
+	}
+}
+
+func TestGinRoutePattern(t *testing.T) {
+	for pattern, want := range map[string]string{
+		"GET /{$}":               "/",
+		"GET /hi/{name}":         "/hi/:name",
+		"/docs/{path...}":        "/docs/*path",
+		"GET /files/{$}":         "/files/",
+		"POST /hi/{name}":        "",
+		"GET example.com/{name}": "",
+	} {
+		got, ok := ginRoutePattern(pattern)
+		if ok != (want != "") || got != want {
+			t.Fatalf("%s: expected %q, got %q %v", pattern, want, got, ok)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package gossr
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/daodao97/gossr/renderer"
+)
+
+// newRenderRequest 构造传给 ssrRender 的请求描述，header/cookie 只透传白名单内的条目。
+func newRenderRequest(r *http.Request, options Options) *renderer.Request {
+	if r == nil || r.URL == nil {
+		return nil
+	}
+
+	req := &renderer.Request{
+		URL:      r.URL.RequestURI(),
+		Path:     r.URL.Path,
+		Query:    r.URL.RawQuery,
+		Method:   r.Method,
+		Headers:  make(map[string]string, len(options.RequestHeaders)),
+		Cookies:  make(map[string]string, len(options.RequestCookies)),
+		Locale:   localeFromPath(r.URL.Path),
+		Origin:   requestOrigin(r, options.TrustForwardedHeaders),
+		ClientIP: clientIP(r, options.TrustForwardedHeaders),
+	}
+
+	for _, name := range options.RequestHeaders {
+		if value := r.Header.Get(name); value != "" {
+			req.Headers[strings.ToLower(name)] = value
+		}
+	}
+
+	for _, name := range options.RequestCookies {
+		if cookie, err := r.Cookie(name); err == nil {
+			req.Cookies[name] = cookie.Value
+		}
+	}
+
+	return req
+}
+
+func clientIP(r *http.Request, trustForwarded bool) string {
+	if trustForwarded {
+		if forwardedFor := firstForwardedValue(r.Header.Get("X-Forwarded-For")); forwardedFor != "" {
+			return forwardedFor
+		}
+		if realIP := strings.TrimSpace(r.Header.Get("X-Real-IP")); realIP != "" {
+			return realIP
+		}
+	}
+
+	host, _, err := net.SplitHostPort(strings.TrimSpace(r.RemoteAddr))
+	if err != nil {
+		return strings.TrimSpace(r.RemoteAddr)
+	}
+	return host
+}
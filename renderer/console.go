@@ -0,0 +1,208 @@
+package renderer
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// ConsoleGlobal 是 console polyfill 调用的宿主函数名，参数为 (level, message)。
+const ConsoleGlobal = "__SSR_CONSOLE__"
+
+const (
+	defaultConsoleRate     = 10
+	defaultConsoleBurst    = 50
+	maxConsoleMessageBytes = 8 << 10
+)
+
+// ConsoleConfig 配置 SSR 脚本中 console.* 的输出。
+type ConsoleConfig struct {
+	// Logger 为 nil 时使用 slog.Default()。
+	Logger *slog.Logger
+	// RateLimit 每个 runtime 每秒允许输出的条数，0 使用默认 10，负值表示不限制。
+	RateLimit float64
+	// Burst 令牌桶容量，<=0 时使用默认 50。
+	Burst int
+}
+
+// Config 汇总引擎的可选配置。
+type Config struct {
+	Console ConsoleConfig
+	// ScriptName 为编译 SSR 脚本时使用的文件名（出现在错误堆栈中），默认 DefaultSSRScriptName。
+	ScriptName string
+}
+
+// Option 以函数式方式修改引擎配置。
+type Option func(*Config)
+
+// WithConsole 设置 console 输出配置。
+func WithConsole(cfg ConsoleConfig) Option {
+	return func(c *Config) {
+		c.Console = cfg
+	}
+}
+
+// WithScriptName 设置编译 SSR 脚本时使用的文件名，为空时使用 DefaultSSRScriptName。
+func WithScriptName(name string) Option {
+	return func(c *Config) {
+		c.ScriptName = name
+	}
+}
+
+// NewConfig 应用 Option 并返回引擎配置。
+func NewConfig(opts ...Option) Config {
+	var cfg Config
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&cfg)
+		}
+	}
+	if cfg.ScriptName == "" {
+		cfg.ScriptName = DefaultSSRScriptName
+	}
+	return cfg
+}
+
+// Console 把 JS console 输出写入 slog，每个 runtime/isolate 持有一个实例。
+// 同一 runtime 同一时间只服务一次渲染，因此不做并发保护。
+type Console struct {
+	logger  *slog.Logger
+	rate    float64
+	burst   float64
+	tokens  float64
+	last    time.Time
+	dropped int
+}
+
+// NewConsole 按配置创建 Console。
+func NewConsole(cfg ConsoleConfig) *Console {
+	logger := cfg.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	rate := cfg.RateLimit
+	if rate == 0 {
+		rate = defaultConsoleRate
+	}
+	burst := cfg.Burst
+	if burst <= 0 {
+		burst = defaultConsoleBurst
+	}
+
+	return &Console{logger: logger, rate: rate, burst: float64(burst), tokens: float64(burst)}
+}
+
+// Log 输出一条 console 消息，并附带当前请求的 ID 与路径；超过速率限制的消息会被丢弃并计数。
+func (c *Console) Log(ctx context.Context, level string, message string) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if !c.allow(time.Now()) {
+		c.dropped++
+		return
+	}
+
+	if len(message) > maxConsoleMessageBytes {
+		message = message[:maxConsoleMessageBytes] + "...(truncated)"
+	}
+
+	attrs := []any{slog.String("source", "ssr-console")}
+	if req := RequestFromContext(ctx); req != nil {
+		if req.ID != "" {
+			attrs = append(attrs, slog.String("req_id", req.ID))
+		}
+		attrs = append(attrs, slog.String("path", req.Path))
+	}
+	if c.dropped > 0 {
+		attrs = append(attrs, slog.Int("dropped", c.dropped))
+		c.dropped = 0
+	}
+
+	c.logger.Log(ctx, consoleLevel(level), message, attrs...)
+}
+
+func (c *Console) allow(now time.Time) bool {
+	if c.rate < 0 {
+		return true
+	}
+
+	if !c.last.IsZero() {
+		c.tokens += now.Sub(c.last).Seconds() * c.rate
+		if c.tokens > c.burst {
+			c.tokens = c.burst
+		}
+	}
+	c.last = now
+
+	if c.tokens < 1 {
+		return false
+	}
+	c.tokens--
+	return true
+}
+
+func consoleLevel(level string) slog.Level {
+	switch level {
+	case "debug", "trace":
+		return slog.LevelDebug
+	case "warn":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// ConsolePolyfill 在 JS 全局注入 console，参数格式化后交给 ConsoleGlobal 宿主函数。
+const ConsolePolyfill = `(function (g) {
+	var host = g.` + ConsoleGlobal + `;
+	if (typeof host !== "function") return;
+
+	function format(v) {
+		if (typeof v === "string") return v;
+		if (v instanceof Error) return v.stack || (v.name + ": " + v.message);
+		if (v === undefined) return "undefined";
+		if (typeof v === "function") return "[Function " + (v.name || "anonymous") + "]";
+		try {
+			var s = JSON.stringify(v);
+			return s === undefined ? String(v) : s;
+		} catch (err) {
+			return String(v);
+		}
+	}
+	function emit(level, args) {
+		var parts = [];
+		for (var i = 0; i < args.length; i++) parts.push(format(args[i]));
+		host(level, parts.join(" "));
+	}
+	function method(level) {
+		return function () { emit(level, arguments); };
+	}
+	function noop() {}
+
+	g.console = {
+		debug: method("debug"),
+		trace: method("trace"),
+		log: method("log"),
+		info: method("info"),
+		dir: method("info"),
+		table: method("info"),
+		warn: method("warn"),
+		error: method("error"),
+		assert: function (cond) {
+			if (cond) return;
+			var args = Array.prototype.slice.call(arguments, 1);
+			args.unshift("Assertion failed:");
+			emit("error", args);
+		},
+		group: noop,
+		groupCollapsed: noop,
+		groupEnd: noop,
+		time: noop,
+		timeEnd: noop,
+		timeLog: noop,
+		count: noop,
+		countReset: noop
+	};
+})(globalThis);`
@@ -0,0 +1,162 @@
+package gojs
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/dop251/goja"
+)
+
+// minIntervalDelay 与 Node.js 一致，setInterval 的最小间隔为 1ms，避免空转。
+const minIntervalDelay = time.Millisecond
+
+// queueMicrotaskPolyfill 借助 Promise 任务队列实现 queueMicrotask，goja 每次从 Go 调入 JS 返回前都会清空该队列。
+const queueMicrotaskPolyfill = `globalThis.queueMicrotask = function queueMicrotask(callback) {
+	if (typeof callback !== "function") {
+		throw new TypeError("queueMicrotask: callback must be a function");
+	}
+	Promise.resolve().then(function () { callback(); });
+};`
+
+// eventLoop 为单个 goja runtime 提供 setTimeout/setInterval。
+// 定时器只在渲染所在的 goroutine 中执行：run 按到期时间依次触发，直到 Promise 落定或 ctx 取消。
+type eventLoop struct {
+	rt     *goja.Runtime
+	timers map[int64]*loopTimer
+	nextID int64
+}
+
+type loopTimer struct {
+	id       int64
+	at       time.Time
+	interval time.Duration
+	repeat   bool
+	fn       goja.Callable
+	args     []goja.Value
+}
+
+// newEventLoop 在 runtime 上注册定时器相关全局函数。
+func newEventLoop(rt *goja.Runtime) *eventLoop {
+	l := &eventLoop{rt: rt, timers: make(map[int64]*loopTimer)}
+
+	global := rt.GlobalObject()
+	_ = global.Set("setTimeout", l.schedule(false))
+	_ = global.Set("setInterval", l.schedule(true))
+	_ = global.Set("clearTimeout", l.clear)
+	_ = global.Set("clearInterval", l.clear)
+	if _, err := rt.RunString(queueMicrotaskPolyfill); err != nil {
+		panic("failed to install queueMicrotask: " + err.Error())
+	}
+
+	return l
+}
+
+func (l *eventLoop) schedule(repeat bool) func(goja.FunctionCall) goja.Value {
+	return func(call goja.FunctionCall) goja.Value {
+		fn, ok := goja.AssertFunction(call.Argument(0))
+		if !ok {
+			panic(l.rt.NewTypeError("callback must be a function"))
+		}
+
+		delay := timerDelay(call.Argument(1))
+		if repeat && delay < minIntervalDelay {
+			delay = minIntervalDelay
+		}
+
+		var args []goja.Value
+		if len(call.Arguments) > 2 {
+			args = append(args, call.Arguments[2:]...)
+		}
+
+		l.nextID++
+		l.timers[l.nextID] = &loopTimer{
+			id:       l.nextID,
+			at:       time.Now().Add(delay),
+			interval: delay,
+			repeat:   repeat,
+			fn:       fn,
+			args:     args,
+		}
+		return l.rt.ToValue(l.nextID)
+	}
+}
+
+func (l *eventLoop) clear(call goja.FunctionCall) goja.Value {
+	id := call.Argument(0)
+	if goja.IsUndefined(id) || goja.IsNull(id) {
+		return goja.Undefined()
+	}
+	delete(l.timers, id.ToInteger())
+	return goja.Undefined()
+}
+
+// timerDelay 把 JS 传入的延迟（毫秒）转为 Duration，非法值按 0 处理。
+func timerDelay(val goja.Value) time.Duration {
+	if val == nil || goja.IsUndefined(val) || goja.IsNull(val) {
+		return 0
+	}
+	ms := val.ToFloat()
+	if math.IsNaN(ms) || ms <= 0 {
+		return 0
+	}
+	if ms > math.MaxInt64/float64(time.Millisecond) {
+		return time.Duration(math.MaxInt64)
+	}
+	return time.Duration(ms * float64(time.Millisecond))
+}
+
+// run 依次触发到期的定时器，直到 settled 返回 true、没有待执行的定时器或 ctx 结束。
+// 没有定时器时直接返回，由调用方决定如何处理仍未落定的 Promise。
+func (l *eventLoop) run(ctx context.Context, settled func() bool) error {
+	for !settled() {
+		next := l.nextTimer()
+		if next == nil {
+			return nil
+		}
+
+		if wait := time.Until(next.at); wait > 0 {
+			timer := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return ctx.Err()
+			case <-timer.C:
+			}
+		} else if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if err := l.fire(next); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// nextTimer 返回最早到期的定时器，同一时间到期时按注册顺序。
+func (l *eventLoop) nextTimer() *loopTimer {
+	var next *loopTimer
+	for _, t := range l.timers {
+		if next == nil || t.at.Before(next.at) || (t.at.Equal(next.at) && t.id < next.id) {
+			next = t
+		}
+	}
+	return next
+}
+
+func (l *eventLoop) fire(t *loopTimer) error {
+	if t.repeat {
+		t.at = time.Now().Add(t.interval)
+	} else {
+		delete(l.timers, t.id)
+	}
+
+	_, err := t.fn(goja.Undefined(), t.args...)
+	return err
+}
+
+// reset 清理本次渲染遗留的定时器，runtime 归还池前调用。
+func (l *eventLoop) reset() {
+	clear(l.timers)
+}
@@ -0,0 +1,77 @@
+package gojs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/daodao97/gossr/renderer"
+	"github.com/dop251/goja"
+)
+
+func TestRendererAwaitsTimers(t *testing.T) {
+	r := NewRenderer(`const sleep = ms => new Promise(resolve => setTimeout(resolve, ms))
+	globalThis.ssrRender = async function(url) {
+		const order = []
+		queueMicrotask(() => order.push("micro"))
+		setTimeout((a, b) => order.push("timeout:" + a + b), 5, "x", "y")
+		let ticks = 0
+		const id = setInterval(() => { if (++ticks === 3) clearInterval(id) }, 1)
+		clearTimeout(setTimeout(() => order.push("cancelled"), 0))
+		await sleep(20)
+		return url + "|" + order.join(",") + "|" + ticks
+	}`, renderer.PoolConfig{Size: 8})
+
+	result, err := r.Render(context.Background(), "/slow", nil)
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	if result.HTML != "/slow|micro,timeout:xy|3" {
+		t.Fatalf("unexpected html: %q", result.HTML)
+	}
+}
+
+func TestRendererTimerRespectsContext(t *testing.T) {
+	r := NewRenderer(`globalThis.ssrRender = function() {
+		return new Promise(resolve => setTimeout(() => resolve("late"), 10000))
+	}`, renderer.PoolConfig{Size: 8})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := r.Render(ctx, "/", nil)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("expected render to stop at ctx deadline, took %s", elapsed)
+	}
+}
+
+func TestRendererTimerErrorFailsRender(t *testing.T) {
+	r := NewRenderer(`globalThis.ssrRender = function() {
+		return new Promise(() => setTimeout(() => { throw new Error("timer boom") }, 1))
+	}`, renderer.PoolConfig{Size: 8})
+
+	if _, err := r.Render(context.Background(), "/", nil); err == nil {
+		t.Fatal("expected timer error to fail render")
+	}
+}
+
+func TestEventLoopResetClearsTimers(t *testing.T) {
+	rt := goja.New()
+	loop := newEventLoop(rt)
+	if _, err := rt.RunString(`setInterval(() => {}, 10); setTimeout(() => {}, 10)`); err != nil {
+		t.Fatalf("schedule timers: %v", err)
+	}
+	if len(loop.timers) != 2 {
+		t.Fatalf("expected 2 timers, got %d", len(loop.timers))
+	}
+
+	loop.reset()
+	if loop.nextTimer() != nil {
+		t.Fatal("expected reset to drop pending timers")
+	}
+}
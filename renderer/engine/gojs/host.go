@@ -0,0 +1,40 @@
+package gojs
+
+import (
+	"context"
+
+	"github.com/daodao97/gossr/renderer"
+	"github.com/dop251/goja"
+)
+
+// installFetch 注册 fetch 宿主函数与 polyfill，请求使用当前渲染的 ctx。
+func installFetch(rt *jsRuntime) {
+	host := func(call goja.FunctionCall) goja.Value {
+		ctx := rt.ctx
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		out, err := renderer.ServeFetch(ctx, call.Argument(0).String())
+		if err != nil {
+			panic(rt.NewGoError(err))
+		}
+		return rt.ToValue(out)
+	}
+
+	_ = rt.Set(renderer.FetchGlobal, host)
+	if _, err := rt.RunString(renderer.FetchPolyfill); err != nil {
+		panic("failed to install fetch polyfill: " + err.Error())
+	}
+}
+
+// installConsole 注册 console 宿主函数与 polyfill，输出写入 runtime 绑定的 Console。
+func installConsole(rt *jsRuntime) {
+	host := func(level string, message string) {
+		rt.console.Log(rt.ctx, level, message)
+	}
+
+	_ = rt.Set(renderer.ConsoleGlobal, host)
+	if _, err := rt.RunString(renderer.ConsolePolyfill); err != nil {
+		panic("failed to install console polyfill: " + err.Error())
+	}
+}
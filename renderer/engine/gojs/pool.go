@@ -4,12 +4,10 @@ import (
 	"context"
 	"fmt"
 	"log"
-	"os"
 	"runtime"
-	"strconv"
-	"strings"
 	"time"
 
+	"github.com/daodao97/gossr/renderer"
 	internalpool "github.com/daodao97/gossr/renderer/engine/internal/pool"
 	"github.com/dop251/goja"
 )
@@ -24,11 +22,21 @@ const (
 // runtimePool 支持动态扩缩容的有界池。
 type runtimePool struct {
 	program *goja.Program
-	bounded *internalpool.Bounded[*goja.Runtime]
+	console renderer.ConsoleConfig
+	bounded *internalpool.Bounded[*jsRuntime]
+}
+
+// jsRuntime 把 goja runtime 与它的事件循环绑定在一起，整体在池中复用。
+type jsRuntime struct {
+	*goja.Runtime
+	loop    *eventLoop
+	console *renderer.Console
+	// ctx 为当前渲染的上下文，供 fetch 等宿主函数使用，归还池时清空。
+	ctx context.Context
 }
 
 // newRuntimePool 创建预热的 Goja runtime 池。
-func newRuntimePool(program *goja.Program) *runtimePool {
+func newRuntimePool(program *goja.Program, cfg renderer.PoolConfig, console renderer.ConsoleConfig) *runtimePool {
 	defaultPoolSize := runtime.NumCPU() * 4
 	if defaultPoolSize < minGojaPoolSize {
 		defaultPoolSize = minGojaPoolSize
@@ -37,16 +45,16 @@ func newRuntimePool(program *goja.Program) *runtimePool {
 		defaultPoolSize = maxGojaPoolSize
 	}
 
-	// 池大小优先从环境变量读取，否则使用 CPU 核心数 * 4。
-	poolSize := parseGojaPoolSize(defaultPoolSize)
+	// 池大小优先使用配置值，否则使用 CPU 核心数 * 4。
+	poolSize := gojaPoolSize(cfg.Size, defaultPoolSize)
 	// 获取超时配置 (默认 5 秒)。
-	timeout := parseGojaPoolTimeout(defaultGojaPoolTimeout)
+	timeout := gojaPoolTimeout(cfg.Timeout, defaultGojaPoolTimeout)
 
-	p := &runtimePool{program: program}
-	p.bounded = internalpool.NewBounded[*goja.Runtime](
+	p := &runtimePool{program: program, console: console}
+	p.bounded = internalpool.NewBounded[*jsRuntime](
 		poolSize,
 		timeout,
-		internalpool.Callbacks[*goja.Runtime]{
+		internalpool.Callbacks[*jsRuntime]{
 			Create: p.createRuntime,
 			Reset:  p.resetRuntime,
 			ClosedErr: func() error {
@@ -64,96 +72,80 @@ func newRuntimePool(program *goja.Program) *runtimePool {
 	return p
 }
 
-func parseGojaPoolSize(defaultSize int) int {
-	raw := strings.TrimSpace(os.Getenv("GOJA_POOL_SIZE"))
-	if raw == "" {
-		return defaultSize
-	}
-
-	size, err := strconv.Atoi(raw)
-	if err != nil || size <= 0 {
-		log.Printf("config: invalid GOJA_POOL_SIZE=%q, use default %d", raw, defaultSize)
+// gojaPoolSize 归一化池大小：未配置时使用默认值，并限制在 [min, max] 区间。
+func gojaPoolSize(size int, defaultSize int) int {
+	if size <= 0 {
 		return defaultSize
 	}
 	if size < minGojaPoolSize {
-		log.Printf("config: GOJA_POOL_SIZE=%d below min %d, clamped", size, minGojaPoolSize)
+		log.Printf("config: goja pool size %d below min %d, clamped", size, minGojaPoolSize)
 		return minGojaPoolSize
 	}
 	if size > maxGojaPoolSize {
-		log.Printf("config: GOJA_POOL_SIZE=%d exceeds max %d, clamped", size, maxGojaPoolSize)
+		log.Printf("config: goja pool size %d exceeds max %d, clamped", size, maxGojaPoolSize)
 		return maxGojaPoolSize
 	}
 	return size
 }
 
-func parseGojaPoolTimeout(defaultTimeout time.Duration) time.Duration {
-	raw := strings.TrimSpace(os.Getenv("GOJA_POOL_TIMEOUT"))
-	if raw == "" {
+// gojaPoolTimeout 归一化获取超时：0 使用默认值，负值表示不设超时。
+func gojaPoolTimeout(timeout time.Duration, defaultTimeout time.Duration) time.Duration {
+	if timeout == 0 {
 		return defaultTimeout
 	}
-
-	timeout, err := time.ParseDuration(raw)
-	if err != nil {
-		log.Printf("config: invalid GOJA_POOL_TIMEOUT=%q, use default %s", raw, defaultTimeout)
-		return defaultTimeout
-	}
-
 	if timeout < 0 {
-		log.Printf("config: GOJA_POOL_TIMEOUT=%q is negative, treated as 0", raw)
 		return 0
 	}
 	if timeout > maxGojaPoolTimeout {
-		log.Printf("config: GOJA_POOL_TIMEOUT=%s exceeds max %s, clamped", timeout, maxGojaPoolTimeout)
+		log.Printf("config: goja pool timeout %s exceeds max %s, clamped", timeout, maxGojaPoolTimeout)
 		return maxGojaPoolTimeout
 	}
 	return timeout
 }
 
 // createRuntime 创建新的 Goja runtime。
-func (p *runtimePool) createRuntime() *goja.Runtime {
+func (p *runtimePool) createRuntime() *jsRuntime {
 	rt := goja.New()
 	global := rt.GlobalObject()
 	_ = global.Set("globalThis", global)
 	_ = global.Set("global", global)
 
-	// 注入 console polyfill (goja 默认不提供)
-	console := rt.NewObject()
-	noop := func(call goja.FunctionCall) goja.Value { return goja.Undefined() }
-	_ = console.Set("log", noop)
-	_ = console.Set("info", noop)
-	_ = console.Set("warn", noop)
-	_ = console.Set("error", noop)
-	_ = console.Set("debug", noop)
-	_ = console.Set("trace", noop)
-	_ = global.Set("console", console)
+	// console、定时器、queueMicrotask 与 fetch 需在脚本执行前注入，便于打包产物在模块顶层引用。
+	jsrt := &jsRuntime{Runtime: rt, loop: newEventLoop(rt), console: renderer.NewConsole(p.console)}
+	installConsole(jsrt)
+	installFetch(jsrt)
 
 	if _, err := rt.RunProgram(p.program); err != nil {
 		panic("failed to run SSR program: " + err.Error())
 	}
 
-	return rt
+	return jsrt
 }
 
-func (p *runtimePool) resetRuntime(rt *goja.Runtime) {
+func (p *runtimePool) resetRuntime(rt *jsRuntime) {
 	if rt == nil {
 		return
 	}
 
 	// runtime 可能被 Interrupt 过，归还前必须清理中断标记。
 	rt.ClearInterrupt()
+	// 丢弃本次渲染遗留的定时器，避免泄漏到下一次渲染。
+	rt.loop.reset()
+	rt.ctx = nil
 
 	// 清理 per-request 数据
 	_ = rt.Set("__SSR_DATA__", goja.Undefined())
 	_ = rt.Set("__SSR_HEAD__", goja.Undefined())
+	_ = rt.Set(renderer.ResponseGlobal, goja.Undefined())
 }
 
 // Get 从池中获取 runtime，支持超时、上下文取消和动态创建。
-func (p *runtimePool) Get(ctx context.Context) (*goja.Runtime, error) {
+func (p *runtimePool) Get(ctx context.Context) (*jsRuntime, error) {
 	return p.bounded.Get(ctx)
 }
 
 // Put 归还 runtime 到池中。
-func (p *runtimePool) Put(rt *goja.Runtime) {
+func (p *runtimePool) Put(rt *jsRuntime) {
 	if rt == nil {
 		return
 	}
@@ -161,7 +153,7 @@ func (p *runtimePool) Put(rt *goja.Runtime) {
 }
 
 // Discard 丢弃 runtime（不归还池），并更新池计数。
-func (p *runtimePool) Discard(rt *goja.Runtime) {
+func (p *runtimePool) Discard(rt *jsRuntime) {
 	if rt == nil {
 		return
 	}
@@ -1,12 +1,28 @@
 package gojs
 
 import (
+	"context"
 	"errors"
 	"fmt"
 
 	"github.com/dop251/goja"
 )
 
+// waitGojaValue 在返回值为未落定的 Promise 时驱动事件循环，直到其落定或 ctx 结束。
+func waitGojaValue(ctx context.Context, loop *eventLoop, val goja.Value) error {
+	if val == nil {
+		return nil
+	}
+	promise, ok := val.Export().(*goja.Promise)
+	if !ok || promise.State() != goja.PromiseStatePending {
+		return nil
+	}
+
+	return loop.run(ctx, func() bool {
+		return promise.State() != goja.PromiseStatePending
+	})
+}
+
 func resolveGojaValue(val goja.Value) (goja.Value, error) {
 	if promise, ok := val.Export().(*goja.Promise); ok {
 		switch promise.State() {
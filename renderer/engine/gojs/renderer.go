@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"sync/atomic"
 
 	"github.com/daodao97/gossr/renderer"
@@ -16,30 +17,119 @@ type Renderer struct {
 }
 
 // NewRenderer 创建 goja 渲染器，编译脚本供后续复用。
-func NewRenderer(scriptContents string) *Renderer {
-	program, err := goja.Compile(renderer.DefaultSSRScriptName, scriptContents, false)
+func NewRenderer(scriptContents string, poolConfig renderer.PoolConfig, opts ...renderer.Option) *Renderer {
+	cfg := renderer.NewConfig(opts...)
+	program, err := goja.Compile(cfg.ScriptName, scriptContents, false)
 	if err != nil {
 		// 与 v8 版本保持行为，一旦脚本无法编译直接 panic，方便尽早暴露问题。
 		panic(fmt.Errorf("compile ssr script: %w", err))
 	}
 
-	return &Renderer{pool: newRuntimePool(program)}
+	return &Renderer{pool: newRuntimePool(program, poolConfig, cfg.Console)}
+}
+
+// PoolStats 返回 runtime 池的运行状态。
+func (r *Renderer) PoolStats() renderer.PoolStats {
+	stats := r.pool.bounded.Stats()
+	return renderer.PoolStats{
+		Size:         stats.Size,
+		MaxSize:      stats.MaxSize,
+		Idle:         stats.Idle,
+		Waits:        stats.Waits,
+		WaitTimeouts: stats.WaitTimeouts,
+	}
+}
+
+// Close 关闭 runtime 池，热重载替换渲染器后调用。
+func (r *Renderer) Close() {
+	r.pool.Close()
 }
 
 // Render 同步执行 ssrRender，支持 Promise 结果。
 func (r *Renderer) Render(ctx context.Context, urlPath string, payload map[string]any) (renderer.Result, error) {
+	return r.execute(ctx, urlPath, payload, func(rt *goja.Runtime, args []goja.Value) (goja.Value, error) {
+		renderFunc, ok := goja.AssertFunction(rt.Get("ssrRender"))
+		if !ok {
+			return nil, errors.New("ssrRender is not a function")
+		}
+		return renderFunc(goja.Undefined(), args...)
+	})
+}
+
+// RenderIsland 执行 ssrRenderers[name](url, data, request)，返回值约定与 ssrRender 相同。
+func (r *Renderer) RenderIsland(ctx context.Context, name string, urlPath string, payload map[string]any) (renderer.Result, error) {
+	return r.execute(ctx, urlPath, payload, func(rt *goja.Runtime, args []goja.Value) (goja.Value, error) {
+		renderers := rt.Get(renderer.IslandsGlobal)
+		var islandFunc goja.Callable
+		if renderers != nil && !goja.IsUndefined(renderers) && !goja.IsNull(renderers) {
+			islandFunc, _ = goja.AssertFunction(renderers.ToObject(rt).Get(name))
+		}
+		if islandFunc == nil {
+			return nil, fmt.Errorf("%w: %s", renderer.ErrIslandNotFound, name)
+		}
+		callArgs := append([]goja.Value{args[0], rt.Get("__SSR_DATA__")}, args[1:]...)
+		return islandFunc(renderers, callArgs...)
+	})
+}
+
+// RenderStream 执行 ssrRenderStream(url, request, write)，JS 侧每次 write 都会直接写入 w。
+func (r *Renderer) RenderStream(ctx context.Context, urlPath string, payload map[string]any, w io.Writer) (renderer.Result, error) {
+	result, err := r.execute(ctx, urlPath, payload, func(rt *goja.Runtime, args []goja.Value) (goja.Value, error) {
+		streamFunc, ok := goja.AssertFunction(rt.Get("ssrRenderStream"))
+		if !ok {
+			return nil, renderer.ErrStreamingUnsupported
+		}
+
+		if len(args) < 2 {
+			args = append(args, goja.Undefined())
+		}
+		write := func(call goja.FunctionCall) goja.Value {
+			chunk := call.Argument(0)
+			if goja.IsUndefined(chunk) || goja.IsNull(chunk) {
+				return goja.Undefined()
+			}
+			if _, err := io.WriteString(w, chunk.String()); err != nil {
+				panic(rt.NewGoError(err))
+			}
+			return goja.Undefined()
+		}
+		return streamFunc(goja.Undefined(), append(args, rt.ToValue(write))...)
+	})
+	if err != nil {
+		return renderer.Result{}, err
+	}
+
+	// 未使用 write 而是直接返回 HTML 时，同样写入流中。
+	if result.HTML != "" {
+		if _, err := io.WriteString(w, result.HTML); err != nil {
+			return renderer.Result{}, err
+		}
+		result.HTML = ""
+	}
+	return result, nil
+}
+
+// execute 从池中取出 runtime，注入 SSR 数据后调用 call，并把返回值解析为 Result。
+func (r *Renderer) execute(ctx context.Context, urlPath string, payload map[string]any, call func(*goja.Runtime, []goja.Value) (goja.Value, error)) (_ renderer.Result, err error) {
 	if ctx == nil {
 		ctx = context.Background()
 	}
 
+	_, acquireSpan := renderer.StartSpan(ctx, renderer.SpanPoolAcquire)
 	rt, err := r.pool.Get(ctx)
+	renderer.EndSpan(acquireSpan, err)
 	if err != nil {
 		return renderer.Result{}, err
 	}
 
+	ctx, span := renderer.StartSpan(ctx, renderer.SpanJSExecute)
+	defer func() { renderer.EndSpan(span, err) }()
+
 	var interrupted atomic.Bool
 	stopWatch := make(chan struct{})
+	watchDone := make(chan struct{})
 	go func() {
+		defer close(watchDone)
 		select {
 		case <-ctx.Done():
 			interrupted.Store(true)
@@ -48,8 +138,10 @@ func (r *Renderer) Render(ctx context.Context, urlPath string, payload map[strin
 		}
 	}()
 
-	defer close(stopWatch)
+	// 归还前先等待监听协程退出，避免 rt 回到池中后才被中断。
 	defer func() {
+		close(stopWatch)
+		<-watchDone
 		if interrupted.Load() {
 			r.pool.Discard(rt)
 			return
@@ -57,8 +149,11 @@ func (r *Renderer) Render(ctx context.Context, urlPath string, payload map[strin
 		r.pool.Put(rt)
 	}()
 
+	rt.ctx = ctx
+
 	// 注入 SSR 数据
 	_ = rt.Set("__SSR_HEAD__", goja.Undefined())
+	_ = rt.Set(renderer.ResponseGlobal, goja.Undefined())
 	if len(payload) > 0 {
 		if err := rt.Set("__SSR_DATA__", payload); err != nil {
 			return renderer.Result{}, err
@@ -67,17 +162,22 @@ func (r *Renderer) Render(ctx context.Context, urlPath string, payload map[strin
 		_ = rt.Set("__SSR_DATA__", goja.Undefined())
 	}
 
-	renderVal := rt.Get("ssrRender")
-	renderFunc, ok := goja.AssertFunction(renderVal)
-	if !ok {
-		return renderer.Result{}, errors.New("ssrRender is not a function")
+	args := []goja.Value{rt.ToValue(urlPath)}
+	if req := renderer.RequestFromContext(ctx); req != nil {
+		args = append(args, rt.ToValue(req.AsMap()))
 	}
 
-	val, err := renderFunc(goja.Undefined(), rt.ToValue(urlPath))
+	val, err := call(rt.Runtime, args)
+	if err == nil {
+		err = waitGojaValue(ctx, rt.loop, val)
+	}
 	if err != nil {
 		if interrupted.Load() && ctx.Err() != nil {
 			return renderer.Result{}, ctx.Err()
 		}
+		if errors.Is(err, renderer.ErrStreamingUnsupported) || errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+			return renderer.Result{}, err
+		}
 		return renderer.Result{}, formatGojaError(err)
 	}
 
@@ -95,8 +195,15 @@ func (r *Renderer) Render(ctx context.Context, urlPath string, payload map[strin
 		head = headVal.String()
 	}
 
-	return renderer.Result{
-		HTML: resultVal.String(),
-		Head: head,
-	}, nil
+	result := renderer.Result{Head: head}
+	renderer.ApplyResponseMeta(&result, exportObject(rt.Get(renderer.ResponseGlobal)))
+
+	// ssrRender 可直接返回 { html, head, status, redirect, headers }。
+	if meta := exportObject(resultVal); meta != nil {
+		renderer.ApplyResponseMeta(&result, meta)
+	} else if !goja.IsUndefined(resultVal) && !goja.IsNull(resultVal) {
+		result.HTML = resultVal.String()
+	}
+
+	return result, nil
 }
@@ -13,3 +13,15 @@ func formatGojaError(err error) error {
 
 	return err
 }
+
+// exportObject 将普通 JS 对象导出为 map，非对象（含字符串、null）返回 nil。
+func exportObject(val goja.Value) map[string]any {
+	if val == nil || goja.IsNull(val) || goja.IsUndefined(val) {
+		return nil
+	}
+
+	if m, ok := val.Export().(map[string]any); ok {
+		return m
+	}
+	return nil
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -16,16 +17,29 @@ type Callbacks[T any] struct {
 	TimeoutErr func(time.Duration) error
 }
 
+// Stats 是池的运行状态快照。
+type Stats struct {
+	// Size 当前已创建（含借出）的资源数，MaxSize 为上限，Idle 为池中空闲数。
+	Size    int
+	MaxSize int
+	Idle    int
+	// Waits 因池满而阻塞等待的 Get 次数，WaitTimeouts 为其中等待超时的次数。
+	Waits        uint64
+	WaitTimeouts uint64
+}
+
 // Bounded 提供带容量上限、超时和关闭语义的通用资源池。
 type Bounded[T any] struct {
-	pool        chan T
-	maxSize     int
-	currentSize int
-	mu          sync.Mutex
-	timeout     time.Duration
-	closed      bool
-	done        chan struct{}
-	callbacks   Callbacks[T]
+	pool         chan T
+	maxSize      int
+	currentSize  int
+	mu           sync.Mutex
+	timeout      time.Duration
+	closed       bool
+	done         chan struct{}
+	callbacks    Callbacks[T]
+	waits        atomic.Uint64
+	waitTimeouts atomic.Uint64
 }
 
 // NewBounded 创建一个有界资源池。
@@ -132,6 +146,7 @@ func (p *Bounded[T]) Get(ctx context.Context) (T, error) {
 	}
 	p.mu.Unlock()
 
+	p.waits.Add(1)
 	waitCtx := ctx
 	cancel := func() {}
 	if p.timeout > 0 {
@@ -148,10 +163,26 @@ func (p *Bounded[T]) Get(ctx context.Context) (T, error) {
 		if ctx.Err() != nil {
 			return zero, ctx.Err()
 		}
+		p.waitTimeouts.Add(1)
 		return zero, p.callbacks.TimeoutErr(p.timeout)
 	}
 }
 
+// Stats 返回池的运行状态快照。
+func (p *Bounded[T]) Stats() Stats {
+	p.mu.Lock()
+	size := p.currentSize
+	p.mu.Unlock()
+
+	return Stats{
+		Size:         size,
+		MaxSize:      p.maxSize,
+		Idle:         len(p.pool),
+		Waits:        p.waits.Load(),
+		WaitTimeouts: p.waitTimeouts.Load(),
+	}
+}
+
 // Put 归还资源到池中。
 func (p *Bounded[T]) Put(resource T) {
 	p.callbacks.Reset(resource)
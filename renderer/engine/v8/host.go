@@ -0,0 +1,54 @@
+//go:build !nov8
+
+package v8
+
+import (
+	"context"
+
+	"github.com/daodao97/gossr/renderer"
+	"rogchap.com/v8go"
+)
+
+// installFetch 在 context 上注册 fetch 宿主函数并运行 polyfill，请求使用当前渲染的 ctx。
+func installFetch(ctx context.Context, v8ctx *v8go.Context, polyfill *v8go.UnboundScript) error {
+	iso := v8ctx.Isolate()
+	host := v8go.NewFunctionTemplate(iso, func(info *v8go.FunctionCallbackInfo) *v8go.Value {
+		raw := ""
+		if args := info.Args(); len(args) > 0 {
+			raw = args[0].String()
+		}
+
+		out, err := renderer.ServeFetch(ctx, raw)
+		if err != nil {
+			msg, _ := v8go.NewValue(iso, err.Error())
+			return iso.ThrowException(msg)
+		}
+
+		val, _ := v8go.NewValue(iso, out)
+		return val
+	})
+	if err := v8ctx.Global().Set(renderer.FetchGlobal, host.GetFunction(v8ctx)); err != nil {
+		return err
+	}
+
+	_, err := polyfill.Run(v8ctx)
+	return err
+}
+
+// installConsole 在 context 上注册 console 宿主函数并运行 polyfill，输出写入 isolate 绑定的 Console。
+func installConsole(ctx context.Context, v8ctx *v8go.Context, console *renderer.Console, polyfill *v8go.UnboundScript) error {
+	host := v8go.NewFunctionTemplate(v8ctx.Isolate(), func(info *v8go.FunctionCallbackInfo) *v8go.Value {
+		args := info.Args()
+		if len(args) < 2 {
+			return nil
+		}
+		console.Log(ctx, args[0].String(), args[1].String())
+		return nil
+	})
+	if err := v8ctx.Global().Set(renderer.ConsoleGlobal, host.GetFunction(v8ctx)); err != nil {
+		return err
+	}
+
+	_, err := polyfill.Run(v8ctx)
+	return err
+}
@@ -6,12 +6,10 @@ import (
 	"context"
 	"fmt"
 	"log"
-	"os"
 	"runtime"
-	"strconv"
-	"strings"
 	"time"
 
+	"github.com/daodao97/gossr/renderer"
 	internalpool "github.com/daodao97/gossr/renderer/engine/internal/pool"
 	"rogchap.com/v8go"
 )
@@ -27,17 +25,23 @@ const (
 type V8IsolateContainer struct {
 	Isolate      *v8go.Isolate
 	RenderScript *v8go.UnboundScript
+	// FetchScript 与 ConsoleScript 为宿主 polyfill，每个 context 在执行 RenderScript 前运行。
+	FetchScript   *v8go.UnboundScript
+	ConsoleScript *v8go.UnboundScript
+	// Console 接收该 isolate 上的 console 输出，按 isolate 限流。
+	Console *renderer.Console
 }
 
 // V8IsolatePool 支持动态扩缩容的有界池。
 type V8IsolatePool struct {
 	ssrScriptContent string
 	ssrScriptName    string
+	console          renderer.ConsoleConfig
 	bounded          *internalpool.Bounded[*V8IsolateContainer]
 }
 
 // NewV8IsolatePool 创建预热的 V8 isolate 池。
-func NewV8IsolatePool(ssrScriptContents string, ssrScriptName string) *V8IsolatePool {
+func NewV8IsolatePool(ssrScriptContents string, ssrScriptName string, cfg renderer.PoolConfig, opts ...renderer.Option) *V8IsolatePool {
 	defaultPoolSize := runtime.NumCPU() * 4
 	if defaultPoolSize < minV8PoolSize {
 		defaultPoolSize = minV8PoolSize
@@ -46,14 +50,15 @@ func NewV8IsolatePool(ssrScriptContents string, ssrScriptName string) *V8Isolate
 		defaultPoolSize = maxV8PoolSize
 	}
 
-	// 池大小优先从环境变量读取，否则使用 CPU 核心数 * 4。
-	poolSize := parseV8PoolSize(defaultPoolSize)
+	// 池大小优先使用配置值，否则使用 CPU 核心数 * 4。
+	poolSize := v8PoolSize(cfg.Size, defaultPoolSize)
 	// 获取超时配置 (默认 5 秒)。
-	timeout := parseV8PoolTimeout(defaultV8PoolTimeout)
+	timeout := v8PoolTimeout(cfg.Timeout, defaultV8PoolTimeout)
 
 	p := &V8IsolatePool{
 		ssrScriptContent: ssrScriptContents,
 		ssrScriptName:    ssrScriptName,
+		console:          renderer.NewConfig(opts...).Console,
 	}
 	p.bounded = internalpool.NewBounded[*V8IsolateContainer](
 		poolSize,
@@ -81,46 +86,32 @@ func NewV8IsolatePool(ssrScriptContents string, ssrScriptName string) *V8Isolate
 	return p
 }
 
-func parseV8PoolSize(defaultSize int) int {
-	raw := strings.TrimSpace(os.Getenv("V8_POOL_SIZE"))
-	if raw == "" {
-		return defaultSize
-	}
-
-	size, err := strconv.Atoi(raw)
-	if err != nil || size <= 0 {
-		log.Printf("config: invalid V8_POOL_SIZE=%q, use default %d", raw, defaultSize)
+// v8PoolSize 归一化池大小：未配置时使用默认值，并限制在 [min, max] 区间。
+func v8PoolSize(size int, defaultSize int) int {
+	if size <= 0 {
 		return defaultSize
 	}
 	if size < minV8PoolSize {
-		log.Printf("config: V8_POOL_SIZE=%d below min %d, clamped", size, minV8PoolSize)
+		log.Printf("config: v8 pool size %d below min %d, clamped", size, minV8PoolSize)
 		return minV8PoolSize
 	}
 	if size > maxV8PoolSize {
-		log.Printf("config: V8_POOL_SIZE=%d exceeds max %d, clamped", size, maxV8PoolSize)
+		log.Printf("config: v8 pool size %d exceeds max %d, clamped", size, maxV8PoolSize)
 		return maxV8PoolSize
 	}
 	return size
 }
 
-func parseV8PoolTimeout(defaultTimeout time.Duration) time.Duration {
-	raw := strings.TrimSpace(os.Getenv("V8_POOL_TIMEOUT"))
-	if raw == "" {
-		return defaultTimeout
-	}
-
-	timeout, err := time.ParseDuration(raw)
-	if err != nil {
-		log.Printf("config: invalid V8_POOL_TIMEOUT=%q, use default %s", raw, defaultTimeout)
+// v8PoolTimeout 归一化获取超时：0 使用默认值，负值表示不设超时。
+func v8PoolTimeout(timeout time.Duration, defaultTimeout time.Duration) time.Duration {
+	if timeout == 0 {
 		return defaultTimeout
 	}
-
 	if timeout < 0 {
-		log.Printf("config: V8_POOL_TIMEOUT=%q is negative, treated as 0", raw)
 		return 0
 	}
 	if timeout > maxV8PoolTimeout {
-		log.Printf("config: V8_POOL_TIMEOUT=%s exceeds max %s, clamped", timeout, maxV8PoolTimeout)
+		log.Printf("config: v8 pool timeout %s exceeds max %s, clamped", timeout, maxV8PoolTimeout)
 		return maxV8PoolTimeout
 	}
 	return timeout
@@ -138,9 +129,22 @@ func (p *V8IsolatePool) createIsolate() *V8IsolateContainer {
 		panic("failed to compile SSR script: " + err.Error())
 	}
 
+	fetchScript, err := isolate.CompileUnboundScript(renderer.FetchPolyfill, "ssr-fetch.js", v8go.CompileOptions{})
+	if err != nil {
+		panic("failed to compile fetch polyfill: " + err.Error())
+	}
+
+	consoleScript, err := isolate.CompileUnboundScript(renderer.ConsolePolyfill, "ssr-console.js", v8go.CompileOptions{})
+	if err != nil {
+		panic("failed to compile console polyfill: " + err.Error())
+	}
+
 	return &V8IsolateContainer{
-		Isolate:      isolate,
-		RenderScript: script,
+		Isolate:       isolate,
+		RenderScript:  script,
+		FetchScript:   fetchScript,
+		ConsoleScript: consoleScript,
+		Console:       renderer.NewConsole(p.console),
 	}
 }
 
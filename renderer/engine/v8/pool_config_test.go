@@ -5,54 +5,52 @@ package v8
 import (
 	"testing"
 	"time"
+
+	"github.com/daodao97/gossr/renderer"
 )
 
-func TestParseV8PoolSize(t *testing.T) {
+func TestV8PoolSize(t *testing.T) {
 	const defaultSize = 32
 
 	tests := []struct {
 		name string
-		env  string
+		size int
 		want int
 	}{
-		{name: "default when empty", env: "", want: defaultSize},
-		{name: "invalid fallback", env: "abc", want: defaultSize},
-		{name: "below min clamp", env: "1", want: minV8PoolSize},
-		{name: "above max clamp", env: "9999", want: maxV8PoolSize},
-		{name: "valid value", env: "64", want: 64},
+		{name: "default when unset", size: 0, want: defaultSize},
+		{name: "negative fallback", size: -3, want: defaultSize},
+		{name: "below min clamp", size: 1, want: minV8PoolSize},
+		{name: "above max clamp", size: 9999, want: maxV8PoolSize},
+		{name: "valid value", size: 64, want: 64},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			t.Setenv("V8_POOL_SIZE", tt.env)
-			if got := parseV8PoolSize(defaultSize); got != tt.want {
-				t.Fatalf("parseV8PoolSize(%q)=%d, want %d", tt.env, got, tt.want)
+			if got := v8PoolSize(tt.size, defaultSize); got != tt.want {
+				t.Fatalf("v8PoolSize(%d)=%d, want %d", tt.size, got, tt.want)
 			}
 		})
 	}
 }
 
-func TestParseV8PoolTimeout(t *testing.T) {
+func TestV8PoolTimeout(t *testing.T) {
 	defaultTimeout := defaultV8PoolTimeout
 
 	tests := []struct {
-		name string
-		env  string
-		want time.Duration
+		name    string
+		timeout time.Duration
+		want    time.Duration
 	}{
-		{name: "default when empty", env: "", want: defaultTimeout},
-		{name: "invalid fallback", env: "abc", want: defaultTimeout},
-		{name: "negative to zero", env: "-1s", want: 0},
-		{name: "above max clamp", env: "60s", want: maxV8PoolTimeout},
-		{name: "valid timeout", env: "250ms", want: 250 * time.Millisecond},
-		{name: "no unit fallback", env: "5", want: defaultTimeout},
+		{name: "default when unset", timeout: 0, want: defaultTimeout},
+		{name: "negative disables timeout", timeout: renderer.NoPoolTimeout, want: 0},
+		{name: "above max clamp", timeout: 60 * time.Second, want: maxV8PoolTimeout},
+		{name: "valid timeout", timeout: 250 * time.Millisecond, want: 250 * time.Millisecond},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			t.Setenv("V8_POOL_TIMEOUT", tt.env)
-			if got := parseV8PoolTimeout(defaultTimeout); got != tt.want {
-				t.Fatalf("parseV8PoolTimeout(%q)=%s, want %s", tt.env, got, tt.want)
+			if got := v8PoolTimeout(tt.timeout, defaultTimeout); got != tt.want {
+				t.Fatalf("v8PoolTimeout(%s)=%s, want %s", tt.timeout, got, tt.want)
 			}
 		})
 	}
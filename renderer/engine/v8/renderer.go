@@ -5,8 +5,10 @@ package v8
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"html/template"
+	"io"
 	"strconv"
 	"sync/atomic"
 
@@ -14,6 +16,9 @@ import (
 	"rogchap.com/v8go"
 )
 
+// streamWriteGlobal 是流式渲染时注入的 write 回调名称。
+const streamWriteGlobal = "__SSR_WRITE__"
+
 // Renderer renders a React application to HTML via v8go。
 type Renderer struct {
 	pool          *V8IsolatePool
@@ -21,27 +26,124 @@ type Renderer struct {
 }
 
 // NewRenderer 创建 v8go 渲染器。
-func NewRenderer(scriptContents string) *Renderer {
+func NewRenderer(scriptContents string, poolConfig renderer.PoolConfig, opts ...renderer.Option) *Renderer {
+	// 预热在后台协程中创建 isolate，先同步编译一次，使脚本错误与 goja 版本一样在此处 panic。
+	cfg := renderer.NewConfig(opts...)
+	iso := v8go.NewIsolate()
+	_, err := iso.CompileUnboundScript(scriptContents, cfg.ScriptName, v8go.CompileOptions{})
+	iso.Dispose()
+	if err != nil {
+		panic(fmt.Errorf("compile ssr script: %w", err))
+	}
+
 	return &Renderer{
-		pool:          NewV8IsolatePool(scriptContents, renderer.DefaultSSRScriptName),
-		ssrScriptName: renderer.DefaultSSRScriptName,
+		pool:          NewV8IsolatePool(scriptContents, cfg.ScriptName, poolConfig, opts...),
+		ssrScriptName: cfg.ScriptName,
 	}
 }
 
+// PoolStats 返回 isolate 池的运行状态。
+func (r *Renderer) PoolStats() renderer.PoolStats {
+	stats := r.pool.bounded.Stats()
+	return renderer.PoolStats{
+		Size:         stats.Size,
+		MaxSize:      stats.MaxSize,
+		Idle:         stats.Idle,
+		Waits:        stats.Waits,
+		WaitTimeouts: stats.WaitTimeouts,
+	}
+}
+
+// Close 关闭 isolate 池，热重载替换渲染器后调用。
+func (r *Renderer) Close() {
+	r.pool.Close()
+}
+
 // Render renders the provided path to HTML with optional data payload.
 func (r *Renderer) Render(ctx context.Context, urlPath string, payload map[string]any) (renderer.Result, error) {
+	return r.execute(ctx, urlPath, payload, func(v8ctx *v8go.Context, args string) (*v8go.Value, error) {
+		return v8ctx.RunScript("ssrRender("+args+")", r.ssrScriptName)
+	})
+}
+
+// RenderIsland 执行 ssrRenderers[name](url, data, request)，返回值约定与 ssrRender 相同。
+func (r *Renderer) RenderIsland(ctx context.Context, name string, urlPath string, payload map[string]any) (renderer.Result, error) {
+	return r.execute(ctx, urlPath, payload, func(v8ctx *v8go.Context, args string) (*v8go.Value, error) {
+		key := "[" + strconv.Quote(name) + "]"
+		kind, err := v8ctx.RunScript("typeof (globalThis."+renderer.IslandsGlobal+" || {})"+key, r.ssrScriptName)
+		if err != nil {
+			return nil, err
+		}
+		if kind.String() != "function" {
+			return nil, fmt.Errorf("%w: %s", renderer.ErrIslandNotFound, name)
+		}
+		return v8ctx.RunScript("(function(url, request) { return "+renderer.IslandsGlobal+key+"(url, globalThis.__SSR_DATA__, request) })("+args+")", r.ssrScriptName)
+	})
+}
+
+// RenderStream 执行 ssrRenderStream(url, request, write)，JS 侧每次 write 都会直接写入 w。
+func (r *Renderer) RenderStream(ctx context.Context, urlPath string, payload map[string]any, w io.Writer) (renderer.Result, error) {
+	result, err := r.execute(ctx, urlPath, payload, func(v8ctx *v8go.Context, args string) (*v8go.Value, error) {
+		kind, err := v8ctx.RunScript("typeof ssrRenderStream", r.ssrScriptName)
+		if err != nil {
+			return nil, err
+		}
+		if kind.String() != "function" {
+			return nil, renderer.ErrStreamingUnsupported
+		}
+
+		iso := v8ctx.Isolate()
+		write := v8go.NewFunctionTemplate(iso, func(info *v8go.FunctionCallbackInfo) *v8go.Value {
+			callArgs := info.Args()
+			if len(callArgs) == 0 || callArgs[0].IsNullOrUndefined() {
+				return nil
+			}
+			if _, err := io.WriteString(w, callArgs[0].String()); err != nil {
+				msg, _ := v8go.NewValue(iso, err.Error())
+				return iso.ThrowException(msg)
+			}
+			return nil
+		})
+		if err := v8ctx.Global().Set(streamWriteGlobal, write.GetFunction(v8ctx)); err != nil {
+			return nil, err
+		}
+		return v8ctx.RunScript("ssrRenderStream("+args+", "+streamWriteGlobal+")", r.ssrScriptName)
+	})
+	if err != nil {
+		return renderer.Result{}, err
+	}
+
+	// 未使用 write 而是直接返回 HTML 时，同样写入流中。
+	if result.HTML != "" {
+		if _, err := io.WriteString(w, result.HTML); err != nil {
+			return renderer.Result{}, err
+		}
+		result.HTML = ""
+	}
+	return result, nil
+}
+
+// execute 从池中取出 isolate，注入 SSR 数据并执行脚本后调用 call，args 为已序列化的 (url, request) 参数列表。
+func (r *Renderer) execute(ctx context.Context, urlPath string, payload map[string]any, call func(*v8go.Context, string) (*v8go.Value, error)) (_ renderer.Result, err error) {
 	if ctx == nil {
 		ctx = context.Background()
 	}
 
+	_, acquireSpan := renderer.StartSpan(ctx, renderer.SpanPoolAcquire)
 	iso, err := r.pool.Get(ctx)
+	renderer.EndSpan(acquireSpan, err)
 	if err != nil {
 		return renderer.Result{}, err
 	}
 
+	ctx, span := renderer.StartSpan(ctx, renderer.SpanJSExecute)
+	defer func() { renderer.EndSpan(span, err) }()
+
 	var terminated atomic.Bool
 	stopWatch := make(chan struct{})
+	watchDone := make(chan struct{})
 	go func() {
+		defer close(watchDone)
 		select {
 		case <-ctx.Done():
 			terminated.Store(true)
@@ -50,8 +152,10 @@ func (r *Renderer) Render(ctx context.Context, urlPath string, payload map[strin
 		}
 	}()
 
-	defer close(stopWatch)
+	// 归还前先等待监听协程退出，避免 iso 回到池中后才被中断。
 	defer func() {
+		close(stopWatch)
+		<-watchDone
 		if terminated.Load() || iso.Isolate.IsExecutionTerminating() {
 			r.pool.Discard(iso)
 			return
@@ -78,6 +182,13 @@ func (r *Renderer) Render(ctx context.Context, urlPath string, payload map[strin
 		}
 	}
 
+	if err := installConsole(ctx, v8ctx, iso.Console, iso.ConsoleScript); err != nil {
+		return renderer.Result{}, formatV8Error(err)
+	}
+	if err := installFetch(ctx, v8ctx, iso.FetchScript); err != nil {
+		return renderer.Result{}, formatV8Error(err)
+	}
+
 	if _, err := iso.RenderScript.Run(v8ctx); err != nil {
 		if terminated.Load() && ctx.Err() != nil {
 			return renderer.Result{}, ctx.Err()
@@ -85,30 +196,34 @@ func (r *Renderer) Render(ctx context.Context, urlPath string, payload map[strin
 		return renderer.Result{}, formatV8Error(err)
 	}
 
-	quotedPath := strconv.Quote(urlPath)
-	renderCmd := fmt.Sprintf("ssrRender(%s)", quotedPath)
-	val, err := v8ctx.RunScript(renderCmd, r.ssrScriptName)
+	args := strconv.Quote(urlPath) + ", undefined"
+	if req := renderer.RequestFromContext(ctx); req != nil {
+		reqJSON, err := json.Marshal(req.AsMap())
+		if err != nil {
+			return renderer.Result{}, err
+		}
+		args = fmt.Sprintf(`%s, JSON.parse("%s")`, strconv.Quote(urlPath), template.JSEscapeString(string(reqJSON)))
+	}
+	val, err := call(v8ctx, args)
 	if err != nil {
 		if terminated.Load() && ctx.Err() != nil {
 			return renderer.Result{}, ctx.Err()
 		}
+		if errors.Is(err, renderer.ErrStreamingUnsupported) {
+			return renderer.Result{}, err
+		}
 		return renderer.Result{}, formatV8Error(err)
 	}
 
-	renderedHtml := ""
-
+	resultVal := val
 	if val.IsPromise() {
-		result, err := resolveV8Promise(v8ctx, val, err, ctx)
+		resultVal, err = resolveV8Promise(v8ctx, val, err, ctx)
 		if err != nil {
 			if terminated.Load() && ctx.Err() != nil {
 				return renderer.Result{}, ctx.Err()
 			}
 			return renderer.Result{}, formatV8Error(err)
 		}
-
-		renderedHtml = result.String()
-	} else {
-		renderedHtml = val.String()
 	}
 
 	headVal, err := v8ctx.RunScript("globalThis.__SSR_HEAD__ || ''", "ssr-head.js")
@@ -124,8 +239,28 @@ func (r *Renderer) Render(ctx context.Context, urlPath string, payload map[strin
 		headContent = headVal.String()
 	}
 
-	return renderer.Result{
-		HTML: renderedHtml,
-		Head: headContent,
-	}, nil
+	result := renderer.Result{Head: headContent}
+
+	responseVal, err := v8ctx.Global().Get(renderer.ResponseGlobal)
+	if err != nil {
+		return renderer.Result{}, formatV8Error(err)
+	}
+	responseMeta, err := exportV8Object(v8ctx, responseVal)
+	if err != nil {
+		return renderer.Result{}, err
+	}
+	renderer.ApplyResponseMeta(&result, responseMeta)
+
+	// ssrRender 可直接返回 { html, head, status, redirect, headers }。
+	resultMeta, err := exportV8Object(v8ctx, resultVal)
+	if err != nil {
+		return renderer.Result{}, err
+	}
+	if resultMeta != nil {
+		renderer.ApplyResponseMeta(&result, resultMeta)
+	} else if !resultVal.IsNullOrUndefined() {
+		result.HTML = resultVal.String()
+	}
+
+	return result, nil
 }
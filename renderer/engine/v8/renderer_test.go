@@ -0,0 +1,240 @@
+//go:build !nov8
+
+package v8
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/daodao97/gossr/renderer"
+)
+
+func TestRendererResponseMeta(t *testing.T) {
+	r := NewRenderer(`globalThis.ssrRender = async function(url) {
+		if (url === "/redirect") {
+			globalThis.__SSR_RESPONSE__ = { status: 301, redirect: "/next" }
+			return ""
+		}
+		globalThis.__SSR_HEAD__ = "<title>t</title>"
+		return { html: "<p>" + url + "</p>", status: 404, headers: { "Set-Cookie": ["a=1", "b=2"], "X-Id": "x" } }
+	}`, renderer.PoolConfig{Size: 8})
+
+	result, err := r.Render(context.Background(), "/missing", nil)
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	if result.HTML != "<p>/missing</p>" || result.Head != "<title>t</title>" {
+		t.Fatalf("unexpected html/head: %+v", result)
+	}
+	if result.Status != http.StatusNotFound {
+		t.Fatalf("expected status 404, got %d", result.Status)
+	}
+	if got := result.Headers.Values("Set-Cookie"); len(got) != 2 {
+		t.Fatalf("expected two Set-Cookie values, got %#v", got)
+	}
+
+	result, err = r.Render(context.Background(), "/redirect", nil)
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	if result.Redirect != "/next" || result.Status != http.StatusMovedPermanently {
+		t.Fatalf("unexpected redirect result: %+v", result)
+	}
+}
+
+func TestRendererPassesRequestArgument(t *testing.T) {
+	r := NewRenderer(`globalThis.ssrRender = function(url, request) {
+		if (!request) return "no-request:" + url
+		return url + "|" + request.method + "|" + request.cookies.theme + "|" + request.headers["user-agent"]
+	}`, renderer.PoolConfig{Size: 8})
+
+	result, err := r.Render(context.Background(), "/a?b=1", nil)
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	if result.HTML != "no-request:/a?b=1" {
+		t.Fatalf("unexpected html without request: %q", result.HTML)
+	}
+
+	ctx := renderer.ContextWithRequest(context.Background(), &renderer.Request{
+		URL:     "/a?b=1",
+		Method:  http.MethodGet,
+		Headers: map[string]string{"user-agent": "test-agent"},
+		Cookies: map[string]string{"theme": "dark"},
+	})
+	result, err = r.Render(ctx, "/a?b=1", nil)
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	if result.HTML != `/a?b=1|GET|dark|test-agent` {
+		t.Fatalf("unexpected html with request: %q", result.HTML)
+	}
+}
+
+func TestRendererRenderStream(t *testing.T) {
+	r := NewRenderer(`globalThis.ssrRenderStream = async function(url, request, write) {
+		write("<main>")
+		await Promise.resolve()
+		write(url + "|" + (request ? request.method : "none"))
+		write("</main>")
+	}`, renderer.PoolConfig{Size: 8})
+
+	ctx := renderer.ContextWithRequest(context.Background(), &renderer.Request{Method: http.MethodGet})
+	var out strings.Builder
+	result, err := r.RenderStream(ctx, "/s", nil, &out)
+	if err != nil {
+		t.Fatalf("render stream failed: %v", err)
+	}
+	if out.String() != "<main>/s|GET</main>" {
+		t.Fatalf("unexpected streamed html: %q", out.String())
+	}
+	if result.HTML != "" {
+		t.Fatalf("expected streamed result html to be empty, got %q", result.HTML)
+	}
+
+	buffered := NewRenderer(`globalThis.ssrRender = function(url) { return url }`, renderer.PoolConfig{Size: 8})
+	if _, err := buffered.RenderStream(context.Background(), "/s", nil, &out); !errors.Is(err, renderer.ErrStreamingUnsupported) {
+		t.Fatalf("expected ErrStreamingUnsupported, got %v", err)
+	}
+}
+
+func TestRendererRenderIsland(t *testing.T) {
+	r := NewRenderer(`globalThis.ssrRenderers = {
+		cart: async function(url, data, request) {
+			globalThis.__SSR_HEAD__ = "<style>.cart{}</style>"
+			return { html: "<b>" + url + "|" + data.items + "|" + (request ? request.method : "none") + "</b>", modules: ["src/Cart.vue"] }
+		},
+		broken: function() { throw new Error("boom") }
+	}`, renderer.PoolConfig{Size: 8})
+
+	ctx := renderer.ContextWithRequest(context.Background(), &renderer.Request{Method: http.MethodGet})
+	result, err := r.RenderIsland(ctx, "cart", "/shop", map[string]any{"items": 3})
+	if err != nil {
+		t.Fatalf("render island failed: %v", err)
+	}
+	if result.HTML != "<b>/shop|3|GET</b>" || result.Head != "<style>.cart{}</style>" || len(result.Modules) != 1 {
+		t.Fatalf("unexpected island result: %+v", result)
+	}
+
+	if _, err := r.RenderIsland(context.Background(), "broken", "/shop", nil); err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Fatalf("expected island error, got %v", err)
+	}
+	if _, err := r.RenderIsland(context.Background(), "missing", "/shop", nil); !errors.Is(err, renderer.ErrIslandNotFound) {
+		t.Fatalf("expected ErrIslandNotFound, got %v", err)
+	}
+}
+
+func TestRendererCancelAfterRenderKeepsPooledRuntime(t *testing.T) {
+	r := NewRenderer(`globalThis.ssrRender = function(url) {
+		let n = 0
+		for (let i = 0; i < 20000; i++) n += i
+		return url + ":" + n
+	}`, renderer.PoolConfig{Size: 1})
+
+	// 池中只有一个 runtime：渲染结束时取消上下文，不应中断已归还的 runtime。
+	for i := 0; i < 200; i++ {
+		ctx, cancel := context.WithCancel(context.Background())
+		if _, err := r.Render(ctx, "/a", nil); err != nil {
+			cancel()
+			t.Fatalf("render %d failed: %v", i, err)
+		}
+		cancel()
+
+		result, err := r.Render(context.Background(), "/b", nil)
+		if err != nil {
+			t.Fatalf("render after cancel %d failed: %v", i, err)
+		}
+		if result.HTML != "/b:199990000" {
+			t.Fatalf("unexpected html: %q", result.HTML)
+		}
+	}
+}
+
+func TestRendererFetchPolyfill(t *testing.T) {
+	r := NewRenderer(`globalThis.ssrRender = async function(url) {
+		const resp = await fetch("/api/items?q=1", { headers: { "X-Trace": "t1" } })
+		const data = await resp.json()
+		let blocked = ""
+		try { await fetch("/blocked") } catch (err) { blocked = err.message }
+		return resp.status + "|" + resp.ok + "|" + resp.headers.get("content-type") + "|" + data.url + "|" + data.trace + "|" + blocked
+	}`, renderer.PoolConfig{Size: 8})
+
+	var gotCtx bool
+	fetch := func(req *http.Request) (*http.Response, error) {
+		if req.URL.Path == "/blocked" {
+			return nil, errors.New("not allowed")
+		}
+		_, gotCtx = req.Context().Deadline()
+		body := `{"url":"` + req.URL.String() + `","trace":"` + req.Header.Get("X-Trace") + `"}`
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": []string{"application/json"}},
+			Body:       io.NopCloser(strings.NewReader(body)),
+		}, nil
+	}
+
+	ctx := renderer.ContextWithRequest(context.Background(), &renderer.Request{Origin: "https://example.com"})
+	ctx = renderer.ContextWithFetch(ctx, fetch)
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	result, err := r.Render(ctx, "/", nil)
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	want := "200|true|application/json|https://example.com/api/items?q=1|t1|fetch failed: not allowed"
+	if result.HTML != want {
+		t.Fatalf("unexpected html:\n got %q\nwant %q", result.HTML, want)
+	}
+	if !gotCtx {
+		t.Fatal("expected fetch request to carry the render deadline")
+	}
+}
+
+func TestRendererConsoleToSlog(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	r := NewRenderer(`globalThis.ssrRender = function(url) {
+		console.error("boom", { code: 1 }, new Error("bad").message)
+		console.debug("dbg")
+		for (let i = 0; i < 5; i++) console.log("noisy", i)
+		return "ok"
+	}`, renderer.PoolConfig{Size: 8}, renderer.WithConsole(renderer.ConsoleConfig{
+		Logger:    logger,
+		RateLimit: 0.001,
+		Burst:     3,
+	}))
+
+	ctx := renderer.ContextWithRequest(context.Background(), &renderer.Request{ID: "req-1", Path: "/p"})
+	if _, err := r.Render(ctx, "/p", nil); err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+
+	var records []map[string]any
+	for _, line := range bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n")) {
+		var rec map[string]any
+		if err := json.Unmarshal(line, &rec); err != nil {
+			t.Fatalf("decode log line %q: %v", line, err)
+		}
+		records = append(records, rec)
+	}
+
+	if len(records) != 3 {
+		t.Fatalf("expected 3 records after rate limiting, got %d: %s", len(records), buf.String())
+	}
+	first := records[0]
+	if first["level"] != "ERROR" || first["msg"] != `boom {"code":1} bad` || first["req_id"] != "req-1" || first["path"] != "/p" {
+		t.Fatalf("unexpected error record: %#v", first)
+	}
+	if records[1]["level"] != "DEBUG" || records[2]["level"] != "INFO" {
+		t.Fatalf("unexpected level mapping: %#v", records)
+	}
+}
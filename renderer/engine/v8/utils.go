@@ -4,6 +4,7 @@ package v8
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 
@@ -46,3 +47,21 @@ func formatV8Error(err error) error {
 
 	return err
 }
+
+// exportV8Object 将普通 JS 对象经 JSON 转为 map，非对象（含字符串、数组、null）返回 nil。
+func exportV8Object(v8ctx *v8go.Context, val *v8go.Value) (map[string]any, error) {
+	if val == nil || val.IsNullOrUndefined() || !val.IsObject() || val.IsArray() || val.IsFunction() {
+		return nil, nil
+	}
+
+	raw, err := v8go.JSONStringify(v8ctx, val)
+	if err != nil {
+		return nil, formatV8Error(err)
+	}
+
+	var meta map[string]any
+	if err := json.Unmarshal([]byte(raw), &meta); err != nil {
+		return nil, fmt.Errorf("decode ssr response object: %w", err)
+	}
+	return meta, nil
+}
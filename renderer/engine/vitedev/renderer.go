@@ -0,0 +1,204 @@
+// Package vitedev 在开发模式下从 Vite dev server 获取 SSR 入口并交给 goja/v8 执行。
+//
+// Vite 侧需要启用 example/web/plugins/gossr-dev.ts 插件，它在 dev server 上暴露两个端点：
+// EntryPath 返回按 vite.config.server.ts 打包、经 Vite 插件链转换后的 SSR 入口，
+// IndexPath 返回经 transformIndexHtml 处理（含 /@vite/client）的 index.html。
+// 两者均以 ETag 标记模块图版本，源码变化后版本递增，Renderer 据此重新编译。
+package vitedev
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/daodao97/gossr/renderer"
+)
+
+const (
+	// EntryPath 为插件暴露的 SSR 入口地址。
+	EntryPath = "/__gossr/server.js"
+	// IndexPath 为插件暴露的 index.html 地址。
+	IndexPath = "/__gossr/index.html"
+
+	maxEntryBytes  = 64 << 20
+	requestTimeout = 30 * time.Second
+)
+
+// CompileFunc 用 SSR 脚本创建实际执行渲染的引擎。
+type CompileFunc func(script string) (renderer.Renderer, error)
+
+// Renderer 实现 renderer.Renderer，每次同步时向 Vite 询问入口版本，变化后重新编译并替换引擎。
+type Renderer struct {
+	baseURL string
+	client  *http.Client
+	compile CompileFunc
+
+	mu        sync.Mutex
+	version   string
+	indexHTML string
+	engine    *devEngine
+}
+
+// devEngine 记录一次编译出的引擎及其上进行中的渲染，被替换后等渲染结束再关闭。
+type devEngine struct {
+	renderer.Renderer
+	inflight sync.WaitGroup
+}
+
+func (e *devEngine) release() {
+	e.inflight.Done()
+}
+
+// retire 等待进行中的渲染结束后关闭引擎；调用前 e 必须已从 Renderer 上摘下，不再登记新的渲染。
+func (e *devEngine) retire() {
+	e.inflight.Wait()
+	if closer, ok := e.Renderer.(renderer.Closer); ok {
+		closer.Close()
+	}
+}
+
+// NewRenderer 创建从 devServerURL 获取 SSR 入口的渲染器，首次 Sync 或 Render 时才会请求 Vite。
+func NewRenderer(devServerURL string, compile CompileFunc) *Renderer {
+	return &Renderer{
+		baseURL: strings.TrimRight(devServerURL, "/"),
+		client:  &http.Client{Timeout: requestTimeout},
+		compile: compile,
+	}
+}
+
+// Sync 向 Vite 查询入口版本，未变化时直接返回当前 index.html；
+// 变化时重新获取 index.html 与 SSR 入口并编译，编译失败时保留旧引擎并返回错误；
+// 旧引擎在其上进行中的渲染结束后于后台关闭。
+func (r *Renderer) Sync(ctx context.Context) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	script, version, modified, err := r.get(ctx, EntryPath, r.version)
+	if err != nil {
+		return "", err
+	}
+	if !modified {
+		return r.indexHTML, nil
+	}
+
+	indexHTML, _, _, err := r.get(ctx, IndexPath, "")
+	if err != nil {
+		return "", err
+	}
+	engine, err := r.compile(script)
+	if err != nil {
+		return "", fmt.Errorf("compile vite ssr entry: %w", err)
+	}
+
+	old := r.engine
+	r.engine, r.version, r.indexHTML = &devEngine{Renderer: engine}, version, indexHTML
+	if old != nil {
+		go old.retire()
+	}
+	return indexHTML, nil
+}
+
+// Render 使用当前引擎渲染，尚未同步过时先同步一次。
+func (r *Renderer) Render(ctx context.Context, urlPath string, payload map[string]any) (renderer.Result, error) {
+	engine, err := r.current(ctx)
+	if err != nil {
+		return renderer.Result{}, err
+	}
+	defer engine.release()
+	return engine.Render(ctx, urlPath, payload)
+}
+
+// RenderStream 在当前引擎支持时流式渲染，否则返回 renderer.ErrStreamingUnsupported。
+func (r *Renderer) RenderStream(ctx context.Context, urlPath string, payload map[string]any, w io.Writer) (renderer.Result, error) {
+	engine, err := r.current(ctx)
+	if err != nil {
+		return renderer.Result{}, err
+	}
+	defer engine.release()
+	sr, ok := engine.Renderer.(renderer.StreamRenderer)
+	if !ok {
+		return renderer.Result{}, renderer.ErrStreamingUnsupported
+	}
+	return sr.RenderStream(ctx, urlPath, payload, w)
+}
+
+// RenderIsland 在当前引擎支持命名组件时渲染 island，否则返回 renderer.ErrIslandNotFound。
+func (r *Renderer) RenderIsland(ctx context.Context, name string, urlPath string, payload map[string]any) (renderer.Result, error) {
+	engine, err := r.current(ctx)
+	if err != nil {
+		return renderer.Result{}, err
+	}
+	defer engine.release()
+	ir, ok := engine.Renderer.(renderer.IslandRenderer)
+	if !ok {
+		return renderer.Result{}, fmt.Errorf("%w: %s", renderer.ErrIslandNotFound, name)
+	}
+	return ir.RenderIsland(ctx, name, urlPath, payload)
+}
+
+// Close 等待进行中的渲染结束后关闭当前引擎。
+func (r *Renderer) Close() {
+	r.mu.Lock()
+	old := r.engine
+	r.engine = nil
+	r.version = ""
+	r.mu.Unlock()
+
+	if old != nil {
+		old.retire()
+	}
+}
+
+// current 返回当前引擎并登记一次渲染，调用方结束后必须调用 release；尚未同步过时先同步一次。
+func (r *Renderer) current(ctx context.Context) (*devEngine, error) {
+	for {
+		r.mu.Lock()
+		engine := r.engine
+		if engine != nil {
+			engine.inflight.Add(1)
+		}
+		r.mu.Unlock()
+		if engine != nil {
+			return engine, nil
+		}
+
+		if _, err := r.Sync(ctx); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// get 请求插件端点，version 非空时携带 If-None-Match，304 时 modified 为 false。
+func (r *Renderer) get(ctx context.Context, path string, version string) (body string, etag string, modified bool, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.baseURL+path, nil)
+	if err != nil {
+		return "", "", false, err
+	}
+	if version != "" {
+		req.Header.Set("If-None-Match", version)
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return "", "", false, fmt.Errorf("vite dev server %s: %w", path, err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		return "", version, false, nil
+	case http.StatusOK:
+	default:
+		return "", "", false, fmt.Errorf("vite dev server returned %d for %s", resp.StatusCode, path)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxEntryBytes))
+	if err != nil {
+		return "", "", false, fmt.Errorf("read %s: %w", path, err)
+	}
+	return string(data), resp.Header.Get("ETag"), true, nil
+}
@@ -0,0 +1,172 @@
+package vitedev
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/daodao97/gossr/renderer"
+)
+
+type scriptRenderer struct {
+	script string
+	closed atomic.Bool
+	// block 非 nil 时渲染先通知 started，再等待 block 关闭后返回。
+	started chan struct{}
+	block   chan struct{}
+}
+
+func (r *scriptRenderer) Render(_ context.Context, urlPath string, _ map[string]any) (renderer.Result, error) {
+	if r.block != nil {
+		r.started <- struct{}{}
+		<-r.block
+	}
+	if r.closed.Load() {
+		return renderer.Result{}, errors.New("render on closed engine")
+	}
+	return renderer.Result{HTML: r.script + ":" + urlPath}, nil
+}
+
+func (r *scriptRenderer) Close() {
+	r.closed.Store(true)
+}
+
+func TestRendererRecompilesWhenVersionChanges(t *testing.T) {
+	var version atomic.Int32
+	version.Store(1)
+	var entryFetches atomic.Int32
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		etag := `"` + string(rune('0'+version.Load())) + `"`
+		switch r.URL.Path {
+		case EntryPath:
+			if r.Header.Get("If-None-Match") == etag {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			entryFetches.Add(1)
+			w.Header().Set("ETag", etag)
+			_, _ = w.Write([]byte("script" + etag))
+		case IndexPath:
+			_, _ = w.Write([]byte("<html>" + etag + "</html>"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	var compiled []*scriptRenderer
+	r := NewRenderer(srv.URL+"/", func(script string) (renderer.Renderer, error) {
+		engine := &scriptRenderer{script: script}
+		compiled = append(compiled, engine)
+		return engine, nil
+	})
+
+	result, err := r.Render(context.Background(), "/a", nil)
+	if err != nil || result.HTML != `script"1":/a` {
+		t.Fatalf("unexpected first render %q %v", result.HTML, err)
+	}
+
+	index, err := r.Sync(context.Background())
+	if err != nil || index != `<html>"1"</html>` {
+		t.Fatalf("unexpected index %q %v", index, err)
+	}
+	if entryFetches.Load() != 1 || len(compiled) != 1 {
+		t.Fatalf("expected unchanged version to reuse the engine, fetched=%d compiled=%d", entryFetches.Load(), len(compiled))
+	}
+
+	version.Store(2)
+	if index, err = r.Sync(context.Background()); err != nil || !strings.Contains(index, `"2"`) {
+		t.Fatalf("expected refreshed index, got %q %v", index, err)
+	}
+	if len(compiled) != 2 {
+		t.Fatalf("expected recompiled engine, compiled=%d", len(compiled))
+	}
+	waitClosed(t, compiled[0])
+	if result, _ = r.Render(context.Background(), "/b", nil); result.HTML != `script"2":/b` {
+		t.Fatalf("unexpected render after reload %q", result.HTML)
+	}
+}
+
+func TestRendererClosesOldEngineAfterInflightRenders(t *testing.T) {
+	var version atomic.Int32
+	version.Store(1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		etag := `"` + string(rune('0'+version.Load())) + `"`
+		if r.URL.Path == EntryPath && r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		_, _ = w.Write([]byte("script" + etag))
+	}))
+	defer srv.Close()
+
+	first := &scriptRenderer{started: make(chan struct{}, 1), block: make(chan struct{})}
+	var compiled []*scriptRenderer
+	r := NewRenderer(srv.URL, func(script string) (renderer.Renderer, error) {
+		engine := &scriptRenderer{script: script}
+		if len(compiled) == 0 {
+			engine = first
+			engine.script = script
+		}
+		compiled = append(compiled, engine)
+		return engine, nil
+	})
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := r.Render(context.Background(), "/slow", nil)
+		done <- err
+	}()
+	<-first.started
+
+	// 渲染进行中时源码变化，旧引擎不能被立即关闭。
+	version.Store(2)
+	if _, err := r.Sync(context.Background()); err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if first.closed.Load() {
+		t.Fatal("expected the old engine to stay open while a render is running on it")
+	}
+	if result, err := r.Render(context.Background(), "/b", nil); err != nil || result.HTML != `script"2":/b` {
+		t.Fatalf("expected new renders on the new engine, got %q %v", result.HTML, err)
+	}
+
+	close(first.block)
+	if err := <-done; err != nil {
+		t.Fatalf("in-flight render failed: %v", err)
+	}
+	waitClosed(t, first)
+}
+
+func waitClosed(t *testing.T, engine *scriptRenderer) {
+	t.Helper()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for !engine.closed.Load() {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the old engine to be closed")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestRendererReportsMissingPlugin(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	defer srv.Close()
+
+	r := NewRenderer(srv.URL, func(string) (renderer.Renderer, error) {
+		t.Fatal("compile should not be called")
+		return nil, nil
+	})
+	if _, err := r.Render(context.Background(), "/", nil); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Fatalf("expected plugin error, got %v", err)
+	}
+}
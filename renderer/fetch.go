@@ -0,0 +1,257 @@
+package renderer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// FetchGlobal 是 fetch polyfill 调用的宿主函数名，参数与返回值均为 JSON 字符串。
+const FetchGlobal = "__SSR_FETCH__"
+
+// MaxFetchBodyBytes 单次 fetch 响应体上限。
+const MaxFetchBodyBytes = 10 << 20
+
+// FetchFunc 执行 JS 侧发起的 fetch；req 已绑定渲染 ctx，URL 均为绝对地址。
+type FetchFunc func(req *http.Request) (*http.Response, error)
+
+type fetchContextKey struct{}
+
+// ContextWithFetch 把 FetchFunc 挂到渲染 ctx 上，未设置时 JS 侧 fetch 会直接 reject。
+func ContextWithFetch(ctx context.Context, fetch FetchFunc) context.Context {
+	return context.WithValue(ctx, fetchContextKey{}, fetch)
+}
+
+// FetchFromContext 取出渲染 ctx 上的 FetchFunc。
+func FetchFromContext(ctx context.Context) FetchFunc {
+	if ctx == nil {
+		return nil
+	}
+	fetch, _ := ctx.Value(fetchContextKey{}).(FetchFunc)
+	return fetch
+}
+
+type fetchRequest struct {
+	Method  string      `json:"method"`
+	URL     string      `json:"url"`
+	Headers [][2]string `json:"headers"`
+	Body    *string     `json:"body"`
+}
+
+type fetchResponse struct {
+	Status     int         `json:"status"`
+	StatusText string      `json:"statusText"`
+	URL        string      `json:"url"`
+	Headers    [][2]string `json:"headers"`
+	Body       string      `json:"body"`
+}
+
+// ServeFetch 处理 polyfill 发来的请求：相对地址基于当前请求的 Origin 解析，
+// 经 ctx 上的 FetchFunc 执行后把响应编码回 JSON。引擎在各自的宿主函数中调用它。
+// 当前 ctx 上有 Tracer 时，每次 fetch 记录一个 span，并把链路信息写入出站请求头。
+func ServeFetch(ctx context.Context, raw string) (_ string, err error) {
+	fetch := FetchFromContext(ctx)
+	if fetch == nil {
+		return "", errors.New("fetch is not available in this render")
+	}
+
+	var in fetchRequest
+	if err := json.Unmarshal([]byte(raw), &in); err != nil {
+		return "", fmt.Errorf("invalid fetch request: %w", err)
+	}
+
+	target, err := resolveFetchURL(ctx, in.URL)
+	if err != nil {
+		return "", err
+	}
+
+	method := strings.ToUpper(strings.TrimSpace(in.Method))
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	ctx, span := StartSpan(ctx, SpanFetch)
+	span.SetAttribute("http.request.method", method)
+	span.SetAttribute("url.full", target)
+	defer func() { EndSpan(span, err) }()
+
+	var body io.Reader
+	if in.Body != nil {
+		body = strings.NewReader(*in.Body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return "", err
+	}
+	for _, kv := range in.Headers {
+		req.Header.Add(kv[0], kv[1])
+	}
+	if tracer := TracerFromContext(ctx); tracer != nil {
+		tracer.Inject(ctx, req.Header)
+	}
+
+	resp, err := fetch(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	span.SetAttribute("http.response.status_code", resp.StatusCode)
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, MaxFetchBodyBytes+1))
+	if err != nil {
+		return "", err
+	}
+	if len(data) > MaxFetchBodyBytes {
+		return "", fmt.Errorf("fetch %s: response body exceeds %d bytes", target, MaxFetchBodyBytes)
+	}
+
+	out := fetchResponse{
+		Status:     resp.StatusCode,
+		StatusText: http.StatusText(resp.StatusCode),
+		URL:        target,
+		Body:       string(data),
+	}
+	if resp.Request != nil && resp.Request.URL != nil {
+		out.URL = resp.Request.URL.String()
+	}
+	for name, values := range resp.Header {
+		for _, value := range values {
+			out.Headers = append(out.Headers, [2]string{strings.ToLower(name), value})
+		}
+	}
+
+	encoded, err := json.Marshal(out)
+	if err != nil {
+		return "", err
+	}
+	return string(encoded), nil
+}
+
+func resolveFetchURL(ctx context.Context, raw string) (string, error) {
+	ref, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return "", fmt.Errorf("invalid fetch url %q: %w", raw, err)
+	}
+	if ref.IsAbs() {
+		return ref.String(), nil
+	}
+
+	req := RequestFromContext(ctx)
+	if req == nil || req.Origin == "" {
+		return "", fmt.Errorf("cannot resolve relative fetch url %q without request origin", raw)
+	}
+	base, err := url.Parse(req.Origin + "/")
+	if err != nil {
+		return "", err
+	}
+	return base.ResolveReference(ref).String(), nil
+}
+
+// FetchPolyfill 在 JS 全局注入 fetch/Headers/Request/Response，实际请求交给 FetchGlobal 宿主函数。
+// 请求在渲染线程内同步执行，返回已 resolve 的 Promise；body 仅支持文本。
+const FetchPolyfill = `(function (g) {
+	var host = g.` + FetchGlobal + `;
+	if (typeof host !== "function") return;
+
+	function Headers(init) {
+		this._map = {};
+		if (!init) return;
+		var self = this;
+		if (init instanceof Headers) {
+			init.forEach(function (v, k) { self.append(k, v); });
+		} else if (Array.isArray(init)) {
+			init.forEach(function (kv) { self.append(kv[0], kv[1]); });
+		} else {
+			Object.keys(init).forEach(function (k) { self.append(k, init[k]); });
+		}
+	}
+	Headers.prototype.append = function (k, v) {
+		k = String(k).toLowerCase();
+		v = String(v);
+		this._map[k] = this._map[k] === undefined ? v : this._map[k] + ", " + v;
+	};
+	Headers.prototype.set = function (k, v) { this._map[String(k).toLowerCase()] = String(v); };
+	Headers.prototype.get = function (k) {
+		var v = this._map[String(k).toLowerCase()];
+		return v === undefined ? null : v;
+	};
+	Headers.prototype.has = function (k) { return this._map[String(k).toLowerCase()] !== undefined; };
+	Headers.prototype["delete"] = function (k) { delete this._map[String(k).toLowerCase()]; };
+	Headers.prototype.forEach = function (cb, thisArg) {
+		var self = this;
+		Object.keys(this._map).sort().forEach(function (k) { cb.call(thisArg, self._map[k], k, self); });
+	};
+	Headers.prototype.entries = function () {
+		var self = this;
+		return Object.keys(this._map).sort().map(function (k) { return [k, self._map[k]]; })[Symbol.iterator]();
+	};
+	Headers.prototype.keys = function () { return Object.keys(this._map).sort()[Symbol.iterator](); };
+	Headers.prototype.values = function () {
+		var self = this;
+		return Object.keys(this._map).sort().map(function (k) { return self._map[k]; })[Symbol.iterator]();
+	};
+	Headers.prototype[Symbol.iterator] = Headers.prototype.entries;
+
+	function Request(input, init) {
+		init = init || {};
+		if (input instanceof Request) {
+			this.url = input.url;
+			this.method = init.method || input.method;
+			this.headers = new Headers(init.headers || input.headers);
+			this.body = init.body !== undefined ? init.body : input.body;
+		} else {
+			this.url = String(input);
+			this.method = init.method || "GET";
+			this.headers = new Headers(init.headers);
+			this.body = init.body;
+		}
+		this.method = String(this.method).toUpperCase();
+		if (this.body === undefined) this.body = null;
+		this.signal = init.signal || null;
+	}
+
+	function Response(body, init) {
+		init = init || {};
+		this._body = body === undefined || body === null ? "" : String(body);
+		this.status = init.status === undefined ? 200 : init.status;
+		this.statusText = init.statusText || "";
+		this.headers = new Headers(init.headers);
+		this.url = init.url || "";
+		this.ok = this.status >= 200 && this.status < 300;
+		this.redirected = false;
+		this.bodyUsed = false;
+	}
+	Response.prototype._consume = function () {
+		if (this.bodyUsed) return Promise.reject(new TypeError("body has already been consumed"));
+		this.bodyUsed = true;
+		return Promise.resolve(this._body);
+	};
+	Response.prototype.text = function () { return this._consume(); };
+	Response.prototype.json = function () { return this._consume().then(JSON.parse); };
+	Response.prototype.clone = function () {
+		return new Response(this._body, { status: this.status, statusText: this.statusText, headers: this.headers, url: this.url });
+	};
+
+	g.Headers = Headers;
+	g.Request = Request;
+	g.Response = Response;
+	g.fetch = function fetch(input, init) {
+		try {
+			var req = new Request(input, init);
+			if (req.signal && req.signal.aborted) throw new Error("aborted");
+			var headers = [];
+			req.headers.forEach(function (v, k) { headers.push([k, v]); });
+			var body = req.body === null ? null : String(req.body);
+			var out = JSON.parse(host(JSON.stringify({ method: req.method, url: req.url, headers: headers, body: body })));
+			var resp = new Response(out.body, { status: out.status, statusText: out.statusText, headers: out.headers || [], url: out.url });
+			return Promise.resolve(resp);
+		} catch (err) {
+			return Promise.reject(new TypeError("fetch failed: " + (err && err.message ? err.message : err)));
+		}
+	};
+})(globalThis);`
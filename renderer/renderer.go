@@ -1,8 +1,15 @@
 package renderer
 
-import "context"
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+)
 
 // Renderer 定义 SSR 引擎需要实现的接口。
+// urlPath 为完整的请求 URI（含 query），请求描述通过 ContextWithRequest 随 ctx 传入。
 type Renderer interface {
 	Render(ctx context.Context, urlPath string, payload map[string]any) (Result, error)
 }
@@ -10,6 +17,67 @@ type Renderer interface {
 type Result struct {
 	HTML string
 	Head string
+	// Status 为 0 时按 200 处理。
+	Status int
+	// Redirect 非空时 Go 侧返回重定向，Status 非 3xx 时使用 302。
+	Redirect string
+	// Headers 为 JS 侧追加的响应头（含 Set-Cookie）。
+	Headers http.Header
+	// Modules 为本次渲染用到的模块 ID（Vue/Vite 的 ctx.modules），用于按 SSR manifest 注入预加载链接。
+	Modules []string
 }
 
 const DefaultSSRScriptName = "server.js"
+
+// NoPoolTimeout 表示从池中获取 runtime 时不设置等待超时，仅受 ctx 控制。
+const NoPoolTimeout time.Duration = -1
+
+// PoolConfig 描述引擎 runtime/isolate 池的容量与获取超时。
+// Size 为 0 时使用引擎默认值（CPU 核心数 * 4）；Timeout 为 0 时使用默认 5s，
+// 小于 0（如 NoPoolTimeout）表示不设等待超时。
+type PoolConfig struct {
+	Size    int
+	Timeout time.Duration
+}
+
+// ErrStreamingUnsupported 表示 SSR 脚本未暴露 ssrRenderStream，调用方应回退到缓冲渲染。
+var ErrStreamingUnsupported = errors.New("ssrRenderStream is not a function")
+
+// StreamRenderer 由支持流式输出的引擎实现：调用 ssrRenderStream(url, request, write)，
+// JS 侧通过 write(chunk) 逐块输出 HTML，返回（或 resolve）时表示输出结束。
+// 流式模式下 Result 中的 HTML 为空，Head/Status/Redirect 无法再作用于已发送的响应。
+type StreamRenderer interface {
+	RenderStream(ctx context.Context, urlPath string, payload map[string]any, w io.Writer) (Result, error)
+}
+
+// IslandsGlobal 是 SSR 脚本导出命名组件渲染函数的全局对象名。
+const IslandsGlobal = "ssrRenderers"
+
+// ErrIslandNotFound 表示 ssrRenderers 中不存在对应名称的渲染函数。
+var ErrIslandNotFound = errors.New("ssrRenderers entry is not a function")
+
+// IslandRenderer 由支持命名组件（islands）的引擎实现：调用 ssrRenderers[name](url, data, request)，
+// 返回值约定与 ssrRender 相同。每次调用独占一个 runtime/isolate，多个 island 可并行渲染。
+type IslandRenderer interface {
+	RenderIsland(ctx context.Context, name string, urlPath string, payload map[string]any) (Result, error)
+}
+
+// PoolStats 是引擎 runtime/isolate 池的运行状态快照。
+type PoolStats struct {
+	Size         int
+	MaxSize      int
+	Idle         int
+	Waits        uint64
+	WaitTimeouts uint64
+}
+
+// PoolStatsProvider 由池化的引擎实现，供指标采集读取池状态。
+type PoolStatsProvider interface {
+	PoolStats() PoolStats
+}
+
+// Closer 由持有 runtime/isolate 池的引擎实现。Close 释放空闲资源，
+// 仍在渲染中的资源在归还时释放，之后的 Render 返回池已关闭的错误。
+type Closer interface {
+	Close()
+}
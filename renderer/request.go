@@ -0,0 +1,67 @@
+package renderer
+
+import "context"
+
+// Request 描述当前 HTTP 请求，作为第二个参数传给 ssrRender(url, request)。
+// Headers/Cookies 只包含配置白名单内的条目，header 名统一为小写。
+type Request struct {
+	// ID 为本次请求的标识，与 fallback 页面中的 ssr-error-id 一致。
+	ID       string
+	URL      string
+	Path     string
+	Query    string
+	Method   string
+	Headers  map[string]string
+	Cookies  map[string]string
+	Locale   string
+	Origin   string
+	ClientIP string
+	// Fragment 为 true 时表示经 /_ssr/fragment 请求片段，脚本只需返回组件 HTML。
+	Fragment bool
+}
+
+// AsMap 转为 JS 侧可直接使用的普通对象。
+func (r *Request) AsMap() map[string]any {
+	if r == nil {
+		return nil
+	}
+
+	headers := make(map[string]any, len(r.Headers))
+	for k, v := range r.Headers {
+		headers[k] = v
+	}
+	cookies := make(map[string]any, len(r.Cookies))
+	for k, v := range r.Cookies {
+		cookies[k] = v
+	}
+
+	return map[string]any{
+		"id":       r.ID,
+		"url":      r.URL,
+		"path":     r.Path,
+		"query":    r.Query,
+		"method":   r.Method,
+		"headers":  headers,
+		"cookies":  cookies,
+		"locale":   r.Locale,
+		"origin":   r.Origin,
+		"clientIP": r.ClientIP,
+		"fragment": r.Fragment,
+	}
+}
+
+type requestContextKey struct{}
+
+// ContextWithRequest 将请求描述挂到 ctx 上，供渲染器传入 ssrRender。
+func ContextWithRequest(ctx context.Context, req *Request) context.Context {
+	return context.WithValue(ctx, requestContextKey{}, req)
+}
+
+// RequestFromContext 读取 ContextWithRequest 挂载的请求描述，不存在时返回 nil。
+func RequestFromContext(ctx context.Context) *Request {
+	if ctx == nil {
+		return nil
+	}
+	req, _ := ctx.Value(requestContextKey{}).(*Request)
+	return req
+}
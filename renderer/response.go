@@ -0,0 +1,90 @@
+package renderer
+
+import (
+	"net/http"
+	"strings"
+)
+
+// ResponseGlobal 是 JS 侧声明响应状态、重定向与响应头的全局变量名，
+// 结构与 ssrRender 返回对象一致：{ status, redirect, headers }。
+const ResponseGlobal = "__SSR_RESPONSE__"
+
+// ApplyResponseMeta 将 JS 侧返回的响应描述合并到 Result。
+// 支持字段：html、head、status、redirect、headers（值为字符串或字符串数组）、modules（字符串数组）。
+// 非法的 status 会被忽略；headers 以追加方式合并，便于设置多个 Set-Cookie。
+func ApplyResponseMeta(result *Result, meta map[string]any) {
+	if result == nil || len(meta) == 0 {
+		return
+	}
+
+	if html, ok := meta["html"].(string); ok {
+		result.HTML = html
+	}
+	if head, ok := meta["head"].(string); ok {
+		result.Head = head
+	}
+	if status, ok := toStatusCode(meta["status"]); ok {
+		result.Status = status
+	}
+	if redirect, ok := meta["redirect"].(string); ok && strings.TrimSpace(redirect) != "" {
+		result.Redirect = strings.TrimSpace(redirect)
+	}
+	if modules := toStrings(meta["modules"]); len(modules) > 0 {
+		result.Modules = modules
+	}
+
+	headers, ok := meta["headers"].(map[string]any)
+	if !ok {
+		return
+	}
+	for name, raw := range headers {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		for _, value := range toStrings(raw) {
+			if result.Headers == nil {
+				result.Headers = http.Header{}
+			}
+			result.Headers.Add(name, value)
+		}
+	}
+}
+
+func toStatusCode(raw any) (int, bool) {
+	var status int
+	switch v := raw.(type) {
+	case int:
+		status = v
+	case int64:
+		status = int(v)
+	case float64:
+		status = int(v)
+	default:
+		return 0, false
+	}
+
+	if status < 200 || status > 599 {
+		return 0, false
+	}
+	return status, true
+}
+
+func toStrings(raw any) []string {
+	switch v := raw.(type) {
+	case string:
+		return []string{v}
+	case []string:
+		return v
+	case []any:
+		values := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	default:
+		return nil
+	}
+}
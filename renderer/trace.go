@@ -0,0 +1,72 @@
+package renderer
+
+import (
+	"context"
+	"net/http"
+)
+
+// 渲染链路中各阶段的 span 名称。
+const (
+	SpanPage        = "gossr.page"
+	SpanDataFetch   = "gossr.data_fetch"
+	SpanRenderQueue = "gossr.render_queue"
+	SpanPoolAcquire = "gossr.pool_acquire"
+	SpanJSExecute   = "gossr.js_execute"
+	SpanInject      = "gossr.inject"
+	SpanFetch       = "gossr.fetch"
+)
+
+// Tracer 是链路追踪的接入点，gossr/oteltrace 提供基于 OpenTelemetry 的实现。
+type Tracer interface {
+	// Start 以 ctx 中的 span 为父节点开始一个新 span，返回携带新 span 的 ctx。
+	Start(ctx context.Context, name string) (context.Context, Span)
+	// Extract 从入站请求头（W3C traceparent 等）中恢复上游链路，返回携带远端父节点的 ctx。
+	Extract(ctx context.Context, header http.Header) context.Context
+	// Inject 把 ctx 中的链路信息写入出站请求头。
+	Inject(ctx context.Context, header http.Header)
+}
+
+// Span 是一段进行中的链路区间。
+type Span interface {
+	SetAttribute(key string, value any)
+	RecordError(err error)
+	End()
+}
+
+type tracerContextKey struct{}
+
+// ContextWithTracer 把 Tracer 挂到 ctx 上，引擎内部经 StartSpan 记录取池与 JS 执行阶段。
+func ContextWithTracer(ctx context.Context, tracer Tracer) context.Context {
+	return context.WithValue(ctx, tracerContextKey{}, tracer)
+}
+
+// TracerFromContext 取出 ctx 上的 Tracer，未设置时返回 nil。
+func TracerFromContext(ctx context.Context) Tracer {
+	if ctx == nil {
+		return nil
+	}
+	tracer, _ := ctx.Value(tracerContextKey{}).(Tracer)
+	return tracer
+}
+
+// StartSpan 使用 ctx 上的 Tracer 开始 span，未设置 Tracer 时返回空实现。
+func StartSpan(ctx context.Context, name string) (context.Context, Span) {
+	if tracer := TracerFromContext(ctx); tracer != nil {
+		return tracer.Start(ctx, name)
+	}
+	return ctx, noopSpan{}
+}
+
+// EndSpan 记录 err（非 nil 时）并结束 span。
+func EndSpan(span Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+	}
+	span.End()
+}
+
+type noopSpan struct{}
+
+func (noopSpan) SetAttribute(string, any) {}
+func (noopSpan) RecordError(error)        {}
+func (noopSpan) End()                     {}
@@ -0,0 +1,89 @@
+package gossr
+
+import (
+	"sort"
+	"strings"
+)
+
+// routeTable 按 gin 风格的路由模式（/hi/:name、/docs/*path）查找配置。
+// 多个模式同时匹配时，静态段更多的模式优先，其次是通配更少的模式。
+type routeTable[T any] struct {
+	entries []routeEntry[T]
+}
+
+type routeEntry[T any] struct {
+	pattern  string
+	segments []string
+	static   int
+	value    T
+}
+
+func newRouteTable[T any](routes map[string]T) routeTable[T] {
+	entries := make([]routeEntry[T], 0, len(routes))
+	for pattern, value := range routes {
+		segments := splitRoutePath(pattern)
+		static := 0
+		for _, segment := range segments {
+			if !strings.HasPrefix(segment, ":") && !strings.HasPrefix(segment, "*") {
+				static++
+			}
+		}
+		entries = append(entries, routeEntry[T]{
+			pattern:  pattern,
+			segments: segments,
+			static:   static,
+			value:    value,
+		})
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].static != entries[j].static {
+			return entries[i].static > entries[j].static
+		}
+		if len(entries[i].segments) != len(entries[j].segments) {
+			return len(entries[i].segments) > len(entries[j].segments)
+		}
+		return entries[i].pattern < entries[j].pattern
+	})
+
+	return routeTable[T]{entries: entries}
+}
+
+func (t routeTable[T]) lookup(urlPath string) (T, bool) {
+	segments := splitRoutePath(urlPath)
+	for _, entry := range t.entries {
+		if matchRouteSegments(entry.segments, segments) {
+			return entry.value, true
+		}
+	}
+
+	var zero T
+	return zero, false
+}
+
+func matchRouteSegments(pattern []string, segments []string) bool {
+	for i, part := range pattern {
+		if strings.HasPrefix(part, "*") {
+			return true
+		}
+		if i >= len(segments) {
+			return false
+		}
+		if strings.HasPrefix(part, ":") {
+			continue
+		}
+		if part != segments[i] {
+			return false
+		}
+	}
+
+	return len(pattern) == len(segments)
+}
+
+func splitRoutePath(p string) []string {
+	trimmed := strings.Trim(strings.TrimSpace(p), "/")
+	if trimmed == "" {
+		return nil
+	}
+	return strings.Split(trimmed, "/")
+}
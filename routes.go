@@ -0,0 +1,87 @@
+package gossr
+
+import (
+	"net/http"
+	"time"
+)
+
+// RouteOptions 单个页面路由的渲染配置，见 Route。
+type RouteOptions struct {
+	// RenderTimeout 覆盖 Options.RenderTimeout，<=0 时沿用全局超时。
+	RenderTimeout time.Duration
+	// ClientOnly 为 true 时跳过服务端渲染，直接返回注入了 __SSR_DATA__ 的 index.html 外壳，由客户端渲染。
+	ClientOnly bool
+	// Cache 非 nil 时覆盖页面缓存中该路由的规则；未调用 WithRenderCache 时以默认配置启用页面缓存。
+	Cache *CacheRule
+	// Revalidate 为 WithISR 下页面的重新生成间隔，<=0 表示只在按需重新生成时更新。
+	Revalidate time.Duration
+}
+
+// RouteOption 以函数式方式修改 RouteOptions。
+type RouteOption func(*RouteOptions)
+
+// Route 为匹配 pattern 的页面设置渲染配置，pattern 与 SsrEngine 路由同款（如 /hi/:name、/docs/*path）。
+// 同一 pattern 多次调用时按顺序叠加。
+func Route(pattern string, opts ...RouteOption) Option {
+	return func(o *Options) {
+		routes := make(map[string]RouteOptions, len(o.Routes)+1)
+		for p, ro := range o.Routes {
+			routes[p] = ro
+		}
+
+		ro := routes[pattern]
+		for _, opt := range opts {
+			if opt != nil {
+				opt(&ro)
+			}
+		}
+		routes[pattern] = ro
+		o.Routes = routes
+	}
+}
+
+// RenderTimeout 设置路由的渲染超时，用于给重页面更长的预算。
+func RenderTimeout(timeout time.Duration) RouteOption {
+	return func(ro *RouteOptions) {
+		ro.RenderTimeout = timeout
+	}
+}
+
+// ClientOnly 关闭路由的服务端渲染，适用于后台等无需 SEO 的页面。
+func ClientOnly() RouteOption {
+	return func(ro *RouteOptions) {
+		ro.ClientOnly = true
+	}
+}
+
+// CachePolicy 把路由标记为可缓存，rule 覆盖 CacheOptions.Routes 中的同名规则。
+func CachePolicy(rule CacheRule) RouteOption {
+	return func(ro *RouteOptions) {
+		ro.Cache = &rule
+	}
+}
+
+// routeCacheRules 返回路由表中声明的页面缓存规则。
+func routeCacheRules(routes map[string]RouteOptions) map[string]CacheRule {
+	rules := make(map[string]CacheRule)
+	for pattern, ro := range routes {
+		if ro.Cache != nil {
+			rules[pattern] = *ro.Cache
+		}
+	}
+	return rules
+}
+
+// route 返回请求匹配的路由配置，未匹配时为零值。
+func (h *pageHandler) route(req *http.Request) RouteOptions {
+	ro, _ := h.routes.lookup(req.URL.Path)
+	return ro
+}
+
+// renderTimeout 返回请求适用的渲染超时。
+func (h *pageHandler) renderTimeout(req *http.Request) time.Duration {
+	if timeout := h.route(req).RenderTimeout; timeout > 0 {
+		return timeout
+	}
+	return h.options.RenderTimeout
+}
@@ -0,0 +1,88 @@
+package gossr
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRouteMergesOptions(t *testing.T) {
+	base := newOptions(Route("/admin/*path", ClientOnly()))
+	opts := newOptions(
+		WithOptions(base),
+		Route("/admin/*path", RenderTimeout(time.Second)),
+		Route("/docs/:id", CachePolicy(CacheRule{TTL: time.Minute})),
+	)
+
+	admin := opts.Routes["/admin/*path"]
+	if !admin.ClientOnly || admin.RenderTimeout != time.Second {
+		t.Fatalf("expected merged route options, got %+v", admin)
+	}
+	if docs := opts.Routes["/docs/:id"]; docs.Cache == nil || docs.Cache.TTL != time.Minute {
+		t.Fatalf("unexpected cache policy %+v", docs)
+	}
+	if len(base.Routes) != 1 {
+		t.Fatalf("expected Route not to mutate shared options, got %v", base.Routes)
+	}
+}
+
+func TestRunBlockingRouteOptions(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	RunBlocking(router, FrontendBuild{
+		FrontendDist: testFrontendDistFS(),
+		ServerDist: fstest.MapFS{"server.js": {Data: []byte(`globalThis.renders = 0
+		globalThis.ssrRender = function(url) {
+			globalThis.renders++
+			if (url.indexOf("/slow") === 0) {
+				const until = Date.now() + 100
+				while (Date.now() < until) {}
+			}
+			return "<p>" + url + "#" + globalThis.renders + "</p>"
+		}`)}},
+	}, func(context.Context, *http.Request) (SSRPayload, error) {
+		return mapPayload{"user": "demo"}, nil
+	},
+		WithRenderTimeout(30*time.Millisecond),
+		Route("/slow-ssr", RenderTimeout(2*time.Second)),
+		Route("/admin/*path", ClientOnly()),
+		Route("/cached/:id", CachePolicy(CacheRule{TTL: time.Minute})),
+	)
+
+	slow := performRequest(router, http.MethodGet, "/slow-ssr", nil)
+	if !strings.Contains(slow.Body.String(), "<p>/slow-ssr#") {
+		t.Fatalf("expected route timeout to allow the slow render, got %q", slow.Body.String())
+	}
+	fallback := performRequest(router, http.MethodGet, "/slow-other", nil)
+	if !strings.Contains(fallback.Body.String(), `name="ssr-error-id"`) {
+		t.Fatalf("expected global timeout to fall back, got %q", fallback.Body.String())
+	}
+
+	admin := performRequest(router, http.MethodGet, "/admin/users", nil)
+	body := admin.Body.String()
+	if admin.Code != http.StatusOK || strings.Contains(body, "<p>") || strings.Contains(body, "ssr-error-id") {
+		t.Fatalf("expected client-only shell, got %d %q", admin.Code, body)
+	}
+	if !strings.Contains(body, "window.__SSR_DATA__") || !strings.Contains(body, "demo") {
+		t.Fatalf("expected client-only shell to carry payload, got %q", body)
+	}
+
+	first := performRequest(router, http.MethodGet, "/cached/1", nil)
+	second := performRequest(router, http.MethodGet, "/cached/1", nil)
+	if first.Header().Get(cacheStatusHeader) != cacheStateMiss || second.Header().Get(cacheStatusHeader) != cacheStateHit {
+		t.Fatalf("expected route cache policy MISS then HIT, got %q %q",
+			first.Header().Get(cacheStatusHeader), second.Header().Get(cacheStatusHeader))
+	}
+	if first.Body.String() != second.Body.String() {
+		t.Fatalf("expected cached body to match, got %q vs %q", first.Body.String(), second.Body.String())
+	}
+	if got := performRequest(router, http.MethodGet, "/other", nil).Header().Get(cacheStatusHeader); got != cacheStateBypass {
+		t.Fatalf("expected undeclared routes to bypass the cache, got %q", got)
+	}
+}
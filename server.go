@@ -12,26 +12,32 @@ import (
 	"log"
 	"net/http"
 	"net/http/httputil"
-	"net/http/pprof"
 	"net/url"
-	"os"
 	"path"
 	"regexp"
-	"runtime"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/daodao97/gossr/locales"
 	"github.com/daodao97/gossr/renderer"
-
-	"github.com/gin-gonic/gin"
 )
 
+// FrontendBuild 为一份前端构建产物：FrontendDist 含 index.html 与 assets/，ServerDist 含 SSR 脚本。
 type FrontendBuild struct {
 	FrontendDist fs.FS
 	ServerDist   fs.FS
+	// ScriptName 为 ServerDist 中的 SSR 脚本文件名，为空时使用 server.js。
+	ScriptName string
+}
+
+func (b FrontendBuild) scriptName() string {
+	if b.ScriptName == "" {
+		return renderer.DefaultSSRScriptName
+	}
+	return b.ScriptName
 }
 
 type BackendDataFetcher func(context.Context, *http.Request) (SSRPayload, error)
@@ -42,12 +48,19 @@ type SessionTokenParser func(token string) (map[string]any, error)
 
 const (
 	DefaultSSRDataRoute = "/_ssr/data"
+	appHTMLMarker       = "<!--app-html-->"
 	maxSSRRenderLimit   = 1024
 	cacheNoStoreHTML    = "no-cache, no-store, must-revalidate"
 	cacheImmutableAsset = "public, max-age=31536000, immutable"
 	cacheShortRootFile  = "public, max-age=86400"
 )
 
+var (
+	// errRenderTimeout 与 errRenderPanic 用于区分渲染失败的原因（指标分类），错误文本保持不变。
+	errRenderTimeout = errors.New("render timeout")
+	errRenderPanic   = errors.New("panic")
+)
+
 var (
 	langAttributePattern = regexp.MustCompile(`lang="[^"]*"`)
 	staticAssetExts      = map[string]struct{}{
@@ -78,6 +91,15 @@ var (
 	}
 )
 
+// blockedResultHeaders 不允许 JS 侧通过 __SSR_RESPONSE__ 覆盖的响应头。
+var blockedResultHeaders = map[string]struct{}{
+	"Connection":        {},
+	"Content-Encoding":  {},
+	"Content-Length":    {},
+	"Content-Type":      {},
+	"Transfer-Encoding": {},
+}
+
 var (
 	sessionTokenParserMu sync.RWMutex
 	sessionTokenParser   SessionTokenParser = defaultSessionTokenParser
@@ -102,122 +124,370 @@ func getSessionTokenParser() SessionTokenParser {
 	return sessionTokenParser
 }
 
-func RunBlocking(router *gin.Engine, frontendBuild FrontendBuild, fetcher BackendDataFetcher) {
-	devMode := isDevMode()
-	registerPprof(router)
-	router.GET("/i/:invite_code", func(c *gin.Context) {
-		inviteCode := strings.TrimSpace(c.Param("invite_code"))
-		if inviteCode != "" {
-			c.SetCookie("invite_code", inviteCode, 60*60*24*30, "/", "", false, true)
+// newPageHandler 按配置创建页面流程，调用方需再通过 build.Store 设置构建产物。
+func newPageHandler(options Options, fetcher BackendDataFetcher) *pageHandler {
+	compressor := newHTMLCompressor(options)
+	return &pageHandler{
+		options:   options,
+		renderSem: newRenderSemaphore(options.RenderLimit),
+		fetcher:   fetcher,
+		fetch:     newSSRFetcher(options),
+		cache:     newPageCache(options, compressor),
+		compress:  compressor,
+		httpCache: newHTTPCachePolicy(options),
+		routes:    newRouteTable(options.Routes),
+		isr:       newISRState(options),
+		metrics:   metricsOrNoop(options.Metrics),
+		engine:    engineName(options),
+	}
+}
+
+// pageHandler 承接 NoRoute 的 SSR 页面流程：取数据 -> 渲染 -> 注入。
+type pageHandler struct {
+	options Options
+	// build 为当前生效的构建产物，WatchDir 模式下检测到变化时整体替换。
+	build     atomic.Pointer[pageBuild]
+	renderSem chan struct{}
+	fetcher   BackendDataFetcher
+	fetch     *ssrFetcher
+	cache     *pageCache
+	compress  *htmlCompressor
+	httpCache *httpCachePolicy
+	routes    routeTable[RouteOptions]
+	isr       *isrState
+	metrics   Metrics
+	engine    string
+	// initialBuild 为启动时的构建版本，未记录构建版本的预渲染页面只在该构建生效期间返回。
+	initialBuild string
+}
+
+// pageResponse 是一次页面渲染的完整输出，可直接写回或进入页面缓存。
+type pageResponse struct {
+	status   int
+	header   http.Header
+	body     string
+	redirect string
+	// cacheable 仅成功渲染、无重定向且未设置 Cookie 的页面可缓存。
+	cacheable bool
+	// encoded 为页面缓存中预先压缩好的 body，key 为 Content-Encoding。
+	encoded map[string][]byte
+	// etag 为基于 payload 版本字段的 ETag，为空时按最终 HTML 计算。
+	etag string
+}
+
+func (h *pageHandler) handle(w http.ResponseWriter, r *http.Request) {
+	if strings.HasPrefix(r.URL.Path, DefaultSSRDataRoute) || strings.HasPrefix(r.URL.Path, DefaultSSRFragmentRoute) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if isStaticAssetLikePath(r.URL.Path) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	w, r, endSpan := h.startPageSpan(w, r)
+	defer endSpan()
+
+	h.build.Load().hints.apply(w, r)
+
+	if resp, state, ok := h.storedPage(r); ok {
+		w.Header().Set(cacheStatusHeader, state)
+		h.writePage(w, r, resp)
+		return
+	}
+	if resp, ok := h.prerenderedPage(r); ok {
+		h.writePage(w, r, resp)
+		return
+	}
+
+	if h.canStream(r) {
+		h.stream(w, r)
+		return
+	}
+
+	if h.cache != nil {
+		resp, state := h.cache.serve(r, h.render)
+		w.Header().Set(cacheStatusHeader, state)
+		h.writePage(w, r, resp)
+		return
+	}
+
+	h.writePage(w, r, h.render(r))
+}
+
+// startPageSpan 在配置了 Tracer 时为请求开启 gossr.page span，返回包装后的 w/r 与结束 span 的函数。
+func (h *pageHandler) startPageSpan(w http.ResponseWriter, r *http.Request) (http.ResponseWriter, *http.Request, func()) {
+	tracer := h.options.Tracer
+	if tracer == nil {
+		return w, r, func() {}
+	}
+
+	ctx := renderer.ContextWithTracer(tracer.Extract(r.Context(), r.Header), tracer)
+	ctx, span := tracer.Start(ctx, renderer.SpanPage)
+	span.SetAttribute("http.request.method", r.Method)
+	span.SetAttribute("url.path", r.URL.Path)
+	sw := &statusWriter{ResponseWriter: w}
+	return sw, r.WithContext(ctx), func() {
+		span.SetAttribute("http.response.status_code", sw.statusCode())
+		span.End()
+	}
+}
+
+func (h *pageHandler) render(req *http.Request) pageResponse {
+	b := h.acquireBuild()
+	defer b.release()
+
+	reqID := newRequestID()
+	payloadMap, err := h.loadPayload(req)
+	if err != nil {
+		log.Println(err)
+		return pageResponse{status: http.StatusInternalServerError}
+	}
+
+	locale := localeFromPath(req.URL.Path)
+
+	// payload 声明了版本且与 If-None-Match 一致时无需渲染；页面缓存会合并不同请求的结果，因此只在直接渲染时短路。
+	rule, ruleOK := h.httpCache.rule(req)
+	versionTag := h.httpCache.versionETag(b.version, req, rule, requestOrigin(req, h.options.TrustForwardedHeaders), payloadMap)
+	if ruleOK && rule.ETag && versionTag != "" && (h.cache == nil || !h.cache.accepts(req)) {
+		if etag, matched := matchETagVariants(req.Header.Get("If-None-Match"), versionTag); matched {
+			return pageResponse{status: http.StatusNotModified, etag: etag}
 		}
-		c.Redirect(http.StatusFound, "/")
-	})
+	}
 
-	var (
-		indexHTML string
-		ssr       renderer.Renderer
-		proxy     *httputil.ReverseProxy
-		renderSem chan struct{}
-	)
-
-	if devMode {
-		proxy = newDevProxy(devServerURL())
-		log.Printf("Development mode enabled. Proxying to %s", devServerURL())
-		router.NoRoute(func(c *gin.Context) {
-			if strings.HasPrefix(c.Request.URL.Path, DefaultSSRDataRoute) {
-				c.Status(http.StatusNotFound)
-				return
-			}
+	// ClientOnly 路由与渲染失败时一样返回外壳页面，但属于正常响应，可缓存且不带 ssr-error-id。
+	if h.route(req).ClientOnly {
+		return pageResponse{
+			status:    http.StatusOK,
+			body:      buildFallbackPage(b.indexHTML, payloadMap, locale, ""),
+			cacheable: true,
+			etag:      versionTag,
+		}
+	}
 
-			proxy.ServeHTTP(c.Writer, c.Request)
-		})
-	} else {
-		indexBytes, err := readFSFile(frontendBuild.FrontendDist, "index.html")
-		if err != nil {
-			panic(fmt.Errorf("failed to read index.html: %w", err))
+	urlPath := req.URL.RequestURI()
+	renderCtx := h.renderContext(req, reqID)
+
+	// 带命名出口的页面先占用一个并发名额，主渲染与全部 island 共用，避免 island 排在其他页面之后超时降级。
+	// 命名出口与主渲染并行；提前返回时先取消再等待，保证释放名额与构建前 island 都已结束。
+	renderSem := h.renderSem
+	var waitIslands func() []islandResult
+	if len(b.islands) > 0 {
+		var release func()
+		if release, err = h.reserveRenderSlot(renderCtx, h.renderTimeout(req)); err == nil {
+			defer release()
+			renderSem = nil
+			islandCtx, cancelIslands := context.WithCancel(renderCtx)
+			waitIslands = h.renderIslands(islandCtx, req, b, urlPath, payloadMap)
+			defer waitIslands()
+			defer cancelIslands()
 		}
-		indexHTML = string(indexBytes)
+	}
 
-		serverEntry, err := readFSFile(frontendBuild.ServerDist, "server.js")
-		if err != nil {
-			panic(fmt.Errorf("failed to read server.js: %w", err))
+	// 只有命名出口、没有 <!--app-html--> 的页面不执行 ssrRender。
+	var result renderer.Result
+	if err == nil && (len(b.islands) == 0 || strings.Contains(b.indexHTML, appHTMLMarker)) {
+		result, err = h.runRenderWithSlot(renderCtx, h.renderTimeout(req), renderSem, func(ctx context.Context) (renderer.Result, error) {
+			return b.ssr.Render(ctx, urlPath, payloadMap)
+		})
+	}
+	if err != nil {
+		log.Printf("ssr render failed id=%s path=%s err=%v", reqID, req.URL.Path, err)
+		h.metrics.IncFallback(fallbackReasonRender)
+
+		return pageResponse{
+			status: http.StatusOK,
+			body:   buildFallbackPage(b.indexHTML, payloadMap, locale, reqID),
 		}
-		ssr = newRendererFromEnv(string(serverEntry))
-		prewarmRenderer(ssr)
+	}
 
-		renderLimit := renderConcurrencyLimit()
-		if renderLimit > 0 {
-			renderSem = make(chan struct{}, renderLimit)
+	if result.Redirect != "" {
+		return pageResponse{
+			status:   redirectStatus(result.Status),
+			header:   result.Headers,
+			redirect: result.Redirect,
 		}
+	}
+
+	_, injectSpan := renderer.StartSpan(req.Context(), renderer.SpanInject)
+	page, head, modules, islandsOK := b.indexHTML, result.Head, result.Modules, true
+	if waitIslands != nil {
+		var islandHead string
+		var islandModules []string
+		page, islandHead, islandModules, islandsOK = h.composeIslands(page, waitIslands(), reqID, req.URL.Path)
+		head += islandHead
+		modules = append(append([]string(nil), modules...), islandModules...)
+	}
+	page = strings.Replace(page, appHTMLMarker, result.HTML, 1)
+	if locale != "" {
+		page = applyHTMLLang(page, locale)
+	}
+	page = injectHeadContent(page, b.manifest.preloadLinks(modules, b.indexHTML)+head)
+	page, injectErr := injectSSRData(page, payloadMap)
+	if injectErr != nil {
+		log.Println(injectErr)
+	}
+	renderer.EndSpan(injectSpan, injectErr)
+
+	status := pageStatus(result.Status)
+	return pageResponse{
+		status:    status,
+		header:    result.Headers,
+		body:      page,
+		cacheable: status == http.StatusOK && islandsOK && len(result.Headers.Values("Set-Cookie")) == 0,
+		etag:      versionTag,
+	}
+}
+
+// runRender 在超时与并发名额限制下执行渲染，并记录耗时与结果指标。
+func (h *pageHandler) runRender(ctx context.Context, timeout time.Duration, render func(context.Context) (renderer.Result, error)) (renderer.Result, error) {
+	return h.runRenderWithSlot(ctx, timeout, h.renderSem, render)
+}
+
+// runRenderWithSlot 与 runRender 相同，但从 sem 获取并发名额；sem 为 nil 表示调用方已通过 reserveRenderSlot 占用名额。
+func (h *pageHandler) runRenderWithSlot(ctx context.Context, timeout time.Duration, sem chan struct{}, render func(context.Context) (renderer.Result, error)) (renderer.Result, error) {
+	start := time.Now()
+	result, err := runWithRenderSlot(ctx, timeout, sem, h.metrics, render)
+	if !errors.Is(err, renderer.ErrStreamingUnsupported) {
+		h.metrics.ObserveRender(h.engine, renderResult(err), time.Since(start))
+	}
+	return result, err
+}
 
-		assetsFS, err := fs.Sub(frontendBuild.FrontendDist, "assets")
+// renderContext 把请求描述（含请求 ID）与 fetch() 能力挂到渲染 ctx 上。
+func (h *pageHandler) renderContext(req *http.Request, reqID string) context.Context {
+	renderReq := newRenderRequest(req, h.options)
+	if renderReq != nil {
+		renderReq.ID = reqID
+	}
+	ctx := renderer.ContextWithRequest(req.Context(), renderReq)
+	return renderer.ContextWithFetch(ctx, h.fetch.forRequest(req))
+}
+
+// newRequestID 生成用于日志与 ssr-error-id 的请求标识。
+func newRequestID() string {
+	return strconv.FormatInt(time.Now().UnixNano(), 10)
+}
+
+// loadPayload 调用 BackendDataFetcher 并补充 session/locale/siteOrigin。
+func (h *pageHandler) loadPayload(req *http.Request) (map[string]any, error) {
+	var payload SSRPayload
+	if h.fetcher != nil {
+		ctx, span := renderer.StartSpan(req.Context(), renderer.SpanDataFetch)
+		var err error
+		payload, err = h.fetcher(ctx, req)
+		renderer.EndSpan(span, err)
 		if err != nil {
-			panic(fmt.Errorf("failed to prepare assets filesystem: %w", err))
+			return nil, err
 		}
+	}
 
-		// /assets 目录使用长期缓存（文件名带 hash）
-		router.Group("/assets", cacheControlMiddleware(cacheImmutableAsset)).
-			StaticFS("/", http.FS(assetsFS))
+	return enrichPayloadFromRequest(payloadToMap(payload), req, h.options.TrustForwardedHeaders), nil
+}
 
-		// 根目录静态文件使用短期缓存
-		registerRootStaticFiles(router, frontendBuild.FrontendDist)
+// writePage 写回页面响应：按路由应用 Cache-Control/ETag，处理条件请求，并按 Accept-Encoding 压缩。
+func (h *pageHandler) writePage(w http.ResponseWriter, r *http.Request, resp pageResponse) {
+	header := w.Header()
+	rule, ruleOK := h.httpCache.rule(r)
+	if resp.status == http.StatusNotModified {
+		applyPageCacheControl(header, rule)
+		header.Set("ETag", resp.etag)
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
 
-		router.NoRoute(func(c *gin.Context) {
-			if strings.HasPrefix(c.Request.URL.Path, DefaultSSRDataRoute) {
-				c.Status(http.StatusNotFound)
-				return
-			}
-			if isStaticAssetLikePath(c.Request.URL.Path) {
-				c.Status(http.StatusNotFound)
-				return
-			}
+	if resp.body == "" && resp.redirect == "" {
+		w.WriteHeader(resp.status)
+		return
+	}
 
-			var (
-				payload    SSRPayload
-				payloadMap map[string]any
-				err        error
-			)
-
-			if fetcher != nil {
-				payload, err = fetcher(c.Request.Context(), c.Request)
-				if err != nil {
-					log.Println(err)
-					c.Status(http.StatusInternalServerError)
-					return
-				}
-			}
+	applyResultHeaders(header, resp.header)
+	cacheable := ruleOK && resp.cacheable && resp.redirect == ""
+	if cacheable {
+		applyPageCacheControl(header, rule)
+	} else {
+		setHTMLNoCacheHeaders(header)
+	}
+	if resp.redirect != "" {
+		http.Redirect(w, r, withBasePath(h.options.BasePath, resp.redirect), resp.status)
+		return
+	}
 
-			payloadMap = enrichPayloadFromRequest(payloadToMap(payload), c.Request)
+	header.Set("Content-Type", "text/html")
+	enc := ""
+	if h.compress.eligible(resp.body) {
+		header.Add("Vary", "Accept-Encoding")
+		enc = h.compress.negotiate(r.Header.Get("Accept-Encoding"))
+	}
 
-			locale := localeFromPath(c.Request.URL.Path)
+	if cacheable && rule.ETag {
+		etag := resp.etag
+		if etag == "" {
+			etag = strongETag(resp.body)
+		}
+		etag = encodedETag(etag, enc)
+		header.Set("ETag", etag)
+		if etagMatches(r.Header.Get("If-None-Match"), etag) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
 
-			reqID := fmt.Sprintf("%d", time.Now().UnixNano())
+	body := []byte(resp.body)
+	if enc != "" {
+		data, ok := resp.encoded[enc]
+		if !ok {
+			var err error
+			if data, err = h.compress.encode(enc, body); err != nil {
+				log.Printf("compress html with %s failed: %v", enc, err)
+			}
+		}
+		if data != nil {
+			header.Set("Content-Encoding", enc)
+			body = data
+		}
+	}
+	w.WriteHeader(resp.status)
+	_, _ = w.Write(body)
+}
 
-			result, err := renderWithTimeout(c.Request.Context(), ssr, c.Request.URL.Path, payloadMap, 3*time.Second, renderSem)
-			if err != nil {
-				log.Printf("ssr render failed id=%s path=%s err=%v", reqID, c.Request.URL.Path, err)
+// applyPageCacheControl 写入路由声明的 Cache-Control 与 Vary，未声明 Cache-Control 时保持 no-cache。
+func applyPageCacheControl(header http.Header, rule HTTPCacheRule) {
+	for _, name := range rule.Vary {
+		header.Add("Vary", http.CanonicalHeaderKey(name))
+	}
+	if rule.CacheControl == "" {
+		setHTMLNoCacheHeaders(header)
+		return
+	}
+	header.Set("Cache-Control", rule.CacheControl)
+}
 
-				fallback := buildFallbackPage(indexHTML, payloadMap, locale, reqID)
-				setHTMLNoCacheHeaders(c)
-				c.Header("Content-Type", "text/html")
-				c.String(http.StatusOK, fallback)
-				return
-			}
+// pageStatus 返回渲染结果声明的状态码，未声明时为 200。
+func pageStatus(status int) int {
+	if status == 0 {
+		return http.StatusOK
+	}
+	return status
+}
 
-			page := strings.Replace(indexHTML, "<!--app-html-->", result.HTML, 1)
-			if locale != "" {
-				page = applyHTMLLang(page, locale)
-			}
-			page = injectHeadContent(page, result.Head)
-			page, injectErr := injectSSRData(page, payloadMap)
-			if injectErr != nil {
-				log.Println(injectErr)
-			}
+// redirectStatus 返回渲染结果声明的重定向状态码，非 3xx 时使用 302。
+func redirectStatus(status int) int {
+	if status >= http.StatusMultipleChoices && status <= http.StatusPermanentRedirect {
+		return status
+	}
+	return http.StatusFound
+}
 
-			setHTMLNoCacheHeaders(c)
-			c.Header("Content-Type", "text/html")
-			c.String(http.StatusOK, page)
-		})
+// applyResultHeaders 写入 JS 侧声明的响应头；传输相关头部由 Go 侧控制，忽略覆盖。
+func applyResultHeaders(header http.Header, headers http.Header) {
+	for name, values := range headers {
+		if _, blocked := blockedResultHeaders[http.CanonicalHeaderKey(name)]; blocked {
+			continue
+		}
+		for _, value := range values {
+			header.Add(name, value)
+		}
 	}
 }
 
@@ -292,15 +562,15 @@ func payloadToMap(payload SSRPayload) map[string]any {
 	return map[string]any{}
 }
 
-func enrichPayloadForSSRFetchResponse(payload map[string]any, req *http.Request) map[string]any {
-	return enrichPayloadWithRequestContext(payload, req, false)
+func enrichPayloadForSSRFetchResponse(payload map[string]any, req *http.Request, trustForwarded bool) map[string]any {
+	return enrichPayloadWithRequestContext(payload, req, false, trustForwarded)
 }
 
-func enrichPayloadFromRequest(payload map[string]any, req *http.Request) map[string]any {
-	return enrichPayloadWithRequestContext(payload, req, true)
+func enrichPayloadFromRequest(payload map[string]any, req *http.Request, trustForwarded bool) map[string]any {
+	return enrichPayloadWithRequestContext(payload, req, true, trustForwarded)
 }
 
-func enrichPayloadWithRequestContext(payload map[string]any, req *http.Request, includeSession bool) map[string]any {
+func enrichPayloadWithRequestContext(payload map[string]any, req *http.Request, includeSession bool, trustForwarded bool) map[string]any {
 	enriched := make(map[string]any, len(payload)+3)
 	for k, v := range payload {
 		enriched[k] = v
@@ -320,7 +590,7 @@ func enrichPayloadWithRequestContext(payload map[string]any, req *http.Request,
 		enriched["locale"] = locale
 	}
 
-	if origin := requestOrigin(req); origin != "" {
+	if origin := requestOrigin(req, trustForwarded); origin != "" {
 		enriched["siteOrigin"] = origin
 	}
 
@@ -419,8 +689,8 @@ func localeFromPath(p string) string {
 	return locales.Default
 }
 
-func requestOrigin(r *http.Request) string {
-	host := primaryHost(r)
+func requestOrigin(r *http.Request, trustForwarded bool) string {
+	host := primaryHost(r, trustForwarded)
 	if host == "" {
 		return ""
 	}
@@ -430,7 +700,7 @@ func requestOrigin(r *http.Request) string {
 		scheme = "https"
 	}
 
-	if trustForwardedHeaders() {
+	if trustForwarded {
 		if proto := firstForwardedValue(r.Header.Get("X-Forwarded-Proto")); proto != "" {
 			scheme = proto
 		}
@@ -445,13 +715,13 @@ func requestOrigin(r *http.Request) string {
 	return fmt.Sprintf("%s://%s", scheme, host)
 }
 
-func primaryHost(r *http.Request) string {
+func primaryHost(r *http.Request, trustForwarded bool) string {
 	if r == nil {
 		return ""
 	}
 
 	host := strings.TrimSpace(r.Host)
-	if trustForwardedHeaders() {
+	if trustForwarded {
 		if forwardedHost := firstForwardedValue(r.Header.Get("X-Forwarded-Host")); forwardedHost != "" {
 			host = forwardedHost
 		}
@@ -481,32 +751,6 @@ func hostHasExplicitPort(host string) bool {
 	return strings.Count(host, ":") == 1
 }
 
-func isDevMode() bool {
-	switch strings.ToLower(strings.TrimSpace(os.Getenv("DEV_MODE"))) {
-	case "1", "true", "yes", "on", "dev":
-		return true
-	default:
-		return false
-	}
-}
-
-func trustForwardedHeaders() bool {
-	switch strings.ToLower(strings.TrimSpace(os.Getenv("TRUST_FORWARDED_HEADERS"))) {
-	case "1", "true", "yes", "on":
-		return true
-	default:
-		return false
-	}
-}
-
-func devServerURL() string {
-	if raw := strings.TrimSpace(os.Getenv("DEV_SERVER_URL")); raw != "" {
-		return raw
-	}
-
-	return "http://127.0.0.1:3333"
-}
-
 func newDevProxy(rawURL string) *httputil.ReverseProxy {
 	parsed, err := url.Parse(rawURL)
 	if err != nil {
@@ -522,11 +766,19 @@ func newDevProxy(rawURL string) *httputil.ReverseProxy {
 	return proxy
 }
 
-func renderWithTimeout(parentCtx context.Context, ssr renderer.Renderer, urlPath string, payload map[string]any, timeout time.Duration, sem chan struct{}) (result renderer.Result, err error) {
+func renderWithTimeout(parentCtx context.Context, ssr renderer.Renderer, urlPath string, payload map[string]any, timeout time.Duration, sem chan struct{}) (renderer.Result, error) {
+	return runWithRenderSlot(parentCtx, timeout, sem, nil, func(ctx context.Context) (renderer.Result, error) {
+		return ssr.Render(ctx, urlPath, payload)
+	})
+}
+
+// runWithRenderSlot 在超时与并发名额限制下执行一次渲染，并把 panic 转为错误。
+// metrics 非 nil 时记录排队深度与等待名额的耗时。
+func runWithRenderSlot(parentCtx context.Context, timeout time.Duration, sem chan struct{}, metrics Metrics, render func(context.Context) (renderer.Result, error)) (result renderer.Result, err error) {
 	defer func() {
 		if r := recover(); r != nil {
 			result = renderer.Result{}
-			err = fmt.Errorf("panic: %v", r)
+			err = fmt.Errorf("%w: %v", errRenderPanic, r)
 		}
 	}()
 
@@ -535,55 +787,96 @@ func renderWithTimeout(parentCtx context.Context, ssr renderer.Renderer, urlPath
 	}
 
 	if timeout <= 0 {
-		timeout = 3 * time.Second
+		timeout = defaultRenderTimeout
 	}
 
 	ctx, cancel := context.WithTimeout(parentCtx, timeout)
 	defer cancel()
 
 	if sem != nil {
-		select {
-		case sem <- struct{}{}:
-			defer func() { <-sem }()
-		case <-ctx.Done():
-			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
-				return renderer.Result{}, fmt.Errorf("render timeout after %s", timeout)
+		_, queueSpan := renderer.StartSpan(ctx, renderer.SpanRenderQueue)
+		err := acquireRenderSlot(ctx, sem, metrics)
+		renderer.EndSpan(queueSpan, err)
+		if err != nil {
+			if errors.Is(err, context.DeadlineExceeded) {
+				return renderer.Result{}, fmt.Errorf("%w after %s", errRenderTimeout, timeout)
 			}
-			return renderer.Result{}, ctx.Err()
+			return renderer.Result{}, err
 		}
+		defer func() { <-sem }()
 	}
 
-	result, err = ssr.Render(ctx, urlPath, payload)
+	result, err = render(ctx)
 	if errors.Is(err, context.DeadlineExceeded) || errors.Is(ctx.Err(), context.DeadlineExceeded) {
-		return renderer.Result{}, fmt.Errorf("render timeout after %s", timeout)
+		return renderer.Result{}, fmt.Errorf("%w after %s", errRenderTimeout, timeout)
 	}
 	return result, err
 }
 
-func renderConcurrencyLimit() int {
-	defaultLimit := runtime.GOMAXPROCS(0)
+// reserveRenderSlot 为一次页面渲染预先占用一个并发名额，等待超过 timeout 时返回 errRenderTimeout；
+// 未限制并发时直接返回。成功时调用方结束后必须调用 release。
+func (h *pageHandler) reserveRenderSlot(ctx context.Context, timeout time.Duration) (release func(), err error) {
+	if h.renderSem == nil {
+		return func() {}, nil
+	}
+	if timeout <= 0 {
+		timeout = defaultRenderTimeout
+	}
 
-	if raw := strings.TrimSpace(os.Getenv("SSR_RENDER_LIMIT")); raw != "" {
-		v, err := strconv.Atoi(raw)
-		if err != nil || v < 0 {
-			log.Printf("config: invalid SSR_RENDER_LIMIT=%q, fallback to %d", raw, defaultLimit)
-			return defaultLimit
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	_, queueSpan := renderer.StartSpan(ctx, renderer.SpanRenderQueue)
+	err = acquireRenderSlot(ctx, h.renderSem, h.metrics)
+	renderer.EndSpan(queueSpan, err)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			err = fmt.Errorf("%w after %s", errRenderTimeout, timeout)
 		}
+		// 没等到名额的页面同样记为一次失败的渲染。
+		h.metrics.ObserveRender(h.engine, renderResult(err), time.Since(start))
+		return nil, err
+	}
+	return func() { <-h.renderSem }, nil
+}
 
-		if v == 0 {
-			log.Printf("config: SSR_RENDER_LIMIT=0 (unlimited)")
-			return 0
+// acquireRenderSlot 占用一个并发名额，需要排队时计入队列深度与等待耗时。
+func acquireRenderSlot(ctx context.Context, sem chan struct{}, metrics Metrics) error {
+	select {
+	case sem <- struct{}{}:
+		if metrics != nil {
+			metrics.ObserveRenderQueueWait(0)
 		}
+		return nil
+	default:
+	}
 
-		if v > maxSSRRenderLimit {
-			log.Printf("config: SSR_RENDER_LIMIT=%d exceeds max %d, clamped", v, maxSSRRenderLimit)
-			return maxSSRRenderLimit
-		}
+	if metrics != nil {
+		start := time.Now()
+		metrics.AddRenderQueueDepth(1)
+		defer func() {
+			metrics.AddRenderQueueDepth(-1)
+			metrics.ObserveRenderQueueWait(time.Since(start))
+		}()
+	}
 
-		return v
+	select {
+	case sem <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
 	}
+}
 
-	return defaultLimit
+func newRenderSemaphore(limit int) chan struct{} {
+	if limit <= 0 {
+		return nil
+	}
+	if limit > maxSSRRenderLimit {
+		log.Printf("config: render limit %d exceeds max %d, clamped", limit, maxSSRRenderLimit)
+		limit = maxSSRRenderLimit
+	}
+	return make(chan struct{}, limit)
 }
 
 func prewarmRenderer(ssr renderer.Renderer) {
@@ -592,64 +885,7 @@ func prewarmRenderer(ssr renderer.Renderer) {
 	}()
 }
 
-// newRendererFromEnv 在 ssr_v8.go 和 ssr_nov8.go 中定义
-
-func registerPprof(router *gin.Engine) {
-	if !isPprofEnabled() {
-		return
-	}
-	log.Printf("pprof enabled at /debug/pprof")
-	group := router.Group("/debug/pprof")
-	group.GET("/", gin.WrapF(pprof.Index))
-	group.GET("/cmdline", gin.WrapF(pprof.Cmdline))
-	group.GET("/profile", gin.WrapF(pprof.Profile))
-	group.POST("/symbol", gin.WrapF(pprof.Symbol))
-	group.GET("/symbol", gin.WrapF(pprof.Symbol))
-	group.GET("/trace", gin.WrapF(pprof.Trace))
-	group.GET("/allocs", gin.WrapH(pprof.Handler("allocs")))
-	group.GET("/block", gin.WrapH(pprof.Handler("block")))
-	group.GET("/goroutine", gin.WrapH(pprof.Handler("goroutine")))
-	group.GET("/heap", gin.WrapH(pprof.Handler("heap")))
-	group.GET("/mutex", gin.WrapH(pprof.Handler("mutex")))
-	group.GET("/threadcreate", gin.WrapH(pprof.Handler("threadcreate")))
-}
-
-func isPprofEnabled() bool {
-	if raw := strings.ToLower(strings.TrimSpace(os.Getenv("ENABLE_PPROF"))); raw != "" {
-		switch raw {
-		case "1", "true", "yes", "on":
-			return true
-		default:
-			return false
-		}
-	}
-	return isDevMode()
-}
-
-func registerRootStaticFiles(router *gin.Engine, frontendDist fs.FS) {
-	entries, err := fs.ReadDir(frontendDist, ".")
-	if err != nil {
-		log.Printf("failed to read frontend dist root: %v", err)
-		return
-	}
-
-	for _, entry := range entries {
-		if entry.IsDir() {
-			continue
-		}
-		name := entry.Name()
-		if name == "index.html" {
-			continue
-		}
-		// 根目录文件（favicon, logo 等）使用短期缓存
-		router.GET("/"+name, func(fileName string) gin.HandlerFunc {
-			return func(c *gin.Context) {
-				c.Header("Cache-Control", cacheShortRootFile)
-				c.FileFromFS(fileName, http.FS(frontendDist))
-			}
-		}(name))
-	}
-}
+// newRenderer 在 ssr_v8.go 和 ssr_nov8.go 中定义
 
 func isStaticAssetLikePath(rawPath string) bool {
 	trimmed := strings.TrimSpace(rawPath)
@@ -671,21 +907,14 @@ func isStaticAssetLikePath(rawPath string) bool {
 	return ok
 }
 
-func cacheControlMiddleware(value string) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		c.Header("Cache-Control", value)
-		c.Next()
-	}
-}
-
-func setHTMLNoCacheHeaders(c *gin.Context) {
-	c.Header("Cache-Control", cacheNoStoreHTML)
-	c.Header("Pragma", "no-cache")
-	c.Header("Expires", "0")
+func setHTMLNoCacheHeaders(header http.Header) {
+	header.Set("Cache-Control", cacheNoStoreHTML)
+	header.Set("Pragma", "no-cache")
+	header.Set("Expires", "0")
 }
 
 func buildFallbackPage(indexHTML string, payload map[string]any, locale string, reqID string) string {
-	page := strings.Replace(indexHTML, "<!--app-html-->", "", 1)
+	page := strings.Replace(indexHTML, appHTMLMarker, "", 1)
 	if locale != "" {
 		page = applyHTMLLang(page, locale)
 	}
@@ -702,3 +931,40 @@ func buildFallbackPage(indexHTML string, payload map[string]any, locale string,
 
 	return page
 }
+
+// statusWriter 记录写出的状态码（供链路追踪使用），并保留 Flush 与 Unwrap 能力。
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	if w.status == 0 && code >= http.StatusOK {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *statusWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *statusWriter) statusCode() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
@@ -60,12 +60,18 @@ func performRequest(handler http.Handler, method, target string, setup func(*htt
 	return w
 }
 
+// registerSSRFetchRoutes 在 gin 路由上挂载带访问校验的 /_ssr/data，并返回对应的 fetcher。
+func registerSSRFetchRoutes(router *gin.Engine, options Options) BackendDataFetcher {
+	router.GET(DefaultSSRDataRoute+"/*path", gin.WrapH(newDataHandler(options)))
+	return dataFetcher(options)
+}
+
 func withTestSSREngine(t *testing.T, register func(*gin.Engine)) {
 	t.Helper()
 
 	oldEngine := SsrEngine
 	engine := gin.New()
-	engine.Use(gin.Recovery())
+	engine.Use(ssrDataMetrics(), gin.Recovery())
 	if register != nil {
 		register(engine)
 	}
@@ -184,12 +190,12 @@ func TestRequestOrigin(t *testing.T) {
 	t.Run("host and tls fallback", func(t *testing.T) {
 		req := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
 		req.Host = "example.com"
-		if got := requestOrigin(req); got != "http://example.com" {
+		if got := requestOrigin(req, false); got != "http://example.com" {
 			t.Fatalf("requestOrigin()=%q, want %q", got, "http://example.com")
 		}
 
 		req.TLS = &tls.ConnectionState{}
-		if got := requestOrigin(req); got != "https://example.com" {
+		if got := requestOrigin(req, false); got != "https://example.com" {
 			t.Fatalf("requestOrigin()=%q, want %q", got, "https://example.com")
 		}
 	})
@@ -201,50 +207,44 @@ func TestRequestOrigin(t *testing.T) {
 		req.Header.Set("X-Forwarded-Proto", "https")
 		req.Header.Set("X-Forwarded-Port", "443")
 
-		if got := requestOrigin(req); got != "http://10.0.0.12:8080" {
+		if got := requestOrigin(req, false); got != "http://10.0.0.12:8080" {
 			t.Fatalf("requestOrigin()=%q, want %q", got, "http://10.0.0.12:8080")
 		}
 	})
 
 	t.Run("forwarded host proto and port", func(t *testing.T) {
-		t.Setenv("TRUST_FORWARDED_HEADERS", "1")
-
 		req := httptest.NewRequest(http.MethodGet, "http://127.0.0.1/", nil)
 		req.Host = "10.0.0.12:8080"
 		req.Header.Set("X-Forwarded-Host", "app.example.com, proxy.internal")
 		req.Header.Set("X-Forwarded-Proto", "https,http")
 		req.Header.Set("X-Forwarded-Port", "443,80")
 
-		if got := requestOrigin(req); got != "https://app.example.com:443" {
+		if got := requestOrigin(req, true); got != "https://app.example.com:443" {
 			t.Fatalf("requestOrigin()=%q, want %q", got, "https://app.example.com:443")
 		}
 	})
 
 	t.Run("host already has explicit port", func(t *testing.T) {
-		t.Setenv("TRUST_FORWARDED_HEADERS", "1")
-
 		req := httptest.NewRequest(http.MethodGet, "http://127.0.0.1/", nil)
 		req.Host = "10.0.0.12:8080"
 		req.Header.Set("X-Forwarded-Host", "app.example.com:8443")
 		req.Header.Set("X-Forwarded-Proto", "https")
 		req.Header.Set("X-Forwarded-Port", "443")
 
-		if got := requestOrigin(req); got != "https://app.example.com:8443" {
+		if got := requestOrigin(req, true); got != "https://app.example.com:8443" {
 			t.Fatalf("requestOrigin()=%q, want %q", got, "https://app.example.com:8443")
 		}
 	})
 }
 
 func TestEnrichPayloadFromRequestWithForwardedOrigin(t *testing.T) {
-	t.Setenv("TRUST_FORWARDED_HEADERS", "1")
-
 	req := httptest.NewRequest(http.MethodGet, "/zh/demo", nil)
 	req.Host = "10.0.0.12:8080"
 	req.Header.Set("X-Forwarded-Host", "app.example.com")
 	req.Header.Set("X-Forwarded-Proto", "https")
 	req.Header.Set("X-Forwarded-Port", "443")
 
-	enriched := enrichPayloadFromRequest(map[string]any{"foo": "bar"}, req)
+	enriched := enrichPayloadFromRequest(map[string]any{"foo": "bar"}, req, true)
 	if got, _ := enriched["foo"].(string); got != "bar" {
 		t.Fatalf("expected foo field to be preserved, got %#v", enriched["foo"])
 	}
@@ -316,7 +316,7 @@ func TestEnrichPayloadFromRequestInjectsSessionForSSRRender(t *testing.T) {
 	})
 	addSessionTokenCookie(req, sessionToken)
 
-	enriched := enrichPayloadFromRequest(map[string]any{"foo": "bar"}, req)
+	enriched := enrichPayloadFromRequest(map[string]any{"foo": "bar"}, req, false)
 	session, ok := enriched["session"].(map[string]any)
 	if !ok {
 		t.Fatalf("expected session object in enriched payload, got %#v", enriched["session"])
@@ -334,7 +334,6 @@ func TestEnrichPayloadFromRequestInjectsSessionForSSRRender(t *testing.T) {
 
 func TestSSRFetchGuardWithoutToken(t *testing.T) {
 	gin.SetMode(gin.TestMode)
-	t.Setenv("SSR_FETCH_TOKEN", "")
 
 	withTestSSREngine(t, func(engine *gin.Engine) {
 		engine.GET("/guard-demo", func(c *gin.Context) {
@@ -343,7 +342,7 @@ func TestSSRFetchGuardWithoutToken(t *testing.T) {
 	})
 
 	router := gin.New()
-	registerSSRFetchRoutes(router)
+	registerSSRFetchRoutes(router, newOptions())
 
 	t.Run("missing origin and missing header", func(t *testing.T) {
 		w := performRequest(router, http.MethodGet, DefaultSSRDataRoute+"/guard-demo", func(req *http.Request) {
@@ -375,7 +374,8 @@ func TestSSRFetchGuardWithoutToken(t *testing.T) {
 	})
 
 	t.Run("allow explicit header only when unsafe bypass enabled", func(t *testing.T) {
-		t.Setenv("SSR_ALLOW_UNSAFE_FETCH_HEADER", "1")
+		router := gin.New()
+		registerSSRFetchRoutes(router, newOptions(WithUnsafeFetchHeaderBypass(true)))
 
 		w := performRequest(router, http.MethodGet, DefaultSSRDataRoute+"/guard-demo", func(req *http.Request) {
 			req.Host = "127.0.0.1:8080"
@@ -389,7 +389,6 @@ func TestSSRFetchGuardWithoutToken(t *testing.T) {
 
 func TestSSRFetchGuardWithSharedToken(t *testing.T) {
 	gin.SetMode(gin.TestMode)
-	t.Setenv("SSR_FETCH_TOKEN", "secret-token")
 
 	withTestSSREngine(t, func(engine *gin.Engine) {
 		engine.GET("/guard-demo", func(c *gin.Context) {
@@ -398,7 +397,7 @@ func TestSSRFetchGuardWithSharedToken(t *testing.T) {
 	})
 
 	router := gin.New()
-	registerSSRFetchRoutes(router)
+	registerSSRFetchRoutes(router, newOptions(WithFetchToken("secret-token")))
 
 	t.Run("same origin without token still forbidden", func(t *testing.T) {
 		w := performRequest(router, http.MethodGet, DefaultSSRDataRoute+"/guard-demo", func(req *http.Request) {
@@ -433,7 +432,6 @@ func TestSSRFetchGuardWithSharedToken(t *testing.T) {
 
 func TestRegisterSSRFetchRoutesFetcherForwardsCookies(t *testing.T) {
 	gin.SetMode(gin.TestMode)
-	t.Setenv("SSR_FETCH_TOKEN", "")
 
 	withTestSSREngine(t, func(engine *gin.Engine) {
 		engine.GET("/cookie-demo", func(c *gin.Context) {
@@ -445,7 +443,7 @@ func TestRegisterSSRFetchRoutesFetcherForwardsCookies(t *testing.T) {
 	})
 
 	router := gin.New()
-	fetcher := registerSSRFetchRoutes(router)
+	fetcher := registerSSRFetchRoutes(router, newOptions())
 
 	const token = "session-token-xyz"
 	req := httptest.NewRequest(http.MethodGet, "/cookie-demo?from=server", nil)
@@ -465,7 +463,6 @@ func TestRegisterSSRFetchRoutesFetcherForwardsCookies(t *testing.T) {
 
 func TestWrapSSRMaskHandlerErrorByDefault(t *testing.T) {
 	gin.SetMode(gin.TestMode)
-	t.Setenv("SSR_EXPOSE_HANDLER_ERROR", "")
 
 	router := gin.New()
 	router.GET("/boom", WrapSSR(func(*gin.Context) (SSRPayload, error) {
@@ -490,15 +487,16 @@ func TestWrapSSRMaskHandlerErrorByDefault(t *testing.T) {
 
 func TestWrapSSRCanExposeHandlerError(t *testing.T) {
 	gin.SetMode(gin.TestMode)
-	t.Setenv("SSR_EXPOSE_HANDLER_ERROR", "1")
-	t.Setenv("DEV_MODE", "1")
 
 	router := gin.New()
 	router.GET("/boom", WrapSSR(func(*gin.Context) (SSRPayload, error) {
 		return nil, errors.New("db: secret leaked")
 	}))
 
-	w := performRequest(router, http.MethodGet, "/boom", nil)
+	options := newOptions(WithDevMode(true), WithExposeHandlerErrors(true))
+	w := performRequest(router, http.MethodGet, "/boom", func(req *http.Request) {
+		*req = *req.WithContext(contextWithOptions(req.Context(), options))
+	})
 
 	if w.Code != http.StatusInternalServerError {
 		t.Fatalf("expected 500, got %d body=%s", w.Code, w.Body.String())
@@ -516,15 +514,16 @@ func TestWrapSSRCanExposeHandlerError(t *testing.T) {
 
 func TestWrapSSRDoesNotExposeHandlerErrorOutsideDevMode(t *testing.T) {
 	gin.SetMode(gin.TestMode)
-	t.Setenv("SSR_EXPOSE_HANDLER_ERROR", "1")
-	t.Setenv("DEV_MODE", "")
 
 	router := gin.New()
 	router.GET("/boom", WrapSSR(func(*gin.Context) (SSRPayload, error) {
 		return nil, errors.New("db: secret leaked")
 	}))
 
-	w := performRequest(router, http.MethodGet, "/boom", nil)
+	options := newOptions(WithDevMode(false), WithExposeHandlerErrors(true))
+	w := performRequest(router, http.MethodGet, "/boom", func(req *http.Request) {
+		*req = *req.WithContext(contextWithOptions(req.Context(), options))
+	})
 
 	if w.Code != http.StatusInternalServerError {
 		t.Fatalf("expected 500, got %d body=%s", w.Code, w.Body.String())
@@ -657,16 +656,22 @@ func testFrontendDistFS() fstest.MapFS {
 	}
 }
 
-func testRouterWithRunBlocking(serverScript string) *gin.Engine {
+// testBuild 返回以 testFrontendDistFS 为前端产物、serverScript 为 server.js 的构建；
+// extraFiles 按路径、内容成对给出，追加或覆盖前端文件。
+func testBuild(serverScript string, frontendFiles map[string]string) FrontendBuild {
+	dist := testFrontendDistFS()
+	for name, content := range frontendFiles {
+		dist[name] = &fstest.MapFile{Data: []byte(content)}
+	}
+	return FrontendBuild{
+		FrontendDist: dist,
+		ServerDist:   fstest.MapFS{"server.js": {Data: []byte(serverScript)}},
+	}
+}
+
+func testRouterWithRunBlocking(serverScript string, opts ...Option) *gin.Engine {
 	router := gin.New()
-	RunBlocking(router, FrontendBuild{
-		FrontendDist: testFrontendDistFS(),
-		ServerDist: fstest.MapFS{
-			"server.js": {
-				Data: []byte(serverScript),
-			},
-		},
-	}, nil)
+	RunBlocking(router, testBuild(serverScript, nil), nil, opts...)
 	return router
 }
 
@@ -686,7 +691,6 @@ func assertNoCacheHeaders(t *testing.T, header http.Header) {
 
 func TestRunBlockingCacheHeaders(t *testing.T) {
 	gin.SetMode(gin.TestMode)
-	t.Setenv("DEV_MODE", "")
 
 	router := testRouterWithRunBlocking(`globalThis.ssrRender = function(url) { return "<div id='app'>SSR:" + url + "</div>" }`)
 
@@ -727,7 +731,6 @@ func TestRunBlockingCacheHeaders(t *testing.T) {
 
 func TestRunBlockingFallbackKeepsNoCacheHeaders(t *testing.T) {
 	gin.SetMode(gin.TestMode)
-	t.Setenv("DEV_MODE", "")
 
 	router := testRouterWithRunBlocking(`globalThis.__not_renderer__ = true`)
 
@@ -742,10 +745,7 @@ func TestRunBlockingFallbackKeepsNoCacheHeaders(t *testing.T) {
 	}
 }
 
-func TestRegisterRootStaticFilesSkipsIndexAndNestedEntries(t *testing.T) {
-	gin.SetMode(gin.TestMode)
-
-	router := gin.New()
+func TestServeRootFileSkipsIndexAndNestedEntries(t *testing.T) {
 	frontendDist := fstest.MapFS{
 		"index.html": {
 			Data: []byte(testIndexHTML),
@@ -758,7 +758,11 @@ func TestRegisterRootStaticFilesSkipsIndexAndNestedEntries(t *testing.T) {
 		},
 	}
 
-	registerRootStaticFiles(router, frontendDist)
+	router := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !serveRootFile(w, r, frontendDist) {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
 
 	t.Run("root file is registered with short cache", func(t *testing.T) {
 		w := performRequest(router, http.MethodGet, "/robots.txt", nil)
@@ -790,3 +794,116 @@ func TestRegisterRootStaticFilesSkipsIndexAndNestedEntries(t *testing.T) {
 		}
 	})
 }
+
+func TestRunBlockingAppliesRenderResponseMeta(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := testRouterWithRunBlocking(`globalThis.ssrRender = function(url) {
+		if (url === "/protected") {
+			globalThis.__SSR_RESPONSE__ = { redirect: "/session-demo?next=%2Fprotected", headers: { "Set-Cookie": "next=protected; Path=/" } }
+			return ""
+		}
+		if (url === "/missing") {
+			return { html: "<div>not found</div>", status: 404, headers: { "Set-Cookie": ["a=1; Path=/", "b=2; Path=/"], "Content-Length": "1" } }
+		}
+		return "<div>ok</div>"
+	}`)
+
+	t.Run("status and multiple cookies", func(t *testing.T) {
+		w := performRequest(router, http.MethodGet, "/missing", nil)
+
+		if w.Code != http.StatusNotFound {
+			t.Fatalf("expected status 404, got %d body=%s", w.Code, w.Body.String())
+		}
+		if !strings.Contains(w.Body.String(), "<div>not found</div>") {
+			t.Fatalf("expected rendered html in body, got %s", w.Body.String())
+		}
+		if got := w.Header().Values("Set-Cookie"); len(got) != 2 {
+			t.Fatalf("expected two Set-Cookie headers, got %#v", got)
+		}
+		if got := w.Header().Get("Content-Length"); got == "1" {
+			t.Fatalf("expected Content-Length from JS to be ignored")
+		}
+		assertNoCacheHeaders(t, w.Header())
+	})
+
+	t.Run("redirect", func(t *testing.T) {
+		w := performRequest(router, http.MethodGet, "/protected", nil)
+
+		if w.Code != http.StatusFound {
+			t.Fatalf("expected status 302, got %d body=%s", w.Code, w.Body.String())
+		}
+		if got := w.Header().Get("Location"); got != "/session-demo?next=%2Fprotected" {
+			t.Fatalf("unexpected Location %q", got)
+		}
+		if got := w.Header().Get("Set-Cookie"); got != "next=protected; Path=/" {
+			t.Fatalf("unexpected Set-Cookie %q", got)
+		}
+	})
+
+	t.Run("response global does not leak between renders", func(t *testing.T) {
+		w := performRequest(router, http.MethodGet, "/ok", nil)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d body=%s", w.Code, w.Body.String())
+		}
+		if got := w.Header().Get("Location"); got != "" {
+			t.Fatalf("expected no Location header, got %q", got)
+		}
+	})
+}
+
+func TestNewRenderRequestAppliesAllowlists(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/zh/list?page=2", nil)
+	req.Host = "10.0.0.12:8080"
+	req.RemoteAddr = "10.0.0.1:5555"
+	req.Header.Set("Accept-Language", "zh-CN")
+	req.Header.Set("Authorization", "Bearer secret")
+	req.Header.Set("X-Forwarded-For", "203.0.113.7, 10.0.0.1")
+	req.AddCookie(&http.Cookie{Name: "theme", Value: "dark"})
+	req.AddCookie(&http.Cookie{Name: "session_token", Value: "secret"})
+
+	got := newRenderRequest(req, newOptions(WithRequestCookies("theme")))
+	if got.URL != "/zh/list?page=2" || got.Path != "/zh/list" || got.Query != "page=2" || got.Method != http.MethodGet {
+		t.Fatalf("unexpected url fields: %+v", got)
+	}
+	if got.Headers["accept-language"] != "zh-CN" {
+		t.Fatalf("expected allowlisted header, got %#v", got.Headers)
+	}
+	if _, ok := got.Headers["authorization"]; ok {
+		t.Fatalf("expected non-allowlisted header to be dropped, got %#v", got.Headers)
+	}
+	if got.Cookies["theme"] != "dark" || len(got.Cookies) != 1 {
+		t.Fatalf("expected only allowlisted cookie, got %#v", got.Cookies)
+	}
+	if got.Locale != "zh" || got.Origin != "http://10.0.0.12:8080" {
+		t.Fatalf("unexpected locale/origin: %+v", got)
+	}
+	if got.ClientIP != "10.0.0.1" {
+		t.Fatalf("expected remote addr ip without trusted proxy, got %q", got.ClientIP)
+	}
+
+	trusted := newRenderRequest(req, newOptions(WithTrustForwardedHeaders(true)))
+	if trusted.ClientIP != "203.0.113.7" {
+		t.Fatalf("expected forwarded client ip, got %q", trusted.ClientIP)
+	}
+}
+
+func TestRunBlockingPassesRequestURIAndRequestToRenderer(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := testRouterWithRunBlocking(`globalThis.ssrRender = function(url, request) {
+		return "<div>" + url + "|" + request.method + "|" + request.query + "|" + request.headers["accept-language"] + "</div>"
+	}`)
+
+	w := performRequest(router, http.MethodGet, "/list?page=2&sort=desc", func(req *http.Request) {
+		req.Header.Set("Accept-Language", "en-US")
+	})
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d body=%s", w.Code, w.Body.String())
+	}
+	if want := "<div>/list?page=2&sort=desc|GET|page=2&sort=desc|en-US</div>"; !strings.Contains(w.Body.String(), want) {
+		t.Fatalf("expected body to contain %q, got %s", want, w.Body.String())
+	}
+}
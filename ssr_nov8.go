@@ -9,7 +9,12 @@ import (
 	rendegojs "github.com/daodao97/gossr/renderer/engine/gojs"
 )
 
-func newRendererFromEnv(scriptContents string) renderer.Renderer {
+func newRenderer(scriptContents, scriptName string, options Options) renderer.Renderer {
 	log.Printf("Using goja SSR engine (v8 disabled via build tag)")
-	return rendegojs.NewRenderer(scriptContents)
+	return rendegojs.NewRenderer(scriptContents, options.GojaPool, renderer.WithConsole(options.Console), renderer.WithScriptName(scriptName))
+}
+
+// engineName 返回实际使用的引擎名，nov8 构建下恒为 goja。
+func engineName(Options) string {
+	return "goja"
 }
@@ -4,25 +4,34 @@ package gossr
 
 import (
 	"log"
-	"os"
-	"strings"
 
 	"github.com/daodao97/gossr/renderer"
 	rendegojs "github.com/daodao97/gossr/renderer/engine/gojs"
 	renderv8 "github.com/daodao97/gossr/renderer/engine/v8"
 )
 
-func newRendererFromEnv(scriptContents string) renderer.Renderer {
-	engine := strings.ToLower(strings.TrimSpace(os.Getenv("SSR_ENGINE")))
-	switch engine {
+func newRenderer(scriptContents, scriptName string, options Options) renderer.Renderer {
+	switch options.Engine {
 	case "", "goja", "gojs", "js", "default":
 		log.Printf("Using goja SSR engine")
-		return rendegojs.NewRenderer(scriptContents)
 	case "v8", "v8go":
 		log.Printf("Using v8go SSR engine")
-		return renderv8.NewRenderer(scriptContents)
 	default:
-		log.Printf("Unknown SSR_ENGINE=%s, fallback to goja", engine)
-		return rendegojs.NewRenderer(scriptContents)
+		log.Printf("Unknown SSR engine %q, fallback to goja", options.Engine)
+	}
+
+	if engineName(options) == "v8" {
+		return renderv8.NewRenderer(scriptContents, options.V8Pool, renderer.WithConsole(options.Console), renderer.WithScriptName(scriptName))
+	}
+	return rendegojs.NewRenderer(scriptContents, options.GojaPool, renderer.WithConsole(options.Console), renderer.WithScriptName(scriptName))
+}
+
+// engineName 返回实际使用的引擎名（goja / v8），用作指标标签。
+func engineName(options Options) string {
+	switch options.Engine {
+	case "v8", "v8go":
+		return "v8"
+	default:
+		return "goja"
 	}
 }
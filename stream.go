@@ -0,0 +1,141 @@
+package gossr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/daodao97/gossr/renderer"
+)
+
+// canStream 判断请求是否走流式输出：需开启 Streaming、引擎支持 ssrRenderStream、
+// index.html 含 <!--app-html--> 标记，且请求既非 ClientOnly 路由也不会进入页面缓存。
+func (h *pageHandler) canStream(req *http.Request) bool {
+	b := h.build.Load()
+	if !h.options.Streaming || b.streamUnsupported.Load() {
+		return false
+	}
+	if _, ok := b.ssr.(renderer.StreamRenderer); !ok {
+		return false
+	}
+	if !strings.Contains(b.indexHTML, appHTMLMarker) || len(b.islands) > 0 || h.route(req).ClientOnly {
+		return false
+	}
+	return h.cache == nil || !h.cache.accepts(req)
+}
+
+// stream 先刷出 <!--app-html--> 之前的 HTML（含 <head> 资源），再取数据并流式输出渲染结果，
+// 最后写入 __SSR_DATA__ 与剩余部分。响应头已发送，渲染结果中的 head/status/redirect 不再生效。
+func (h *pageHandler) stream(rw http.ResponseWriter, req *http.Request) {
+	b := h.acquireBuild()
+	defer b.release()
+
+	prefix, suffix, _ := strings.Cut(b.indexHTML, appHTMLMarker)
+	if locale := localeFromPath(req.URL.Path); locale != "" {
+		prefix = applyHTMLLang(prefix, locale)
+	}
+
+	header := rw.Header()
+	setHTMLNoCacheHeaders(header)
+	header.Set("Content-Type", "text/html")
+	header.Set("X-Accel-Buffering", "no")
+	rw.WriteHeader(http.StatusOK)
+
+	w := newFlushWriter(rw)
+	if _, err := io.WriteString(w, prefix); err != nil {
+		return
+	}
+
+	reqID := newRequestID()
+
+	payloadMap, err := h.loadPayload(req)
+	if err != nil {
+		log.Printf("ssr stream fetch failed id=%s path=%s err=%v", reqID, req.URL.Path, err)
+		h.metrics.IncFallback(fallbackReasonData)
+		writeStreamTail(w, suffix, nil, reqID)
+		return
+	}
+
+	err = h.renderStream(h.renderContext(req, reqID), b, h.renderTimeout(req), req.URL.RequestURI(), payloadMap, w)
+	if err != nil {
+		log.Printf("ssr stream render failed id=%s path=%s err=%v", reqID, req.URL.Path, err)
+		h.metrics.IncFallback(fallbackReasonRender)
+		writeStreamTail(w, suffix, payloadMap, reqID)
+		return
+	}
+
+	writeStreamTail(w, suffix, payloadMap, "")
+}
+
+// renderStream 调用 ssrRenderStream；脚本未提供时记录下来，并用 ssrRender 的结果补齐本次输出。
+func (h *pageHandler) renderStream(ctx context.Context, b *pageBuild, timeout time.Duration, urlPath string, payload map[string]any, w io.Writer) error {
+	sr := b.ssr.(renderer.StreamRenderer)
+	_, err := h.runRender(ctx, timeout, func(ctx context.Context) (renderer.Result, error) {
+		return sr.RenderStream(ctx, urlPath, payload, w)
+	})
+	if !errors.Is(err, renderer.ErrStreamingUnsupported) {
+		return err
+	}
+
+	if b.streamUnsupported.CompareAndSwap(false, true) {
+		log.Printf("ssr streaming disabled: %v, falling back to buffered render", err)
+	}
+
+	result, err := h.runRender(ctx, timeout, func(ctx context.Context) (renderer.Result, error) {
+		return b.ssr.Render(ctx, urlPath, payload)
+	})
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, result.HTML)
+	return err
+}
+
+// writeStreamTail 写入 index.html 的剩余部分，并在 </body> 前注入 SSR 数据与错误标记。
+func writeStreamTail(w io.Writer, suffix string, payload map[string]any, reqID string) {
+	if strings.TrimSpace(reqID) != "" {
+		errMeta := fmt.Sprintf(`<meta name="ssr-error-id" content="%s">`, template.HTMLEscapeString(reqID))
+		suffix = injectBeforeBodyEnd(suffix, errMeta)
+	}
+
+	tail, err := injectSSRData(suffix, payload)
+	if err != nil {
+		log.Println(err)
+	}
+	_, _ = io.WriteString(w, tail)
+}
+
+func injectBeforeBodyEnd(html string, content string) string {
+	if strings.Contains(html, "</body>") {
+		return strings.Replace(html, "</body>", content+"</body>", 1)
+	}
+	return content + html
+}
+
+// flushWriter 每次写入后立即 Flush，保证分块及时到达客户端。
+type flushWriter struct {
+	w       io.Writer
+	flusher http.Flusher
+}
+
+func newFlushWriter(w io.Writer) *flushWriter {
+	fw := &flushWriter{w: w}
+	if flusher, ok := w.(http.Flusher); ok {
+		fw.flusher = flusher
+	}
+	return fw
+}
+
+func (fw *flushWriter) Write(p []byte) (int, error) {
+	n, err := fw.w.Write(p)
+	if fw.flusher != nil && n > 0 {
+		fw.flusher.Flush()
+	}
+	return n, err
+}
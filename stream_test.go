@@ -0,0 +1,107 @@
+package gossr
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRunBlockingStreamsPrefixBeforeFetch(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	w := httptest.NewRecorder()
+	var bodyAtFetch string
+	router := gin.New()
+	RunBlocking(router, testBuild(`globalThis.ssrRenderStream = function(url, request, write) {
+		write("<div>")
+		write(url + ":" + __SSR_DATA__.msg)
+		write("</div>")
+	}`, nil), func(context.Context, *http.Request) (SSRPayload, error) {
+		bodyAtFetch = w.Body.String()
+		return mapPayload{"msg": "hello"}, nil
+	}, WithStreaming(true))
+
+	req := httptest.NewRequest(http.MethodGet, "/zh/stream?x=1", nil)
+	router.ServeHTTP(w, req)
+
+	if bodyAtFetch != `<!doctype html><html lang="zh"><head></head><body>` {
+		t.Fatalf("expected prefix flushed before fetch, got %q", bodyAtFetch)
+	}
+	if !w.Flushed {
+		t.Fatal("expected streamed response to be flushed")
+	}
+
+	body := w.Body.String()
+	if !strings.Contains(body, "<body><div>/zh/stream?x=1:hello</div><script id=\"ssr-data\">") {
+		t.Fatalf("expected streamed html followed by ssr data, got %s", body)
+	}
+	if !strings.HasSuffix(body, "</script></body></html>") {
+		t.Fatalf("expected tail after ssr data, got %s", body)
+	}
+	if got := w.Header().Get("X-Accel-Buffering"); got != "no" {
+		t.Fatalf("expected X-Accel-Buffering=no, got %q", got)
+	}
+	assertNoCacheHeaders(t, w.Header())
+}
+
+func TestRunBlockingStreamingFallsBackToBufferedRender(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := testRouterWithRunBlocking(`globalThis.ssrRender = function(url) {
+		globalThis.__SSR_HEAD__ = "<title>buffered</title>"
+		return "<div>" + url + "</div>"
+	}`, WithStreaming(true))
+
+	for i := 0; i < 2; i++ {
+		w := performRequest(router, http.MethodGet, "/plain", nil)
+		if !strings.Contains(w.Body.String(), "<div>/plain</div>") {
+			t.Fatalf("expected buffered html, got %s", w.Body.String())
+		}
+		// 首次请求在流中补齐 HTML，之后整页缓冲渲染，head 才能生效。
+		if i == 1 && !strings.Contains(w.Body.String(), "<title>buffered</title>") {
+			t.Fatalf("expected buffered path after fallback, got %s", w.Body.String())
+		}
+	}
+}
+
+func TestRunBlockingStreamingRenderError(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := testRouterWithRunBlocking(`globalThis.ssrRenderStream = function(url, request, write) {
+		write("<div>partial")
+		throw new Error("boom")
+	}`, WithStreaming(true))
+
+	var w *httptest.ResponseRecorder
+	captureLogOutput(t, func() {
+		w = performRequest(router, http.MethodGet, "/broken", nil)
+	})
+
+	body := w.Body.String()
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200 once streaming started, got %d", w.Code)
+	}
+	if !strings.Contains(body, `<meta name="ssr-error-id"`) || !strings.HasSuffix(body, "</body></html>") {
+		t.Fatalf("expected error marker and tail, got %s", body)
+	}
+}
+
+func TestRunBlockingStreamingSkipsCachedRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := testRouterWithRunBlocking(`globalThis.ssrRender = function(url) { return "<div>buffered</div>" }
+	globalThis.ssrRenderStream = function(url, request, write) { write("<div>streamed</div>") }`, WithStreaming(true),
+		WithRenderCache(CacheOptions{Routes: map[string]CacheRule{"/cached": {TTL: time.Minute}}}))
+
+	if w := performRequest(router, http.MethodGet, "/cached", nil); !strings.Contains(w.Body.String(), "buffered") {
+		t.Fatalf("expected cached route to use buffered render, got %s", w.Body.String())
+	}
+	if w := performRequest(router, http.MethodGet, "/live", nil); !strings.Contains(w.Body.String(), "streamed") {
+		t.Fatalf("expected uncached route to stream, got %s", w.Body.String())
+	}
+}
@@ -0,0 +1,145 @@
+package gossr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultAssetGracePeriod 为替换构建后旧构建静态资源的默认保留时长。
+const defaultAssetGracePeriod = 10 * time.Minute
+
+var errSwapUnsupported = errors.New("gossr: SwapBuild is not supported in dev mode")
+
+// WithAssetGracePeriod 设置 SwapBuild 后旧构建静态资源继续可访问的时长，<=0 时使用 10 分钟。
+// 仍在使用旧 HTML 的客户端在此期间按需加载的 chunk 不会 404。
+func WithAssetGracePeriod(d time.Duration) Option {
+	return func(opts *Options) {
+		opts.AssetGracePeriod = d
+	}
+}
+
+// SwapBuild 校验新构建后原子替换 index.html、渲染器与静态资源（蓝绿切换）：
+// index.html 须包含 <!--app-html-->，server.js 须能编译并导出 ssrRender，且 "/" 的冒烟渲染成功。
+// 校验失败时返回错误并保留当前构建；成功后旧构建上进行中的渲染照常完成，
+// 旧构建的 /assets 与根目录文件在 AssetGracePeriod 内仍可访问。
+// ISR 存储中由旧构建生成的页面随之失效并按需重新生成；Prerendered 中的页面须与构建一同发布，
+// 不属于新构建时改走实时渲染（见 prerenderedPage）。
+func (s *Server) SwapBuild(build FrontendBuild) error {
+	if s == nil || s.pages == nil {
+		return errSwapUnsupported
+	}
+
+	s.swapMu.Lock()
+	defer s.swapMu.Unlock()
+
+	next, err := s.validateBuild(build)
+	if err != nil {
+		return err
+	}
+
+	// 先挂上新资源，保证新 HTML 引用的 chunk 在切换后立即可用。
+	s.assets.push(build.FrontendDist)
+	s.pages.swapBuild(next)
+	log.Printf("ssr build swapped")
+	return nil
+}
+
+// validateBuild 加载新构建并以 "/" 做一次冒烟渲染（仅含 <!--app-html--> 时），失败时关闭已创建的渲染器。
+func (s *Server) validateBuild(build FrontendBuild) (*pageBuild, error) {
+	if build.FrontendDist == nil || build.ServerDist == nil {
+		return nil, errors.New("swap build: FrontendDist and ServerDist are required")
+	}
+
+	next, err := loadPageBuild(build, s.pages.options)
+	if err != nil {
+		return nil, fmt.Errorf("swap build: %w", err)
+	}
+	if !strings.Contains(next.indexHTML, appHTMLMarker) && len(next.islands) == 0 {
+		next.retire()
+		return nil, fmt.Errorf("swap build: index.html does not contain %s or an island outlet", appHTMLMarker)
+	}
+
+	// 只有命名出口的页面不执行 ssrRender，island 失败时按出口降级，无需冒烟渲染。
+	if !strings.Contains(next.indexHTML, appHTMLMarker) {
+		return next, nil
+	}
+	if _, err := renderWithTimeout(context.Background(), next.ssr, "/", nil, s.pages.options.RenderTimeout, nil); err != nil {
+		next.retire()
+		return nil, fmt.Errorf("swap build: smoke render of / failed: %w", err)
+	}
+	return next, nil
+}
+
+// assetLayers 把多份构建的 FrontendDist 叠成一个 fs.FS：优先当前构建，
+// 未命中时依次查找仍在宽限期内的旧构建。
+type assetLayers struct {
+	grace time.Duration
+
+	mu     sync.RWMutex
+	layers []assetLayer
+}
+
+type assetLayer struct {
+	fsys fs.FS
+	// expires 为零值表示当前构建，否则为旧构建资源的下线时间。
+	expires time.Time
+}
+
+func newAssetLayers(current fs.FS, grace time.Duration) *assetLayers {
+	if grace <= 0 {
+		grace = defaultAssetGracePeriod
+	}
+	return &assetLayers{
+		grace:  grace,
+		layers: []assetLayer{{fsys: current}},
+	}
+}
+
+// push 把 fsys 设为当前构建，原当前构建进入宽限期，并清理已过期的旧构建。
+func (l *assetLayers) push(fsys fs.FS) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	layers := []assetLayer{{fsys: fsys}}
+	for _, layer := range l.layers {
+		if layer.expires.IsZero() {
+			layer.expires = now.Add(l.grace)
+		}
+		if now.Before(layer.expires) {
+			layers = append(layers, layer)
+		}
+	}
+	l.layers = layers
+}
+
+func (l *assetLayers) Open(name string) (fs.File, error) {
+	l.mu.RLock()
+	layers := l.layers
+	l.mu.RUnlock()
+
+	now := time.Now()
+	var firstErr error
+	for _, layer := range layers {
+		if !layer.expires.IsZero() && !now.Before(layer.expires) {
+			continue
+		}
+		file, err := layer.fsys.Open(name)
+		if err == nil {
+			return file, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	if firstErr == nil {
+		firstErr = &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	return nil, firstErr
+}
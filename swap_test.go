@@ -0,0 +1,104 @@
+package gossr
+
+import (
+	"errors"
+	"io/fs"
+	"net/http"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestServerSwapBuild(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	server := RunBlocking(router, testBuild(`globalThis.ssrRender = function() { return "<p>v1</p>" }`,
+		map[string]string{"assets/app-v1.js": "console.log('v1')"}), nil,
+		WithRenderCache(CacheOptions{Default: CacheRule{TTL: time.Minute}}),
+	)
+	if body := performRequest(router, http.MethodGet, "/", nil).Body.String(); !strings.Contains(body, "<p>v1</p>") {
+		t.Fatalf("expected initial build, got %q", body)
+	}
+
+	if err := server.SwapBuild(testBuild(`globalThis.ssrRender = function() { return "<p>v2</p>" }`,
+		map[string]string{"assets/app-v2.js": "console.log('v2')", "v2.txt": "v2"})); err != nil {
+		t.Fatalf("swap build: %v", err)
+	}
+	if body := performRequest(router, http.MethodGet, "/", nil).Body.String(); !strings.Contains(body, "<p>v2</p>") {
+		t.Fatalf("expected swapped build (and flushed cache), got %q", body)
+	}
+
+	for _, target := range []string{"/assets/app-v2.js", "/assets/app-v1.js"} {
+		w := performRequest(router, http.MethodGet, target, nil)
+		if w.Code != http.StatusOK || w.Header().Get("Cache-Control") != cacheImmutableAsset {
+			t.Fatalf("%s: expected immutable asset during grace period, got %d %q", target, w.Code, w.Header().Get("Cache-Control"))
+		}
+	}
+	if w := performRequest(router, http.MethodGet, "/v2.txt", nil); w.Code != http.StatusOK || w.Body.String() != "v2" {
+		t.Fatalf("expected root file added by the new build, got %d %q", w.Code, w.Body.String())
+	}
+}
+
+func TestServerSwapBuildRejectsInvalidBuild(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	server := RunBlocking(router, testBuild(`globalThis.ssrRender = function() { return "<p>v1</p>" }`, nil), nil)
+
+	noMarker := testBuild(`globalThis.ssrRender = function() { return "<p>v2</p>" }`, map[string]string{"assets/app-v2.js": "console.log('v2')"})
+	noMarker.FrontendDist.(fstest.MapFS)["index.html"] = &fstest.MapFile{Data: []byte("<html><body></body></html>")}
+
+	cases := map[string]FrontendBuild{
+		"missing marker": noMarker,
+		"syntax error":   testBuild(`globalThis.ssrRender = function( {`, nil),
+		"no ssrRender":   testBuild(`globalThis.other = 1`, nil),
+		"render throws":  testBuild(`globalThis.ssrRender = function() { throw new Error("boom") }`, nil),
+		"missing dist":   {},
+	}
+	for name, build := range cases {
+		if err := server.SwapBuild(build); err == nil {
+			t.Fatalf("%s: expected validation error", name)
+		}
+	}
+
+	if body := performRequest(router, http.MethodGet, "/", nil).Body.String(); !strings.Contains(body, "<p>v1</p>") {
+		t.Fatalf("expected current build to be kept, got %q", body)
+	}
+	if w := performRequest(router, http.MethodGet, "/assets/app-v2.js", nil); w.Code == http.StatusOK {
+		t.Fatal("expected rejected build assets to stay unreachable")
+	}
+}
+
+func TestServerSwapBuildUnsupportedInDevMode(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	server := RunBlocking(gin.New(), FrontendBuild{}, nil, WithDevMode(true))
+	if err := server.SwapBuild(testBuild("", nil)); !errors.Is(err, errSwapUnsupported) {
+		t.Fatalf("expected dev mode swap to be rejected, got %v", err)
+	}
+}
+
+func TestAssetLayersExpireOldBuilds(t *testing.T) {
+	layers := newAssetLayers(fstest.MapFS{"old.js": {Data: []byte("old")}}, 20*time.Millisecond)
+	layers.push(fstest.MapFS{"new.js": {Data: []byte("new")}})
+
+	if _, err := fs.ReadFile(layers, "old.js"); err != nil {
+		t.Fatalf("expected old asset within grace period: %v", err)
+	}
+	time.Sleep(30 * time.Millisecond)
+	if _, err := fs.ReadFile(layers, "old.js"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("expected old asset to expire, got %v", err)
+	}
+	if _, err := fs.ReadFile(layers, "new.js"); err != nil {
+		t.Fatalf("expected current asset: %v", err)
+	}
+
+	layers.push(fstest.MapFS{})
+	if len(layers.layers) != 2 {
+		t.Fatalf("expected expired layers to be pruned, got %d", len(layers.layers))
+	}
+}
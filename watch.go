@@ -0,0 +1,105 @@
+package gossr
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// watchInterval 为 WatchDir 模式下轮询构建目录的间隔。
+var watchInterval = 500 * time.Millisecond
+
+// WithWatch 从磁盘目录 dir（vite build 的 dist/，含 client/ 与 server/）读取构建产物，
+// 并在 index.html、SSR 脚本或 manifest 变化时重新编译脚本、原子替换渲染器与引擎池。
+// layout 可选，用于自定义目录与脚本名。轮询在 Server.Close 后停止。
+// 适合配合 `vite build --watch` 在本地验证真实的 SSR 输出，不建议在生产环境开启。
+func WithWatch(dir string, layout ...Layout) Option {
+	return func(opts *Options) {
+		opts.WatchDir = dir
+		opts.WatchLayout = Layout{}
+		if len(layout) > 0 {
+			opts.WatchLayout = layout[0]
+		}
+	}
+}
+
+// DirBuild 返回读取磁盘目录 dir/client 与 dir/server 的构建产物。
+func DirBuild(dir string) FrontendBuild {
+	return layoutDirBuild(dir, Layout{})
+}
+
+// layoutDirBuild 按 layout 返回读取磁盘目录 dir 的构建产物，文件在请求时从磁盘读取。
+func layoutDirBuild(dir string, layout Layout) FrontendBuild {
+	layout = layout.withDefaults()
+	return FrontendBuild{
+		FrontendDist: os.DirFS(filepath.Join(dir, filepath.FromSlash(layout.ClientDir))),
+		ServerDist:   os.DirFS(filepath.Join(dir, filepath.FromSlash(layout.ServerDir))),
+		ScriptName:   layout.ScriptName,
+	}
+}
+
+// watch 轮询构建目录，文件发生变化且在相邻两次轮询间保持不变（构建已写完）后重新加载，stop 关闭时退出。
+// loaded 为加载当前构建前记录的指纹；新构建加载失败（如脚本尚未写完整）时保留当前构建，等待下一次变化。
+func (h *pageHandler) watch(dir string, layout Layout, build FrontendBuild, loaded string, stop <-chan struct{}) {
+	log.Printf("Watching SSR build in %s", dir)
+
+	pending := loaded
+	ticker := time.NewTicker(watchInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		current := buildFingerprint(dir, layout)
+		if current == loaded {
+			pending = current
+			continue
+		}
+		if current != pending {
+			pending = current
+			continue
+		}
+
+		loaded = current
+		next, err := loadPageBuild(build, h.options)
+		if err != nil {
+			log.Printf("ssr build reload failed: %v", err)
+			continue
+		}
+		h.swapBuild(next)
+		prewarmRenderer(next.ssr)
+		log.Printf("ssr build reloaded from %s", dir)
+	}
+}
+
+// buildFingerprint 汇总热重载关注的文件的大小与修改时间。
+func buildFingerprint(dir string, layout Layout) string {
+	var b strings.Builder
+	for _, name := range watchedBuildFiles(layout) {
+		info, err := os.Stat(filepath.Join(dir, filepath.FromSlash(name)))
+		if err != nil {
+			fmt.Fprintf(&b, "%s:-;", name)
+			continue
+		}
+		fmt.Fprintf(&b, "%s:%d:%d;", name, info.Size(), info.ModTime().UnixNano())
+	}
+	return b.String()
+}
+
+// watchedBuildFiles 按 layout 返回相对构建目录的 index.html、SSR 脚本与 manifest 路径。
+func watchedBuildFiles(layout Layout) []string {
+	layout = layout.withDefaults()
+	files := []string{path.Join(layout.ClientDir, "index.html"), path.Join(layout.ServerDir, layout.ScriptName)}
+	for _, name := range append(append([]string{}, ssrManifestPaths...), buildManifestPaths...) {
+		files = append(files, path.Join(layout.ClientDir, name))
+	}
+	return files
+}
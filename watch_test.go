@@ -0,0 +1,155 @@
+package gossr
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/daodao97/gossr/renderer"
+	"github.com/gin-gonic/gin"
+)
+
+func writeBuildFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+
+	p := filepath.Join(dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+		t.Fatalf("write %s: %v", name, err)
+	}
+}
+
+func TestRunBlockingWatchReloadsBuild(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	interval := watchInterval
+	watchInterval = 5 * time.Millisecond
+	t.Cleanup(func() { watchInterval = interval })
+
+	dir := t.TempDir()
+	writeBuildFile(t, dir, "client/index.html", testIndexHTML)
+	writeBuildFile(t, dir, "client/assets/app.js", "console.log('ok')")
+	writeBuildFile(t, dir, "server/server.js", `globalThis.ssrRender = function() { return "<p>v1</p>" }`)
+
+	router := gin.New()
+	server := RunBlocking(router, FrontendBuild{}, nil,
+		WithWatch(dir),
+		WithRenderCache(CacheOptions{Default: CacheRule{TTL: time.Minute}}),
+	)
+	t.Cleanup(func() { _ = server.Close() })
+
+	if body := performRequest(router, http.MethodGet, "/", nil).Body.String(); !strings.Contains(body, "<p>v1</p>") {
+		t.Fatalf("expected initial build, got %q", body)
+	}
+	if w := performRequest(router, http.MethodGet, "/assets/app.js", nil); w.Code != http.StatusOK {
+		t.Fatalf("expected assets from the watched directory, got %d", w.Code)
+	}
+
+	writeBuildFile(t, dir, "server/server.js", `globalThis.ssrRender = function() { return "<p>version 2</p>" }`)
+	waitForBody(t, router, "<p>version 2</p>")
+
+	// 写到一半的脚本无法编译时保留当前构建。
+	writeBuildFile(t, dir, "server/server.js", `globalThis.ssrRender = function( {`)
+	time.Sleep(50 * time.Millisecond)
+	if body := performRequest(router, http.MethodGet, "/", nil).Body.String(); !strings.Contains(body, "<p>version 2</p>") {
+		t.Fatalf("expected broken script to keep the previous build, got %q", body)
+	}
+
+	writeBuildFile(t, dir, "server/server.js", `globalThis.ssrRender = function() { return "<p>v3</p>" }`)
+	waitForBody(t, router, "<p>v3</p>")
+}
+
+func TestWatchUsesLayoutAndStopsOnClose(t *testing.T) {
+	interval := watchInterval
+	watchInterval = 5 * time.Millisecond
+	t.Cleanup(func() { watchInterval = interval })
+
+	dir := t.TempDir()
+	writeBuildFile(t, dir, "web/index.html", testIndexHTML)
+	writeBuildFile(t, dir, "ssr/entry.js", `globalThis.ssrRender = function() { return "<p>v1</p>" }`)
+
+	server, err := NewHandler(FrontendBuild{}, WithWatch(dir, Layout{ClientDir: "web", ServerDir: "ssr", ScriptName: "entry.js"}))
+	if err != nil {
+		t.Fatalf("new handler: %v", err)
+	}
+	if body := performRequest(server, http.MethodGet, "/", nil).Body.String(); !strings.Contains(body, "<p>v1</p>") {
+		t.Fatalf("expected initial build from the layout, got %q", body)
+	}
+
+	writeBuildFile(t, dir, "ssr/entry.js", `globalThis.ssrRender = function() { return "<p>v2</p>" }`)
+	waitForBody(t, server, "<p>v2</p>")
+
+	if err := server.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	writeBuildFile(t, dir, "ssr/entry.js", `globalThis.ssrRender = function() { return "<p>v3</p>" }`)
+	time.Sleep(50 * time.Millisecond)
+	if body := performRequest(server, http.MethodGet, "/", nil).Body.String(); !strings.Contains(body, "<p>v2</p>") {
+		t.Fatalf("expected no reload after Close, got %q", body)
+	}
+}
+
+func waitForBody(t *testing.T, handler http.Handler, want string) {
+	t.Helper()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		body := performRequest(handler, http.MethodGet, "/", nil).Body.String()
+		if strings.Contains(body, want) {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected reloaded body %q, got %q", want, body)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+type closableRenderer struct {
+	closed atomic.Bool
+}
+
+func (r *closableRenderer) Render(context.Context, string, map[string]any) (renderer.Result, error) {
+	return renderer.Result{HTML: "ok"}, nil
+}
+
+func (r *closableRenderer) Close() {
+	r.closed.Store(true)
+}
+
+func TestSwapBuildDrainsOldRenderer(t *testing.T) {
+	oldRenderer := &closableRenderer{}
+	h := &pageHandler{}
+	h.build.Store(&pageBuild{ssr: oldRenderer})
+
+	inflight := h.acquireBuild()
+	next := &pageBuild{ssr: &closableRenderer{}}
+	h.swapBuild(next)
+
+	if got := h.acquireBuild(); got != next {
+		t.Fatal("expected new requests to use the swapped build")
+	} else {
+		got.release()
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	if oldRenderer.closed.Load() {
+		t.Fatal("expected old renderer to stay open while a render is in flight")
+	}
+
+	inflight.release()
+	deadline := time.Now().Add(time.Second)
+	for !oldRenderer.closed.Load() {
+		if time.Now().After(deadline) {
+			t.Fatal("expected old renderer to be closed after in-flight renders finish")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}